@@ -107,6 +107,20 @@ func (a *Architecture) UintN(buf []byte) uint64 {
 	return u
 }
 
+// ExtractBitField returns the bitSize bits of buf starting at bitOffset,
+// where buf holds an integer of len(buf) bytes in the architecture's byte
+// order. Whatever the byte order, bitOffset counts from the most
+// significant bit of the integer, as DW_AT_bit_offset does. The result is
+// false if buf is longer than 8 bytes or the bits don't fit in it.
+func (a *Architecture) ExtractBitField(buf []byte, bitOffset, bitSize int64) (uint64, bool) {
+	n := int64(len(buf)) * 8
+	if n > 64 || bitSize <= 0 || bitOffset < 0 || bitOffset+bitSize > n {
+		return 0, false
+	}
+	u := a.UintN(buf) >> uint(n-bitOffset-bitSize)
+	return u & (1<<uint(bitSize) - 1), true
+}
+
 func (a *Architecture) Uintptr(buf []byte) uint64 {
 	if len(buf) != a.PointerSize {
 		panic("bad PointerSize")
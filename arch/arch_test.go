@@ -0,0 +1,71 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package arch
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestExtractBitField(t *testing.T) {
+	bigEndian := Architecture{ByteOrder: binary.BigEndian}
+	for _, test := range []struct {
+		arch      *Architecture
+		buf       []byte
+		bitOffset int64
+		bitSize   int64
+		want      uint64
+	}{
+		// 1-bit fields.
+		{&AMD64, []byte{0x01}, 7, 1, 1},
+		{&AMD64, []byte{0x01}, 6, 1, 0},
+		{&AMD64, []byte{0x80}, 0, 1, 1},
+		{&AMD64, []byte{0x00, 0x01}, 7, 1, 1},
+		{&bigEndian, []byte{0x80}, 0, 1, 1},
+		{&bigEndian, []byte{0x01}, 7, 1, 1},
+		{&bigEndian, []byte{0x01, 0x00}, 7, 1, 1},
+		{&bigEndian, []byte{0x00, 0x80}, 8, 1, 1},
+
+		// 7-bit fields.
+		{&AMD64, []byte{0x7f}, 1, 7, 0x7f},
+		{&AMD64, []byte{0xfe}, 0, 7, 0x7f},
+		{&AMD64, []byte{0x80, 0x2a}, 2, 7, 0x55},
+		{&bigEndian, []byte{0xfe}, 0, 7, 0x7f},
+		{&bigEndian, []byte{0x7f}, 1, 7, 0x7f},
+		{&bigEndian, []byte{0x01, 0x54}, 7, 7, 0x55},
+
+		// 32-bit fields.
+		{&AMD64, []byte{0x78, 0x56, 0x34, 0x12}, 0, 32, 0x12345678},
+		{&AMD64, []byte{0, 0, 0, 0, 0x78, 0x56, 0x34, 0x12}, 0, 32, 0x12345678},
+		{&AMD64, []byte{0xf0, 0x67, 0x45, 0x23, 0x01, 0, 0, 0}, 28, 32, 0x1234567f},
+		{&bigEndian, []byte{0x12, 0x34, 0x56, 0x78}, 0, 32, 0x12345678},
+		{&bigEndian, []byte{0, 0, 0, 0, 0x12, 0x34, 0x56, 0x78}, 32, 32, 0x12345678},
+		{&bigEndian, []byte{0x01, 0x23, 0x45, 0x67, 0xf0, 0, 0, 0}, 4, 32, 0x1234567f},
+
+		// 64-bit fields.
+		{&AMD64, []byte{8, 7, 6, 5, 4, 3, 2, 1}, 0, 64, 0x0102030405060708},
+		{&bigEndian, []byte{1, 2, 3, 4, 5, 6, 7, 8}, 0, 64, 0x0102030405060708},
+	} {
+		got, ok := test.arch.ExtractBitField(test.buf, test.bitOffset, test.bitSize)
+		if !ok || got != test.want {
+			t.Errorf("%v: ExtractBitField(% x, %d, %d) = %#x, %t, want %#x, true", test.arch.ByteOrder, test.buf, test.bitOffset, test.bitSize, got, ok, test.want)
+		}
+	}
+
+	// Bit fields that don't fit.
+	for _, test := range []struct {
+		buf                []byte
+		bitOffset, bitSize int64
+	}{
+		{[]byte{0}, 0, 0},
+		{[]byte{0}, -1, 1},
+		{[]byte{0}, 4, 5},
+		{make([]byte, 9), 0, 8},
+	} {
+		if got, ok := AMD64.ExtractBitField(test.buf, test.bitOffset, test.bitSize); ok {
+			t.Errorf("ExtractBitField(% x, %d, %d) = %#x, true, want false", test.buf, test.bitOffset, test.bitSize, got)
+		}
+	}
+}
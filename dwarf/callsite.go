@@ -0,0 +1,118 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+import "fmt"
+
+// A CallSite describes a call made by a function, from a DW_TAG_call_site
+// entry or its GNU precursor, DW_TAG_GNU_call_site.
+type CallSite struct {
+	CallOrigin *Entry // the subprogram called, or nil if not known, as for indirect calls
+	PC         uint64 // the return address of the call
+	TailCall   bool
+	Parameters []CallSiteParam
+}
+
+// A CallSiteParam describes an argument passed at a call site.
+type CallSiteParam struct {
+	// Location is a location expression giving where the argument is
+	// passed, usually a register.
+	Location []byte
+	// Value, if non-nil, is a location expression giving the value of the
+	// argument as computed in the caller's frame at the call.
+	Value []byte
+}
+
+// CallSites returns the call sites in the subprogram entry at
+// subprogramOff, including those in its lexical blocks and inlined
+// subroutines.
+func (d *Data) CallSites(subprogramOff Offset) ([]CallSite, error) {
+	r := d.Reader()
+	r.Seek(subprogramOff)
+	e, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.Offset != subprogramOff || e.Tag != TagSubprogram {
+		return nil, fmt.Errorf("no subprogram at offset %#x", subprogramOff)
+	}
+	if !e.Children {
+		return nil, nil
+	}
+	var sites []CallSite
+	var site *CallSite // the call site whose parameters are being read
+	for depth := 1; depth > 0; {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, DecodeError{"info", r.offset(), "unexpected end of DWARF entries"}
+		}
+		if e.Tag == 0 {
+			depth--
+			continue
+		}
+		switch e.Tag {
+		case TagCallSite, TagGNUCallSite:
+			cs, err := d.callSite(e)
+			if err != nil {
+				return nil, err
+			}
+			sites = append(sites, cs)
+			site = &sites[len(sites)-1]
+		case TagCallSiteParameter, TagGNUCallSiteParameter:
+			if site == nil {
+				break
+			}
+			var p CallSiteParam
+			p.Location, _ = e.Val(AttrLocation).([]byte)
+			p.Value, _ = e.Val(AttrCallValue).([]byte)
+			if p.Value == nil {
+				p.Value, _ = e.Val(AttrGNUCallSiteValue).([]byte)
+			}
+			site.Parameters = append(site.Parameters, p)
+		}
+		if e.Children {
+			depth++
+		}
+	}
+	return sites, nil
+}
+
+// callSite returns the CallSite described by the call site entry e,
+// without its parameters.
+func (d *Data) callSite(e *Entry) (CallSite, error) {
+	var cs CallSite
+	pc, ok := e.Val(AttrCallReturnPC).(uint64)
+	if !ok {
+		pc, ok = e.Val(AttrLowpc).(uint64)
+	}
+	if ok {
+		cs.PC = pc + d.baseAddr
+	}
+	cs.TailCall, _ = e.Val(AttrCallTailCall).(bool)
+	if !cs.TailCall {
+		cs.TailCall, _ = e.Val(AttrGNUTailCall).(bool)
+	}
+	origin, ok := e.Val(AttrCallOrigin).(Offset)
+	if !ok {
+		origin, ok = e.Val(AttrAbstractOrigin).(Offset)
+	}
+	if !ok {
+		return cs, nil
+	}
+	r := d.Reader()
+	r.Seek(origin)
+	oe, err := r.Next()
+	if err != nil {
+		return cs, err
+	}
+	if oe == nil || oe.Offset != origin {
+		return cs, DecodeError{"info", e.Offset, "call site origin is not an entry"}
+	}
+	cs.CallOrigin = oe
+	return cs, nil
+}
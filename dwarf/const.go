@@ -84,11 +84,37 @@ const (
 	AttrCallFile       Attr = 0x58
 	AttrCallLine       Attr = 0x59
 	AttrDescription    Attr = 0x5A
+	// The following are new in DWARF 5.
+	AttrDwoName            Attr = 0x76
+	AttrCallAllCalls       Attr = 0x7A
+	AttrCallAllSourceCalls Attr = 0x7B
+	AttrCallAllTailCalls   Attr = 0x7C
+	AttrCallReturnPC       Attr = 0x7D
+	AttrCallValue          Attr = 0x7E
+	AttrCallOrigin         Attr = 0x7F
+	AttrCallParameter      Attr = 0x80
+	AttrCallPC             Attr = 0x81
+	AttrCallTailCall       Attr = 0x82
+	AttrCallTarget         Attr = 0x83
+	AttrNoreturn           Attr = 0x87
+
+	// GNU extensions, the precursors of the DWARF 5 call site attributes.
+	AttrGNUCallSiteValue     Attr = 0x2111
+	AttrGNUCallSiteDataValue Attr = 0x2112
+	AttrGNUCallSiteTarget    Attr = 0x2113
+	AttrGNUTailCall          Attr = 0x2115
+	AttrGNUAllTailCallSites  Attr = 0x2116
+	AttrGNUAllCallSites      Attr = 0x2117
+
+	// GNU extension for split DWARF, the precursor of AttrDwoName.
+	AttrGNUDwoName Attr = 0x2130
 
 	// Go-specific attributes.
-	AttrGoKind Attr = 0x2900
-	AttrGoKey  Attr = 0x2901
-	AttrGoElem Attr = 0x2902
+	AttrGoKind          Attr = 0x2900
+	AttrGoKey           Attr = 0x2901
+	AttrGoElem          Attr = 0x2902
+	AttrGoEmbeddedField Attr = 0x2903
+	AttrGoPackageName   Attr = 0x2905
 )
 
 var attrNames = [...]string{
@@ -164,6 +190,19 @@ var attrNames = [...]string{
 	AttrCallFile:       "CallFile",
 	AttrCallLine:       "CallLine",
 	AttrDescription:    "Description",
+	AttrNoreturn:       "Noreturn",
+
+	AttrDwoName:            "DwoName",
+	AttrCallAllCalls:       "CallAllCalls",
+	AttrCallAllSourceCalls: "CallAllSourceCalls",
+	AttrCallAllTailCalls:   "CallAllTailCalls",
+	AttrCallReturnPC:       "CallReturnPC",
+	AttrCallValue:          "CallValue",
+	AttrCallOrigin:         "CallOrigin",
+	AttrCallParameter:      "CallParameter",
+	AttrCallPC:             "CallPC",
+	AttrCallTailCall:       "CallTailCall",
+	AttrCallTarget:         "CallTarget",
 }
 
 func (a Attr) String() string {
@@ -180,6 +219,24 @@ func (a Attr) String() string {
 		return "GoKey"
 	case AttrGoElem:
 		return "GoElem"
+	case AttrGoEmbeddedField:
+		return "GoEmbeddedField"
+	case AttrGoPackageName:
+		return "GoPackageName"
+	case AttrGNUCallSiteValue:
+		return "GNUCallSiteValue"
+	case AttrGNUCallSiteDataValue:
+		return "GNUCallSiteDataValue"
+	case AttrGNUCallSiteTarget:
+		return "GNUCallSiteTarget"
+	case AttrGNUTailCall:
+		return "GNUTailCall"
+	case AttrGNUAllTailCallSites:
+		return "GNUAllTailCallSites"
+	case AttrGNUAllCallSites:
+		return "GNUAllCallSites"
+	case AttrGNUDwoName:
+		return "GNUDwoName"
 	}
 	return strconv.Itoa(int(a))
 }
@@ -298,6 +355,13 @@ const (
 	TagTypeUnit            Tag = 0x41
 	TagRvalueReferenceType Tag = 0x42
 	TagTemplateAlias       Tag = 0x43
+	// The following are new in DWARF 5.
+	TagCallSite          Tag = 0x48
+	TagCallSiteParameter Tag = 0x49
+
+	// GNU extensions, the precursors of the DWARF 5 call site tags.
+	TagGNUCallSite          Tag = 0x4109
+	TagGNUCallSiteParameter Tag = 0x410A
 )
 
 var tagNames = [...]string{
@@ -362,6 +426,9 @@ var tagNames = [...]string{
 	TagTypeUnit:               "TypeUnit",
 	TagRvalueReferenceType:    "RvalueReferenceType",
 	TagTemplateAlias:          "TemplateAlias",
+
+	TagCallSite:          "CallSite",
+	TagCallSiteParameter: "CallSiteParameter",
 }
 
 func (t Tag) String() string {
@@ -371,6 +438,12 @@ func (t Tag) String() string {
 			return s
 		}
 	}
+	switch t {
+	case TagGNUCallSite:
+		return "GNUCallSite"
+	case TagGNUCallSiteParameter:
+		return "GNUCallSiteParameter"
+	}
 	return strconv.Itoa(int(t))
 }
 
@@ -387,8 +460,9 @@ func (t Tag) GoString() string {
 // Location expression operators.
 // The debug info encodes value locations like 8(R3)
 // as a sequence of these op codes.
-// This package does not implement full expressions;
-// the opPlusUconst operator is expected by the type parser.
+// EvalLocation implements those that need no access to
+// memory; the opPlusUconst operator is also
+// expected by the type parser.
 const (
 	opAddr       = 0x03 /* 1 op, const addr */
 	opDeref      = 0x06
@@ -450,6 +524,11 @@ const (
 	opCall2       = 0x98 /* 2-byte offset of DIE */
 	opCall4       = 0x99 /* 4-byte offset of DIE */
 	opCallRef     = 0x9A /* 4- or 8- byte offset of DIE */
+	/* also new in Dwarf v3 */
+	opCallFrameCFA = 0x9C
+	/* next two new in Dwarf v4 */
+	opImplicitValue = 0x9E /* 2 op, ULEB128 size; block of that size */
+	opStackValue    = 0x9F
 	/* 0xE0-0xFF reserved for user-specific */
 )
 
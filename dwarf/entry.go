@@ -12,6 +12,7 @@ package dwarf
 
 import (
 	"errors"
+	"fmt"
 	"strconv"
 )
 
@@ -123,6 +124,20 @@ func (e *Entry) Val(a Attr) interface{} {
 	return nil
 }
 
+// TypeAt returns the type given by e's AttrType attribute, which refers
+// either to an entry of d or, by its signature, to a type unit. As in the
+// types read by Data.Type, an entry with no AttrType, such as a function
+// returning nothing, has a VoidType.
+func (e *Entry) TypeAt(d *Data) (Type, error) {
+	switch v := e.Val(AttrType).(type) {
+	case Offset:
+		return d.Type(v)
+	case uint64:
+		return d.signatureType(v)
+	}
+	return new(VoidType), nil
+}
+
 // An Offset represents the location of an Entry within the DWARF info.
 // (See Reader.Seek.)
 type Offset uint32
@@ -230,6 +245,15 @@ func (b *buf) entry(atab abbrevTable, ubase Offset) *Entry {
 			if b.err != nil {
 				return nil
 			}
+			if b.dwarf.strReader != nil {
+				s, err := b.dwarf.readString(off)
+				if err != nil {
+					b.err = err
+					return nil
+				}
+				val = s
+				break
+			}
 			b1 := makeBuf(b.dwarf, unknownFormat{}, "str", 0, b.dwarf.str)
 			b1.skip(int(off))
 			val = b1.string()
@@ -405,3 +429,125 @@ func (r *Reader) clone() typeReader {
 func (r *Reader) offset() Offset {
 	return r.b.off
 }
+
+// IterEntries calls fn for each entry in the info section, in the order
+// of a depth-first walk of the entries of every compilation unit.  The
+// null entries that terminate lists of children are not passed to fn.
+// Iteration stops early if fn returns false.
+func (d *Data) IterEntries(fn func(*Entry) bool) error {
+	r := d.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return nil
+		}
+		if entry.Tag == 0 {
+			continue
+		}
+		if !fn(entry) {
+			return nil
+		}
+	}
+}
+
+// ForEachCU calls fn for the root entry of each compilation unit in the info
+// section, along with the entry's offset.  The children of each root entry
+// are skipped.  Iteration stops early if fn returns false.
+func (d *Data) ForEachCU(fn func(cuOff Offset, cuEntry *Entry) bool) error {
+	r := d.Reader()
+	for i := range d.unit {
+		off := d.unit[i].off
+		r.Seek(off)
+		entry, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if entry == nil {
+			return DecodeError{"info", off, "missing compilation unit entry"}
+		}
+		if !fn(off, entry) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// A CrossRefError describes a reference from one entry to another that
+// does not resolve to an entry.
+type CrossRefError struct {
+	Offset       Offset // Offset of the referring entry.
+	AttrName     Attr   // The referring attribute.
+	TargetOffset Offset // The offset referred to.
+	Message      string
+}
+
+func (e CrossRefError) Error() string {
+	return fmt.Sprintf("entry at %#x: %s: %s", e.Offset, e.AttrName, e.Message)
+}
+
+// crossRefAttrs are the attributes checked by CrossRefCheck.
+var crossRefAttrs = []Attr{AttrType, AttrAbstractOrigin, AttrSpecification, AttrImport}
+
+// CrossRefCheck checks that every AttrType, AttrAbstractOrigin,
+// AttrSpecification and AttrImport attribute in the info section refers
+// to the offset of an entry, and returns an error for each that does not.
+// It is intended for testing DWARF generators. The error result is set if
+// the info section can't be read.
+func (d *Data) CrossRefCheck() ([]CrossRefError, error) {
+	var errs []CrossRefError
+	offsets := make(map[Offset]bool)
+	var refs []*Entry
+	err := d.IterEntries(func(e *Entry) bool {
+		offsets[e.Offset] = true
+		for _, attr := range crossRefAttrs {
+			if _, ok := e.Val(attr).(Offset); ok {
+				refs = append(refs, e)
+				break
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range refs {
+		for _, attr := range crossRefAttrs {
+			target, ok := e.Val(attr).(Offset)
+			if ok && !offsets[target] {
+				errs = append(errs, CrossRefError{
+					Offset:       e.Offset,
+					AttrName:     attr,
+					TargetOffset: target,
+					Message:      fmt.Sprintf("no entry at offset %#x", target),
+				})
+			}
+		}
+	}
+	return errs, nil
+}
+
+// TypeReferences returns the entries in the info section whose AttrType
+// refers to the type at typOff, such as the variables, parameters and
+// struct fields of that type.  The first call indexes every entry in the
+// info section; later calls use the index.
+func (d *Data) TypeReferences(typOff Offset) ([]*Entry, error) {
+	d.refsMu.Lock()
+	defer d.refsMu.Unlock()
+	if d.typeRefs == nil {
+		refs := make(map[Offset][]*Entry)
+		err := d.IterEntries(func(e *Entry) bool {
+			if off, ok := e.Val(AttrType).(Offset); ok {
+				refs[off] = append(refs[off], e)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		d.typeRefs = refs
+	}
+	return d.typeRefs[typOff], nil
+}
@@ -0,0 +1,470 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	. "golang.org/x/debug/dwarf"
+)
+
+func TestIterEntries(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+
+	// Count the non-null entries the hard way.
+	want := 0
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			t.Fatal("r.Next:", err)
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag != 0 {
+			want++
+		}
+	}
+
+	got := 0
+	err := d.IterEntries(func(e *Entry) bool {
+		if e.Tag == 0 {
+			t.Errorf("IterEntries passed a null entry at offset %#x", e.Offset)
+		}
+		got++
+		return true
+	})
+	if err != nil {
+		t.Fatal("IterEntries:", err)
+	}
+	if got != want {
+		t.Errorf("IterEntries visited %d entries, want %d", got, want)
+	}
+
+	// Stop at the first compilation unit.
+	n := 0
+	err = d.IterEntries(func(e *Entry) bool {
+		n++
+		if e.Tag != TagCompileUnit {
+			t.Errorf("first entry has tag %s, want %s", e.Tag, TagCompileUnit)
+		}
+		return false
+	})
+	if err != nil {
+		t.Fatal("IterEntries:", err)
+	}
+	if n != 1 {
+		t.Errorf("IterEntries called fn %d times after it returned false, want 1", n)
+	}
+}
+
+func TestForEachCU(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	var want []Offset
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			t.Fatal("r.Next:", err)
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == TagCompileUnit {
+			want = append(want, e.Offset)
+		}
+		r.SkipChildren()
+	}
+
+	var got []Offset
+	err := d.ForEachCU(func(off Offset, e *Entry) bool {
+		if e.Offset != off {
+			t.Errorf("entry offset %#x, want %#x", e.Offset, off)
+		}
+		if e.Tag != TagCompileUnit {
+			t.Errorf("entry at %#x has tag %s, want %s", off, e.Tag, TagCompileUnit)
+		}
+		got = append(got, off)
+		return true
+	})
+	if err != nil {
+		t.Fatal("ForEachCU:", err)
+	}
+	if len(got) == 0 || len(got) != len(want) {
+		t.Fatalf("ForEachCU visited units at %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("ForEachCU visited units at %v, want %v", got, want)
+		}
+	}
+}
+
+func TestCrossRefCheck(t *testing.T) {
+	for _, file := range []string{"testdata/typedef.elf", "testdata/class.elf"} {
+		if errs, err := elfData(t, file).CrossRefCheck(); err != nil || len(errs) != 0 {
+			t.Errorf("%s: got errors %v, %v", file, errs, err)
+		}
+	}
+
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x16, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // typedef: name, type
+		0,
+	}
+	info := []byte{
+		16, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                       // address size
+		1,                       // 11: compile unit
+		2, 't', 0, 100, 0, 0, 0, // 12: typedef t of the type at 100
+		0, // 19: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := d.CrossRefCheck()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("got errors %v, want 1 error", errs)
+	}
+	if e := errs[0]; e.Offset != 12 || e.AttrName != AttrType || e.TargetOffset != 100 {
+		t.Errorf("got %+v, want error for AttrType at 12 referring to 100", e)
+	}
+
+	// An error reading the info section is returned.
+	info = []byte{
+		9, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8, // address size
+		1, // compile unit
+		9, // no such abbreviation
+	}
+	d, err = New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errs, err := d.CrossRefCheck(); err == nil {
+		t.Errorf("bad info section: got %v and no error", errs)
+	}
+}
+
+func TestObjectFiles(t *testing.T) {
+	if files, err := elfData(t, "testdata/typedef.elf").ObjectFiles(); err != nil || len(files) != 0 {
+		t.Errorf("typedef.elf: got %q, %v, want no object files", files, err)
+	}
+
+	abbrev := []byte{
+		1, 0x11, 0, 0x1b, 0x08, 0xb0, 0x42, 0x08, 0, 0, // compile unit: comp dir, GNU dwo name
+		2, 0x11, 0, 0x76, 0x08, 0, 0, // compile unit: dwo name
+		0,
+	}
+	var info []byte
+	unit := func(data string) {
+		n := 7 + len(data)
+		info = append(info, byte(n), 0, 0, 0) // unit length
+		info = append(info, 2, 0)             // version
+		info = append(info, 0, 0, 0, 0)       // abbrev offset
+		info = append(info, 8)                // address size
+		info = append(info, data...)
+	}
+	unit("\x01/build\x00x.dwo\x00")
+	unit("\x02/abs/y.dwo\x00")
+	unit("\x01/build\x00x.dwo\x00")
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := d.ObjectFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"/build/x.dwo", "/abs/y.dwo"}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %q, want %q", files, want)
+	}
+}
+
+func TestCompilationUnits(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0x03, 0x08, 0x13, 0x0b, 0x25, 0x08, 0x11, 0x01, 0x12, 0x01, 0, 0, // compile unit: name, language, producer, low pc, high pc
+		2, 0x11, 1, 0x03, 0x08, 0x13, 0x0b, 0, 0, // compile unit: name, language
+		3, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		4, 0x16, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // typedef: name, type
+		5, 0x34, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // variable: name, type
+		0,
+	}
+	var info []byte
+	unit := func(data string) {
+		n := 7 + len(data)
+		info = append(info, byte(n), 0, 0, 0) // unit length
+		info = append(info, 2, 0)             // version
+		info = append(info, 0, 0, 0, 0)       // abbrev offset
+		info = append(info, 8)                // address size
+		info = append(info, data...)
+	}
+	// a.c, at offset 0, defines int at 37 and a_t at 44, and declares a
+	// variable of type a_t.
+	unit("\x01a.c\x00\x0cgcc\x00\x00\x10\x00\x00\x00\x00\x00\x00\x00\x11\x00\x00\x00\x00\x00\x00" +
+		"\x03int\x00\x05\x04" +
+		"\x04a_t\x00\x25\x00\x00\x00" +
+		"\x05x\x00\x2c\x00\x00\x00" +
+		"\x00")
+	// b.c, at offset 61, defines long at 78 and b_t at 86.
+	unit("\x02b.c\x00\x0c" +
+		"\x03long\x00\x05\x08" +
+		"\x04b_t\x00\x11\x00\x00\x00" +
+		"\x00")
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	units, err := d.CompilationUnits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(units) != 2 {
+		t.Fatalf("got %d units, want 2", len(units))
+	}
+	for i, want := range []struct {
+		offset         Offset
+		name, producer string
+		lowpc, highpc  uint64
+		types          string
+	}{
+		{11, "a.c", "gcc", 0x1000, 0x1100, "37 int, 44 a_t"},
+		{72, "b.c", "", 0, 0, "78 long, 86 b_t"},
+	} {
+		cu := units[i]
+		if cu.Offset != want.offset || cu.Name != want.name || cu.Language != 0x0c || cu.Producer != want.producer || cu.LowPC != want.lowpc || cu.HighPC != want.highpc {
+			t.Errorf("unit %d: got %+v", i, *cu)
+		}
+		var types []string
+		err := cu.Types(func(off Offset, typ Type) error {
+			types = append(types, fmt.Sprintf("%d %s", off, typ))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(types, ", "); got != want.types {
+			t.Errorf("%s: got types %q, want %q", cu.Name, got, want.types)
+		}
+	}
+
+	// An error from fn stops the walk.
+	stop := errors.New("stop")
+	n := 0
+	err = units[0].Types(func(Offset, Type) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("got %v after %d types, want %v after 1", err, n, stop)
+	}
+}
+
+func TestPackageName(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0x85, 0x52, 0x08, 0, 0, // compile unit: Go package name
+		2, 0x11, 1, 0, 0, // compile unit
+		3, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		4, 0x16, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // typedef: name, type
+		0,
+	}
+	var info []byte
+	unit := func(data string) {
+		n := 7 + len(data)
+		info = append(info, byte(n), 0, 0, 0) // unit length
+		info = append(info, 2, 0)             // version
+		info = append(info, 0, 0, 0, 0)       // abbrev offset
+		info = append(info, 8)                // address size
+		info = append(info, data...)
+	}
+	// The unit at 11 is package net/http, and defines int at 21 and
+	// net/http.Request at 28.
+	unit("\x01net/http\x00" +
+		"\x03int\x00\x05\x08" +
+		"\x04net/http.Request\x00\x15\x00\x00\x00" +
+		"\x00")
+	// The unit at 62 has no package name, and defines long at 63.
+	unit("\x02" +
+		"\x03long\x00\x05\x08" +
+		"\x00")
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if name, err := d.PackageName(11); err != nil || name != "net/http" {
+		t.Errorf("PackageName(11) = %q, %v, want net/http", name, err)
+	}
+	for _, off := range []Offset{62, 21, 1000} {
+		if name, err := d.PackageName(off); err == nil {
+			t.Errorf("PackageName(%d) = %q, want an error", off, name)
+		}
+	}
+	for _, test := range []struct {
+		off                  Offset
+		pkg, name, qualified string
+	}{
+		{28, "net/http", "net/http.Request", `"net/http".Request`},
+		{21, "net/http", "int", "int"},
+		{63, "", "long", "long"},
+	} {
+		typ, err := d.Type(test.off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := typ.Common()
+		if c.PackageName != test.pkg || c.Name != test.name || c.QualifiedName() != test.qualified {
+			t.Errorf("type at %d: got package %q, name %q, qualified name %q, want %q, %q, %q",
+				test.off, c.PackageName, c.Name, c.QualifiedName(), test.pkg, test.name, test.qualified)
+		}
+	}
+}
+
+func TestEntryTypeAt(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		0,
+	}
+	info := []byte{
+		16, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                         // address size
+		1,                         // 11: compile unit
+		2, 'i', 'n', 't', 0, 5, 4, // 12: int
+		0, // 19: end of compile unit
+	}
+	const sig = 0x0123456789abcdef
+	types := []byte{
+		27, 0, 0, 0, // unit length
+		4, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                                              // address size
+		0xef, 0xcd, 0xab, 0x89, 0x67, 0x45, 0x23, 0x01, // signature
+		23, 0, 0, 0, // type offset
+		2, 'l', 'o', 'n', 'g', 0, 5, 8, // 23: long
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AddTypes("types", types); err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		val  interface{}
+		want string
+	}{
+		{Offset(12), "int"},
+		{uint64(sig), "long"},
+		{nil, "void"},
+	} {
+		e := &Entry{Tag: TagVariable}
+		if test.val != nil {
+			e.Field = []Field{{Attr: AttrType, Val: test.val}}
+		}
+		typ, err := e.TypeAt(d)
+		if err != nil {
+			t.Errorf("type %#v: %v", test.val, err)
+			continue
+		}
+		if typ.String() != test.want {
+			t.Errorf("type %#v: got %s, want %s", test.val, typ, test.want)
+		}
+	}
+
+	e := &Entry{Tag: TagVariable, Field: []Field{{Attr: AttrType, Val: uint64(1)}}}
+	if typ, err := e.TypeAt(d); err == nil {
+		t.Errorf("unknown signature: got %s, want error", typ)
+	}
+}
+
+func TestTypeReferences(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	typedef, err := d.LookupTypedef("t_my_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		refs, err := d.TypeReferences(typedef.Type.Common().Offset)
+		if err != nil {
+			t.Fatal("TypeReferences:", err)
+		}
+		found := false
+		for _, e := range refs {
+			if e.Val(AttrType) != typedef.Type.Common().Offset {
+				t.Errorf("entry at %#x has type %v, want %#x", e.Offset, e.Val(AttrType), typedef.Type.Common().Offset)
+			}
+			if e.Offset == typedef.Offset {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("TypeReferences(%#x) = %v, missing typedef t_my_list at %#x", typedef.Type.Common().Offset, refs, typedef.Offset)
+		}
+	}
+	refs, err := d.TypeReferences(1 << 30)
+	if err != nil || len(refs) != 0 {
+		t.Errorf("TypeReferences of a nonexistent type = %v, %v, want none", refs, err)
+	}
+}
+
+func TestStringReader(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 0, 0x03, 0x0e, 0, 0, // compile unit: name (strp)
+		0,
+	}
+	long := strings.Repeat("x", 150)
+	str := []byte("short\x00" + long + "\x00unterminated")
+	for _, test := range []struct {
+		off  byte
+		want string
+	}{
+		{0, "short"},
+		{6, long},
+		{2, "ort"},
+		{157, ""},
+	} {
+		info := []byte{
+			12, 0, 0, 0, // unit length
+			2, 0, // version
+			0, 0, 0, 0, // abbrev offset
+			8,                    // address size
+			1, test.off, 0, 0, 0, // compile unit
+		}
+		d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.SetStringReader(bytes.NewReader(str))
+		e, err := d.Reader().Next()
+		if err != nil {
+			if test.want == "" {
+				continue
+			}
+			t.Fatalf("string at %d: %v", test.off, err)
+		}
+		if test.want == "" {
+			t.Errorf("unterminated string at %d: got %q, want error", test.off, e.Val(AttrName))
+		} else if got := e.Val(AttrName); got != test.want {
+			t.Errorf("string at %d: got %q, want %q", test.off, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+import "reflect"
+
+// TypesEqual reports whether a and b describe structurally the same type,
+// possibly in different binaries: they must be the same kind of type with
+// the same name and size, structs must have the same fields at the same
+// offsets, and the types they refer to, such as the elements of arrays and
+// the targets of pointers, must be equal too. The parameters of function
+// types must also have the same names. Typedefs are looked through, so a
+// typedef of int is equal to int.
+func TypesEqual(a, b Type) bool {
+	return typesEqual(a, b, make(map[[2]Type]bool))
+}
+
+// typesEqual is TypesEqual, where compared holds the pairs of types
+// already being compared, which are assumed equal to stop cycles. The
+// pairs are keyed by identity rather than by offset, since types that were
+// not read from DWARF may all have offset 0.
+func typesEqual(a, b Type, compared map[[2]Type]bool) bool {
+	a, b = UnwrapTypedef(a), UnwrapTypedef(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	ca, cb := a.Common(), b.Common()
+	if ca.Name != cb.Name || ca.ByteSize != cb.ByteSize || ca.ReflectKind != cb.ReflectKind {
+		return false
+	}
+	key := [2]Type{a, b}
+	if compared[key] {
+		return true
+	}
+	compared[key] = true
+
+	switch a := a.(type) {
+	case *QualType:
+		b := b.(*QualType)
+		return a.Qual == b.Qual && typesEqual(a.Type, b.Type, compared)
+	case *ArrayType:
+		b := b.(*ArrayType)
+		return a.Count == b.Count && a.StrideBitSize == b.StrideBitSize && typesEqual(a.Type, b.Type, compared)
+	case *PtrType:
+		return typesEqual(a.Type, b.(*PtrType).Type, compared)
+	case *StructType:
+		return structsEqual(a, b.(*StructType), compared)
+	case *SliceType:
+		b := b.(*SliceType)
+		return structsEqual(&a.StructType, &b.StructType, compared) && typesEqual(a.ElemType, b.ElemType, compared)
+	case *StringType:
+		return structsEqual(&a.StructType, &b.(*StringType).StructType, compared)
+	case *InterfaceType:
+		return typesEqual(a.Type, b.(*InterfaceType).Type, compared)
+	case *MapType:
+		b := b.(*MapType)
+		return typesEqual(a.KeyType, b.KeyType, compared) && typesEqual(a.ElemType, b.ElemType, compared)
+	case *ChanType:
+		b := b.(*ChanType)
+		return a.Direction == b.Direction && typesEqual(a.ElemType, b.ElemType, compared)
+	case *EnumType:
+		b := b.(*EnumType)
+		if a.EnumName != b.EnumName || len(a.Val) != len(b.Val) {
+			return false
+		}
+		for i, v := range a.Val {
+			if v.Name != b.Val[i].Name || v.Val != b.Val[i].Val {
+				return false
+			}
+		}
+		return true
+	case *FuncType:
+		b := b.(*FuncType)
+		if len(a.Params) != len(b.Params) || !typesEqual(a.ReturnType, b.ReturnType, compared) {
+			return false
+		}
+		for i, p := range a.Params {
+			if p.Name != b.Params[i].Name || !typesEqual(p.Type, b.Params[i].Type, compared) {
+				return false
+			}
+		}
+		return true
+	case *NamelistType:
+		b := b.(*NamelistType)
+		if len(a.Items) != len(b.Items) {
+			return false
+		}
+		for i, item := range a.Items {
+			if item.Name != b.Items[i].Name || !typesEqual(item.Type, b.Items[i].Type, compared) {
+				return false
+			}
+		}
+		return true
+	case interface {
+		Basic() *BasicType
+	}:
+		ba, bb := a.Basic(), b.(interface {
+			Basic() *BasicType
+		}).Basic()
+		return ba.BitSize == bb.BitSize && ba.BitOffset == bb.BitOffset
+	}
+	// VoidType, DotDotDotType.
+	return true
+}
+
+// structsEqual reports whether the struct, union or class types a and b
+// are structurally equal.
+func structsEqual(a, b *StructType, compared map[[2]Type]bool) bool {
+	if a.Kind != b.Kind || a.StructName != b.StructName || a.Incomplete != b.Incomplete ||
+		len(a.Field) != len(b.Field) || len(a.Bases) != len(b.Bases) {
+		return false
+	}
+	for i, fa := range a.Field {
+		fb := b.Field[i]
+		if fa.Name != fb.Name || fa.ByteOffset != fb.ByteOffset ||
+			fa.BitOffset != fb.BitOffset || fa.BitSize != fb.BitSize ||
+			!typesEqual(fa.Type, fb.Type, compared) {
+			return false
+		}
+	}
+	for i, ba := range a.Bases {
+		bb := b.Bases[i]
+		if ba.ByteOffset != bb.ByteOffset || ba.Virtual != bb.Virtual || !typesEqual(ba.Type, bb.Type, compared) {
+			return false
+		}
+	}
+	return true
+}
+
+// TypesCompatible reports whether the types at offsets a and b in d have
+// the same memory layout, as defined by TypesCompatibleAcross.
+func (d *Data) TypesCompatible(a, b Offset) bool {
+	return TypesCompatibleAcross(d, a, d, b)
+}
+
+// TypesCompatibleAcross reports whether the type at offset a in aData has
+// the same memory layout as the type at offset b in bData, as when
+// checking a plugin's version of a type against the host's. The types
+// must have the same size and reflect kind; structs must have the same
+// fields, with the same names and offsets and compatible types; and
+// arrays must have the same number of compatible elements. Pointers need
+// only have the same size: the types they point to are not compared.
+// Unlike TypesEqual, the names of the types are not compared. A type that
+// can't be read is not compatible with anything.
+func TypesCompatibleAcross(aData *Data, a Offset, bData *Data, b Offset) bool {
+	ta, err := aData.Type(a)
+	if err != nil {
+		return false
+	}
+	tb, err := bData.Type(b)
+	if err != nil {
+		return false
+	}
+	return layoutsCompatible(ta, tb)
+}
+
+// layoutsCompatible reports whether a and b have the same memory layout.
+// Qualifiers and typedefs do not affect the layout, so they are looked
+// through.
+func layoutsCompatible(a, b Type) bool {
+	a, b = UnwrapQual(a), UnwrapQual(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if reflect.TypeOf(a) != reflect.TypeOf(b) ||
+		a.Size() != b.Size() || a.Common().ReflectKind != b.Common().ReflectKind {
+		return false
+	}
+	switch a := a.(type) {
+	case *ArrayType:
+		b := b.(*ArrayType)
+		return a.Count == b.Count && a.StrideBitSize == b.StrideBitSize && layoutsCompatible(a.Type, b.Type)
+	case *StructType:
+		return structLayoutsCompatible(a, b.(*StructType))
+	case *SliceType:
+		return structLayoutsCompatible(&a.StructType, &b.(*SliceType).StructType)
+	case *StringType:
+		return structLayoutsCompatible(&a.StructType, &b.(*StringType).StructType)
+	}
+	// Pointers and other types are compatible if they have the same size.
+	return true
+}
+
+// structLayoutsCompatible reports whether the struct, union or class
+// types a and b have the same memory layout.
+func structLayoutsCompatible(a, b *StructType) bool {
+	if (a.Kind == "union") != (b.Kind == "union") || a.Incomplete != b.Incomplete ||
+		len(a.Field) != len(b.Field) || len(a.Bases) != len(b.Bases) {
+		return false
+	}
+	for i, fa := range a.Field {
+		fb := b.Field[i]
+		if fa.Name != fb.Name || fa.ByteOffset != fb.ByteOffset ||
+			fa.BitOffset != fb.BitOffset || fa.BitSize != fb.BitSize ||
+			!layoutsCompatible(fa.Type, fb.Type) {
+			return false
+		}
+	}
+	for i, ba := range a.Bases {
+		bb := b.Bases[i]
+		if ba.ByteOffset != bb.ByteOffset || ba.Virtual != bb.Virtual || !layoutsCompatible(ba.Type, bb.Type) {
+			return false
+		}
+	}
+	return true
+}
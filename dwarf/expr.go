@@ -0,0 +1,329 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// DWARF location expressions.  DWARF v4 section 2.5 and 2.6.
+
+package dwarf
+
+import "fmt"
+
+// maxLocationOps is the number of operations EvalLocation executes before
+// giving up, so that a malformed expression with a backward branch can't
+// loop forever.
+const maxLocationOps = 10000
+
+// A LocationKind says how the result of a location expression is to be
+// interpreted.
+type LocationKind int
+
+const (
+	// LocationAddr means the object is in memory at Location.Addr.
+	LocationAddr LocationKind = iota
+	// LocationValue means the object has no location, and its value is
+	// Location.Value (DW_OP_stack_value).
+	LocationValue
+	// LocationImplicit means the object has no location, and its value is
+	// the contents of Location.Implicit (DW_OP_implicit_value).
+	LocationImplicit
+	// LocationRegister means the object is in register Location.Reg,
+	// whose value is Location.Value (DW_OP_reg0 and so on).
+	LocationRegister
+)
+
+// A Location is the result of evaluating a DWARF location expression.
+type Location struct {
+	Kind     LocationKind
+	Addr     uint64 // for LocationAddr
+	Value    uint64 // for LocationValue and LocationRegister
+	Implicit []byte // for LocationImplicit
+	Reg      int    // for LocationRegister
+}
+
+// Registers holds the registers of a stack frame, for evaluating location
+// expressions that refer to them.
+type Registers struct {
+	Reg       map[int]uint64 // register values, by DWARF register number
+	FrameBase uint64         // the frame base of the function, for DW_OP_fbreg
+	CFA       uint64         // the canonical frame address, for DW_OP_call_frame_cfa
+}
+
+// EvalLocation evaluates the DWARF location expression expr, read from an
+// attribute of the entry e. The operands of DW_OP_addr are shifted by the
+// base address, and have the address size of e's unit, or of the first
+// unit if e is nil. Evaluation stops with an error after a fixed number of
+// operations, in case a branch loops. Only operators that do not need
+// access to the registers or memory of the program are supported.
+func (d *Data) EvalLocation(e *Entry, expr []byte) (Location, error) {
+	return d.evaluateLocationExpr(e, expr, nil)
+}
+
+// EvalLocationRegs is like EvalLocation, but also supports the operators
+// that use the registers of a stack frame, whose values are given by regs:
+// the register operators DW_OP_reg0 to DW_OP_reg31 and DW_OP_regx, the
+// register-relative operators DW_OP_breg0 to DW_OP_breg31 and DW_OP_bregx,
+// DW_OP_fbreg and DW_OP_call_frame_cfa.
+func (d *Data) EvalLocationRegs(e *Entry, expr []byte, regs *Registers) (Location, error) {
+	if regs == nil {
+		regs = &Registers{}
+	}
+	return d.evaluateLocationExpr(e, expr, regs)
+}
+
+// evaluateLocationExpr evaluates a location expression. If regs is nil,
+// operators that use registers are not supported.
+func (d *Data) evaluateLocationExpr(e *Entry, expr []byte, regs *Registers) (Location, error) {
+	if len(d.unit) == 0 {
+		return Location{}, fmt.Errorf("no compilation units")
+	}
+	if len(expr) == 0 {
+		return Location{}, fmt.Errorf("empty location expression")
+	}
+	u := d.exprUnit(e)
+	b := makeBuf(d, u, "location", 0, expr)
+	var stack []uint64
+	pop := func() uint64 {
+		if len(stack) == 0 {
+			b.error("stack underflow")
+			return 0
+		}
+		x := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		return x
+	}
+	push := func(x uint64) {
+		stack = append(stack, x)
+	}
+	// jump moves the program counter by off bytes.
+	jump := func(off int16) {
+		pc := int(b.off) + int(off)
+		if pc < 0 || pc > len(expr) {
+			b.error("branch out of range")
+			return
+		}
+		b = makeBuf(d, u, "location", Offset(pc), expr[pc:])
+	}
+	bool2int := func(x bool) uint64 {
+		if x {
+			return 1
+		}
+		return 0
+	}
+	// reg returns the value of register n.
+	reg := func(n int) (uint64, error) {
+		if regs == nil {
+			return 0, fmt.Errorf("location expression uses registers")
+		}
+		x, ok := regs.Reg[n]
+		if !ok {
+			return 0, fmt.Errorf("location expression uses register %d, whose value is not known", n)
+		}
+		return x, nil
+	}
+	// regLocation returns the location of an object in register n, which
+	// must be the last operation.
+	regLocation := func(n int) (Location, error) {
+		if b.err == nil && len(b.data) != 0 {
+			return Location{}, fmt.Errorf("register operation is not the last operation")
+		}
+		x, err := reg(n)
+		if err != nil {
+			return Location{}, err
+		}
+		return Location{Kind: LocationRegister, Reg: n, Value: x}, b.err
+	}
+
+	for ops := 0; len(b.data) != 0 && b.err == nil; ops++ {
+		if ops == maxLocationOps {
+			return Location{}, fmt.Errorf("location expression did not finish after %d operations", maxLocationOps)
+		}
+		op := b.uint8()
+		switch {
+		case opLit0 <= op && op < opLit0+32:
+			push(uint64(op - opLit0))
+			continue
+		case opReg0 <= op && op < opReg0+32:
+			return regLocation(int(op - opReg0))
+		case opBreg0 <= op && op < opBreg0+32:
+			x, err := reg(int(op - opBreg0))
+			if err != nil {
+				return Location{}, err
+			}
+			push(x + uint64(b.int()))
+			continue
+		}
+		switch op {
+		case opRegx:
+			return regLocation(int(b.uint()))
+		case opBregx:
+			x, err := reg(int(b.uint()))
+			if err != nil {
+				return Location{}, err
+			}
+			push(x + uint64(b.int()))
+		case opFbreg:
+			if regs == nil {
+				return Location{}, fmt.Errorf("location expression uses the frame base")
+			}
+			push(regs.FrameBase + uint64(b.int()))
+		case opCallFrameCFA:
+			if regs == nil {
+				return Location{}, fmt.Errorf("location expression uses the canonical frame address")
+			}
+			push(regs.CFA)
+		case opAddr:
+			push(b.addr() + d.baseAddr)
+		case opConst1u:
+			push(uint64(b.uint8()))
+		case opConst1s:
+			push(uint64(int8(b.uint8())))
+		case opConst2u:
+			push(uint64(b.uint16()))
+		case opConst2s:
+			push(uint64(int16(b.uint16())))
+		case opConst4u:
+			push(uint64(b.uint32()))
+		case opConst4s:
+			push(uint64(int32(b.uint32())))
+		case opConst8u, opConst8s:
+			push(b.uint64())
+		case opConstu:
+			push(b.uint())
+		case opConsts:
+			push(uint64(b.int()))
+		case opDup:
+			x := pop()
+			push(x)
+			push(x)
+		case opDrop:
+			pop()
+		case opOver:
+			if len(stack) < 2 {
+				b.error("stack underflow")
+				break
+			}
+			push(stack[len(stack)-2])
+		case opPick:
+			i := int(b.uint8())
+			if i >= len(stack) {
+				b.error("stack underflow")
+				break
+			}
+			push(stack[len(stack)-1-i])
+		case opSwap:
+			x, y := pop(), pop()
+			push(x)
+			push(y)
+		case opRot:
+			x, y, z := pop(), pop(), pop()
+			push(x)
+			push(z)
+			push(y)
+		case opAbs:
+			x := int64(pop())
+			if x < 0 {
+				x = -x
+			}
+			push(uint64(x))
+		case opNeg:
+			push(uint64(-int64(pop())))
+		case opNot:
+			push(^pop())
+		case opPlusUconst:
+			push(pop() + b.uint())
+		case opAnd, opDiv, opMinus, opMod, opMul, opOr, opPlus, opShl, opShr, opShra, opXor,
+			opEq, opGe, opGt, opLe, opLt, opNe:
+			y, x := pop(), pop()
+			switch op {
+			case opAnd:
+				push(x & y)
+			case opDiv:
+				if y == 0 {
+					b.error("division by zero")
+					break
+				}
+				push(uint64(int64(x) / int64(y)))
+			case opMinus:
+				push(x - y)
+			case opMod:
+				if y == 0 {
+					b.error("division by zero")
+					break
+				}
+				push(x % y)
+			case opMul:
+				push(x * y)
+			case opOr:
+				push(x | y)
+			case opPlus:
+				push(x + y)
+			case opShl:
+				push(x << y)
+			case opShr:
+				push(x >> y)
+			case opShra:
+				push(uint64(int64(x) >> y))
+			case opXor:
+				push(x ^ y)
+			case opEq:
+				push(bool2int(int64(x) == int64(y)))
+			case opGe:
+				push(bool2int(int64(x) >= int64(y)))
+			case opGt:
+				push(bool2int(int64(x) > int64(y)))
+			case opLe:
+				push(bool2int(int64(x) <= int64(y)))
+			case opLt:
+				push(bool2int(int64(x) < int64(y)))
+			case opNe:
+				push(bool2int(int64(x) != int64(y)))
+			}
+		case opSkip:
+			jump(int16(b.uint16()))
+		case opBra:
+			off := int16(b.uint16())
+			if pop() != 0 {
+				jump(off)
+			}
+		case opNop:
+		case opImplicitValue:
+			n := b.uint()
+			if n > uint64(len(b.data)) {
+				b.error("underflow")
+				break
+			}
+			v := b.bytes(int(n))
+			if b.err == nil && len(b.data) != 0 {
+				return Location{}, fmt.Errorf("DW_OP_implicit_value is not the last operation")
+			}
+			return Location{Kind: LocationImplicit, Implicit: v}, b.err
+		case opStackValue:
+			x := pop()
+			if b.err == nil && len(b.data) != 0 {
+				return Location{}, fmt.Errorf("DW_OP_stack_value is not the last operation")
+			}
+			return Location{Kind: LocationValue, Value: x}, b.err
+		default:
+			return Location{}, fmt.Errorf("unsupported location operation %#x", op)
+		}
+	}
+	if b.err != nil {
+		return Location{}, b.err
+	}
+	if len(stack) == 0 {
+		return Location{}, fmt.Errorf("location expression left an empty stack")
+	}
+	return Location{Kind: LocationAddr, Addr: stack[len(stack)-1]}, nil
+}
+
+// exprUnit returns the unit of the entry e, whose address size is used to
+// decode the operands of a location expression read from e. If e is nil,
+// or its offset is in no unit, it returns the first unit.
+func (d *Data) exprUnit(e *Entry) *unit {
+	if e != nil {
+		if u := d.unitForOffset(e.Offset); u != nil {
+			return u
+		}
+	}
+	return &d.unit[0]
+}
@@ -0,0 +1,154 @@
+// Copyright 2014 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "golang.org/x/debug/dwarf"
+)
+
+func TestEvalLocation(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	tests := []struct {
+		name string
+		expr []byte
+		want Location
+	}{
+		{"addr", []byte{0x03, 0x10, 0x20, 0, 0, 0, 0, 0, 0}, Location{Kind: LocationAddr, Addr: 0x2010}},
+		{"plus_uconst", []byte{0x03, 0x10, 0x20, 0, 0, 0, 0, 0, 0, 0x23, 0x08}, Location{Kind: LocationAddr, Addr: 0x2018}},
+		{"lit stack_value", []byte{0x35, 0x9f}, Location{Kind: LocationValue, Value: 5}},
+		{"consts neg", []byte{0x11, 0x7d, 0x1f, 0x9f}, Location{Kind: LocationValue, Value: 3}},
+		{"arith", []byte{0x37, 0x33, 0x1c, 0x32, 0x1e, 0x9f}, Location{Kind: LocationValue, Value: 8}},
+		{"swap div", []byte{0x32, 0x3a, 0x16, 0x1b, 0x9f}, Location{Kind: LocationValue, Value: 5}},
+		{"shra", []byte{0x11, 0x70, 0x32, 0x26, 0x9f}, Location{Kind: LocationValue, Value: 0xfffffffffffffffc}},
+		{"bra", []byte{0x31, 0x28, 0x02, 0x00, 0x31, 0x9f, 0x39, 0x9f}, Location{Kind: LocationValue, Value: 9}},
+		{"skip", []byte{0x2f, 0x02, 0x00, 0x31, 0x9f, 0x3a, 0x9f}, Location{Kind: LocationValue, Value: 10}},
+		{"compare", []byte{0x33, 0x34, 0x2d, 0x9f}, Location{Kind: LocationValue, Value: 1}},
+		{"implicit_value", []byte{0x9e, 0x04, 1, 2, 3, 4}, Location{Kind: LocationImplicit, Implicit: []byte{1, 2, 3, 4}}},
+	}
+	for _, test := range tests {
+		got, err := d.EvalLocation(nil, test.expr)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got.Kind != test.want.Kind || got.Addr != test.want.Addr || got.Value != test.want.Value ||
+			!bytes.Equal(got.Implicit, test.want.Implicit) {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	for _, expr := range [][]byte{
+		{},                 // empty
+		{0x22},             // plus with empty stack
+		{0x50},             // reg0
+		{0x30, 0x30, 0x1b}, // division by zero
+		{0x9e, 0x08, 1, 2}, // truncated implicit value
+		{0x30, 0x9f, 0x30}, // stack_value not last
+		{0xe0},             // user-defined
+		{0x2f, 0xfd, 0xff}, // skip back to itself forever
+	} {
+		if got, err := d.EvalLocation(nil, expr); err == nil {
+			t.Errorf("EvalLocation(%x) = %+v, want error", expr, got)
+		}
+	}
+}
+
+func TestEvalLocationUnit(t *testing.T) {
+	// Two units with different address sizes. The variable in the
+	// second is at DW_OP_addr 0x2010, with a 4-byte operand.
+	abbrev := []byte{
+		1, 0x11, 0, 0, 0, // compile unit
+		2, 0x11, 1, 0, 0, // compile unit, with children
+		3, 0x34, 0, 0x02, 0x0a, 0, 0, // variable: location
+		0,
+	}
+	info := []byte{
+		8, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,           // address size
+		1,           // 11: compile unit
+		16, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		4,                                  // address size
+		2,                                  // 23: compile unit
+		3, 5, 0x03, 0x10, 0x20, 0x00, 0x00, // 24: variable at 0x2010
+		0, // 31: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := d.Reader()
+	r.Seek(24)
+	e, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := d.EvalLocation(e, e.Val(AttrLocation).([]byte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Kind != LocationAddr || got.Addr != 0x2010 {
+		t.Errorf("got %+v, want address 0x2010", got)
+	}
+	// Without the entry, the operand is read with the first unit's
+	// address size, and is too short.
+	if got, err := d.EvalLocation(nil, e.Val(AttrLocation).([]byte)); err == nil {
+		t.Errorf("without the entry: got %+v, want error", got)
+	}
+}
+
+func TestEvalLocationRegs(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	regs := &Registers{
+		Reg:       map[int]uint64{0: 100, 6: 0x7000, 40: 7},
+		FrameBase: 0x8000,
+		CFA:       0x9000,
+	}
+	tests := []struct {
+		name string
+		expr []byte
+		want Location
+	}{
+		{"reg0", []byte{0x50}, Location{Kind: LocationRegister, Reg: 0, Value: 100}},
+		{"regx", []byte{0x90, 40}, Location{Kind: LocationRegister, Reg: 40, Value: 7}},
+		{"breg6", []byte{0x76, 0x78}, Location{Kind: LocationAddr, Addr: 0x6ff8}},
+		{"bregx", []byte{0x92, 40, 0x02}, Location{Kind: LocationAddr, Addr: 9}},
+		{"fbreg", []byte{0x91, 0x10}, Location{Kind: LocationAddr, Addr: 0x8010}},
+		{"call_frame_cfa", []byte{0x9c, 0x23, 0x08}, Location{Kind: LocationAddr, Addr: 0x9008}},
+		{"breg stack_value", []byte{0x70, 0x01, 0x9f}, Location{Kind: LocationValue, Value: 101}},
+		{"no registers", []byte{0x31, 0x9f}, Location{Kind: LocationValue, Value: 1}},
+	}
+	for _, test := range tests {
+		got, err := d.EvalLocationRegs(nil, test.expr, regs)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got.Kind != test.want.Kind || got.Addr != test.want.Addr || got.Value != test.want.Value || got.Reg != test.want.Reg {
+			t.Errorf("%s: got %+v, want %+v", test.name, got, test.want)
+		}
+	}
+
+	for _, expr := range [][]byte{
+		{0x51},       // reg1, unknown
+		{0x50, 0x30}, // reg0 not last
+		{0x71, 0x00}, // breg1, unknown
+	} {
+		if got, err := d.EvalLocationRegs(nil, expr, regs); err == nil {
+			t.Errorf("EvalLocationRegs(%x) = %+v, want error", expr, got)
+		}
+	}
+	for _, expr := range [][]byte{{0x91, 0x10}, {0x9c}} {
+		if got, err := d.EvalLocation(nil, expr); err == nil {
+			t.Errorf("EvalLocation(%x) = %+v, want error", expr, got)
+		}
+	}
+}
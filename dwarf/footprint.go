@@ -0,0 +1,139 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+import (
+	"reflect"
+	"unsafe"
+)
+
+// A MemFootprint is an estimate of the memory used by a Data, in bytes.
+type MemFootprint struct {
+	SectionBytes   int64 // the debug sections passed to New and AddTypes
+	TypeCacheBytes int64 // the types decoded so far
+	IndexBytes     int64 // abbreviation tables, units and other indexes
+	TotalBytes     int64 // all of the above, plus the Data itself
+}
+
+// MemoryFootprint returns an estimate of the memory used by d. The sizes
+// of the caches and indexes are found by traversing them with reflection,
+// counting each object once, so they grow as d is used. Slices of the
+// debug sections held by the caches, such as block attributes, are
+// counted as part of the sections. If the strings are read through
+// SetStringReader, the memory held by the reader is counted in place of
+// the .debug_str section.
+func (d *Data) MemoryFootprint() MemFootprint {
+	var m MemFootprint
+	s := &sizer{seen: make(map[uintptr]bool)}
+	// The type units refer back to d.
+	s.seen[uintptr(unsafe.Pointer(d))] = true
+	for _, sec := range [][]byte{d.abbrev, d.aranges, d.frame, d.info, d.line, d.pubnames, d.ranges, d.str} {
+		s.addSection(sec)
+		m.SectionBytes += int64(len(sec))
+	}
+	for _, tu := range d.typeSigs {
+		s.addSection(tu.data)
+		m.SectionBytes += int64(len(tu.data))
+	}
+	if d.strReader != nil {
+		// The reader may hold the whole section, as a bytes.Reader does.
+		m.SectionBytes += s.indirect(reflect.ValueOf(d.strReader))
+	}
+
+	d.typeMu.RLock()
+	m.TypeCacheBytes = s.indirect(reflect.ValueOf(d.typeCache))
+	d.typeMu.RUnlock()
+	d.refsMu.Lock()
+	m.IndexBytes += s.indirect(reflect.ValueOf(d.typeRefs))
+	d.refsMu.Unlock()
+	for _, v := range []interface{}{d.abbrevCache, d.typeSigs, d.unit} {
+		m.IndexBytes += s.indirect(reflect.ValueOf(v))
+	}
+	m.TotalBytes = int64(unsafe.Sizeof(*d)) + m.SectionBytes + m.TypeCacheBytes + m.IndexBytes
+	return m
+}
+
+// A sizer adds up the memory referred to by values.
+type sizer struct {
+	seen     map[uintptr]bool // Objects already counted.
+	sections [][2]uintptr     // Address ranges of the sections, which are not counted.
+}
+
+func (s *sizer) addSection(sec []byte) {
+	if len(sec) == 0 {
+		return
+	}
+	start := uintptr(unsafe.Pointer(&sec[0]))
+	s.sections = append(s.sections, [2]uintptr{start, start + uintptr(len(sec))})
+}
+
+// inSection reports whether p points into one of the sections.
+func (s *sizer) inSection(p uintptr) bool {
+	for _, r := range s.sections {
+		if r[0] <= p && p < r[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// indirect returns the number of bytes referred to by v, not counting v
+// itself.
+func (s *sizer) indirect(v reflect.Value) int64 {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || s.seen[v.Pointer()] {
+			return 0
+		}
+		s.seen[v.Pointer()] = true
+		return int64(v.Type().Elem().Size()) + s.indirect(v.Elem())
+	case reflect.Slice:
+		if v.IsNil() || s.seen[v.Pointer()] || s.inSection(v.Pointer()) {
+			return 0
+		}
+		s.seen[v.Pointer()] = true
+		n := int64(v.Cap()) * int64(v.Type().Elem().Size())
+		for i := 0; i < v.Len(); i++ {
+			n += s.indirect(v.Index(i))
+		}
+		return n
+	case reflect.String:
+		return int64(v.Len())
+	case reflect.Map:
+		if v.IsNil() || s.seen[v.Pointer()] {
+			return 0
+		}
+		s.seen[v.Pointer()] = true
+		n := int64(v.Len()) * int64(v.Type().Key().Size()+v.Type().Elem().Size())
+		it := v.MapRange()
+		for it.Next() {
+			n += s.indirect(it.Key()) + s.indirect(it.Value())
+		}
+		return n
+	case reflect.Interface:
+		if v.IsNil() {
+			return 0
+		}
+		e := v.Elem()
+		if e.Kind() == reflect.Ptr {
+			return s.indirect(e)
+		}
+		// Other values are boxed.
+		return int64(e.Type().Size()) + s.indirect(e)
+	case reflect.Struct:
+		var n int64
+		for i := 0; i < v.NumField(); i++ {
+			n += s.indirect(v.Field(i))
+		}
+		return n
+	case reflect.Array:
+		var n int64
+		for i := 0; i < v.Len(); i++ {
+			n += s.indirect(v.Index(i))
+		}
+		return n
+	}
+	return 0
+}
@@ -30,7 +30,7 @@ func (d *Data) PCToSPOffset(pc uint64) (offset int64, err error) {
 	}
 	buf := makeBuf(d, &d.unit[0], "frame", 0, d.frame)
 	for len(buf.data) > 0 {
-		offset, err := m.evalCompilationUnit(&buf, pc)
+		offset, err := m.evalCompilationUnit(&buf, pc-d.baseAddr)
 		if err != nil {
 			return 0, err
 		}
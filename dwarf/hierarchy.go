@@ -0,0 +1,88 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+import "sync"
+
+// A TypeHierarchy records which types contain which others: a struct,
+// union or class contains the types of its fields and base classes, and
+// an array contains its element type. It is built on first use by
+// decoding every such type in the info section.
+type TypeHierarchy struct {
+	d        *Data
+	once     sync.Once
+	err      error // from reading the info section
+	children map[Offset][]Type
+	parents  map[Offset][]Type
+}
+
+// TypeHierarchy returns the containment hierarchy of the types in d.
+func (d *Data) TypeHierarchy() *TypeHierarchy {
+	return &TypeHierarchy{d: d}
+}
+
+// Children returns the types directly contained in t, each listed once.
+func (h *TypeHierarchy) Children(t Type) ([]Type, error) {
+	h.once.Do(h.build)
+	return h.children[t.Common().Offset], h.err
+}
+
+// Parent returns the types that directly contain t, each listed once.
+func (h *TypeHierarchy) Parent(t Type) ([]Type, error) {
+	h.once.Do(h.build)
+	return h.parents[t.Common().Offset], h.err
+}
+
+func (h *TypeHierarchy) build() {
+	h.children = make(map[Offset][]Type)
+	h.parents = make(map[Offset][]Type)
+	var offsets []Offset
+	h.err = h.d.IterEntries(func(e *Entry) bool {
+		switch e.Tag {
+		case TagStructType, TagClassType, TagUnionType, TagArrayType:
+			offsets = append(offsets, e.Offset)
+		}
+		return true
+	})
+	if h.err != nil {
+		return
+	}
+	for _, off := range offsets {
+		t, err := h.d.Type(off)
+		if err != nil {
+			// Types that can't be decoded are left out.
+			continue
+		}
+		switch t := t.(type) {
+		case *StructType:
+			for _, b := range t.Bases {
+				h.add(t, b.Type)
+			}
+			for _, f := range t.Field {
+				h.add(t, f.Type)
+			}
+		case *ArrayType:
+			h.add(t, t.Type)
+		}
+	}
+}
+
+// add records that parent contains child, if it is not already recorded.
+func (h *TypeHierarchy) add(parent, child Type) {
+	if _, ok := child.(*VoidType); ok || child == nil {
+		return
+	}
+	poff := parent.Common().Offset
+	for _, c := range h.children[poff] {
+		if c == child {
+			// Another field has the same type, so the pair is
+			// already in both maps.
+			return
+		}
+	}
+	h.children[poff] = append(h.children[poff], child)
+	coff := child.Common().Offset
+	h.parents[coff] = append(h.parents[coff], parent)
+}
@@ -0,0 +1,553 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// JSON encoding of types.
+//
+// Each type is encoded as an object whose "kind" field says which
+// implementation of Type it is, such as "struct" or "ptr", followed by the
+// fields of its CommonType and its own fields. A type that appears more
+// than once, as in a cyclic type such as a linked list, is encoded in full
+// only the first time; later appearances are encoded as {"$ref": id},
+// referring to the "$id" that the encoder numbered it with. Offsets are not
+// used for this, as types that were not read from a Data, and some that
+// were, such as the inner arrays of multi-dimensional arrays, share Offset
+// zero.
+
+// A jsonType is the JSON encoding of a Type.
+type jsonType struct {
+	ID          int          `json:"$id,omitempty"`
+	Ref         int          `json:"$ref,omitempty"`
+	Kind        string       `json:"kind,omitempty"`
+	Offset      Offset       `json:"offset,omitempty"`
+	Name        string       `json:"name,omitempty"`
+	ByteSize    int64        `json:"byteSize,omitempty"`
+	ReflectKind reflect.Kind `json:"reflectKind,omitempty"`
+	PackageName string       `json:"packageName,omitempty"`
+
+	// BasicType
+	BitSize   int64 `json:"bitSize,omitempty"`
+	BitOffset int64 `json:"bitOffset,omitempty"`
+
+	// QualType
+	Qual string `json:"qual,omitempty"`
+
+	// The element, target or named type of an ArrayType, PtrType,
+	// QualType or TypedefType.
+	Type *jsonType `json:"type,omitempty"`
+
+	// ArrayType
+	StrideBitSize int64 `json:"strideBitSize,omitempty"`
+	Count         int64 `json:"count,omitempty"`
+
+	// StructType
+	StructName string         `json:"structName,omitempty"`
+	StructKind string         `json:"structKind,omitempty"`
+	Field      []jsonField    `json:"field,omitempty"`
+	Bases      []jsonBase     `json:"bases,omitempty"`
+	Methods    []*MethodEntry `json:"methods,omitempty"`
+	Incomplete bool           `json:"incomplete,omitempty"`
+
+	TemplateParam []jsonTemplateParam `json:"templateParam,omitempty"`
+
+	// SliceType, MapType and ChanType
+	KeyType   *jsonType `json:"keyType,omitempty"`
+	ElemType  *jsonType `json:"elemType,omitempty"`
+	Direction ChanDir   `json:"direction,omitempty"`
+
+	// EnumType
+	EnumName string       `json:"enumName,omitempty"`
+	Val      []*EnumValue `json:"val,omitempty"`
+
+	// FuncType
+	ReturnType *jsonType   `json:"returnType,omitempty"`
+	ParamType  []*jsonType `json:"paramType,omitempty"`
+	ParamName  []string    `json:"paramName,omitempty"`
+
+	// NamelistType
+	Items []jsonItem `json:"items,omitempty"`
+}
+
+type jsonField struct {
+	Name         string    `json:"name,omitempty"`
+	Type         *jsonType `json:"type,omitempty"`
+	ByteOffset   int64     `json:"byteOffset,omitempty"`
+	ByteSize     int64     `json:"byteSize,omitempty"`
+	BitOffset    int64     `json:"bitOffset,omitempty"`
+	BitSize      int64     `json:"bitSize,omitempty"`
+	IsArtificial bool      `json:"isArtificial,omitempty"`
+	Embedded     bool      `json:"embedded,omitempty"`
+}
+
+type jsonBase struct {
+	Type       *jsonType `json:"type,omitempty"`
+	ByteOffset int64     `json:"byteOffset,omitempty"`
+	Virtual    bool      `json:"virtual,omitempty"`
+}
+
+// A jsonTemplateParam is the JSON encoding of a TemplateParam. Of the
+// values of value parameters, only integers are decoded as they were.
+type jsonTemplateParam struct {
+	Name  string      `json:"name,omitempty"`
+	Type  *jsonType   `json:"type,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+type jsonItem struct {
+	Name string    `json:"name,omitempty"`
+	Type *jsonType `json:"type,omitempty"`
+}
+
+// newJSONType returns a new zero Type of each kind.
+var newJSONType = map[string]func() Type{
+	"basic":       func() Type { return new(BasicType) },
+	"char":        func() Type { return new(CharType) },
+	"uchar":       func() Type { return new(UcharType) },
+	"int":         func() Type { return new(IntType) },
+	"uint":        func() Type { return new(UintType) },
+	"float":       func() Type { return new(FloatType) },
+	"complex":     func() Type { return new(ComplexType) },
+	"bool":        func() Type { return new(BoolType) },
+	"addr":        func() Type { return new(AddrType) },
+	"unspecified": func() Type { return new(UnspecifiedType) },
+	"qual":        func() Type { return new(QualType) },
+	"array":       func() Type { return new(ArrayType) },
+	"void":        func() Type { return new(VoidType) },
+	"ptr":         func() Type { return new(PtrType) },
+	"struct":      func() Type { return new(StructType) },
+	"slice":       func() Type { return new(SliceType) },
+	"string":      func() Type { return new(StringType) },
+	"interface":   func() Type { return new(InterfaceType) },
+	"enum":        func() Type { return new(EnumType) },
+	"func":        func() Type { return new(FuncType) },
+	"dotdotdot":   func() Type { return new(DotDotDotType) },
+	"namelist":    func() Type { return new(NamelistType) },
+	"typedef":     func() Type { return new(TypedefType) },
+	"map":         func() Type { return new(MapType) },
+	"chan":        func() Type { return new(ChanType) },
+}
+
+// jsonKind returns the "kind" of t in its JSON encoding.
+func jsonKind(t Type) string {
+	switch t.(type) {
+	case *BasicType:
+		return "basic"
+	case *CharType:
+		return "char"
+	case *UcharType:
+		return "uchar"
+	case *IntType:
+		return "int"
+	case *UintType:
+		return "uint"
+	case *FloatType:
+		return "float"
+	case *ComplexType:
+		return "complex"
+	case *BoolType:
+		return "bool"
+	case *AddrType:
+		return "addr"
+	case *UnspecifiedType:
+		return "unspecified"
+	case *QualType:
+		return "qual"
+	case *ArrayType:
+		return "array"
+	case *VoidType:
+		return "void"
+	case *PtrType:
+		return "ptr"
+	case *StructType:
+		return "struct"
+	case *SliceType:
+		return "slice"
+	case *StringType:
+		return "string"
+	case *InterfaceType:
+		return "interface"
+	case *EnumType:
+		return "enum"
+	case *FuncType:
+		return "func"
+	case *DotDotDotType:
+		return "dotdotdot"
+	case *NamelistType:
+		return "namelist"
+	case *TypedefType:
+		return "typedef"
+	case *MapType:
+		return "map"
+	case *ChanType:
+		return "chan"
+	}
+	return ""
+}
+
+func (c *CommonType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCommon(c))
+}
+
+func (t *BasicType) MarshalJSON() ([]byte, error)       { return marshalType(t) }
+func (t *CharType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *UcharType) MarshalJSON() ([]byte, error)       { return marshalType(t) }
+func (t *IntType) MarshalJSON() ([]byte, error)         { return marshalType(t) }
+func (t *UintType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *FloatType) MarshalJSON() ([]byte, error)       { return marshalType(t) }
+func (t *ComplexType) MarshalJSON() ([]byte, error)     { return marshalType(t) }
+func (t *BoolType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *AddrType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *UnspecifiedType) MarshalJSON() ([]byte, error) { return marshalType(t) }
+func (t *QualType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *ArrayType) MarshalJSON() ([]byte, error)       { return marshalType(t) }
+func (t *VoidType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *PtrType) MarshalJSON() ([]byte, error)         { return marshalType(t) }
+func (t *StructType) MarshalJSON() ([]byte, error)      { return marshalType(t) }
+func (t *SliceType) MarshalJSON() ([]byte, error)       { return marshalType(t) }
+func (t *StringType) MarshalJSON() ([]byte, error)      { return marshalType(t) }
+func (t *InterfaceType) MarshalJSON() ([]byte, error)   { return marshalType(t) }
+func (t *EnumType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *FuncType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+func (t *DotDotDotType) MarshalJSON() ([]byte, error)   { return marshalType(t) }
+func (t *NamelistType) MarshalJSON() ([]byte, error)    { return marshalType(t) }
+func (t *TypedefType) MarshalJSON() ([]byte, error)     { return marshalType(t) }
+func (t *MapType) MarshalJSON() ([]byte, error)         { return marshalType(t) }
+func (t *ChanType) MarshalJSON() ([]byte, error)        { return marshalType(t) }
+
+func jsonCommon(c *CommonType) *jsonType {
+	return &jsonType{
+		Offset:      c.Offset,
+		Name:        c.Name,
+		ByteSize:    c.ByteSize,
+		ReflectKind: c.ReflectKind,
+		PackageName: c.PackageName,
+	}
+}
+
+func marshalType(t Type) ([]byte, error) {
+	e := &typeEncoder{ids: make(map[Type]int)}
+	j, err := e.encode(t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+// A typeEncoder encodes types, numbering each from 1 as it is encoded.
+type typeEncoder struct {
+	ids map[Type]int
+}
+
+func (e *typeEncoder) encode(t Type) (*jsonType, error) {
+	if t == nil {
+		return nil, nil
+	}
+	if id, ok := e.ids[t]; ok {
+		return &jsonType{Ref: id}, nil
+	}
+	id := len(e.ids) + 1
+	e.ids[t] = id
+	j := jsonCommon(t.Common())
+	j.ID = id
+	j.Kind = jsonKind(t)
+	if j.Kind == "" {
+		return nil, fmt.Errorf("dwarf: can't encode type %T as JSON", t)
+	}
+	var err error
+	switch t := t.(type) {
+	case interface {
+		Basic() *BasicType
+	}:
+		j.BitSize = t.Basic().BitSize
+		j.BitOffset = t.Basic().BitOffset
+	case *QualType:
+		j.Qual = t.Qual
+		j.Type, err = e.encode(t.Type)
+	case *ArrayType:
+		j.StrideBitSize = t.StrideBitSize
+		j.Count = t.Count
+		j.Type, err = e.encode(t.Type)
+	case *PtrType:
+		j.Type, err = e.encode(t.Type)
+	case *StructType:
+		err = e.encodeStruct(j, t)
+	case *SliceType:
+		if err = e.encodeStruct(j, &t.StructType); err == nil {
+			j.ElemType, err = e.encode(t.ElemType)
+		}
+	case *StringType:
+		err = e.encodeStruct(j, &t.StructType)
+	case *InterfaceType:
+		j.Type, err = e.encode(t.Type)
+	case *EnumType:
+		j.EnumName = t.EnumName
+		j.Val = t.Val
+	case *FuncType:
+		if j.ReturnType, err = e.encode(t.ReturnType); err != nil {
+			return nil, err
+		}
+		for _, p := range t.ParamType {
+			var jp *jsonType
+			if jp, err = e.encode(p); err != nil {
+				return nil, err
+			}
+			j.ParamType = append(j.ParamType, jp)
+		}
+		// The names are only recorded if there are any.
+		named := false
+		names := make([]string, len(t.Params))
+		for i, p := range t.Params {
+			names[i] = p.Name
+			named = named || p.Name != ""
+		}
+		if named {
+			j.ParamName = names
+		}
+	case *NamelistType:
+		for _, item := range t.Items {
+			ji := jsonItem{Name: item.Name}
+			if ji.Type, err = e.encode(item.Type); err != nil {
+				return nil, err
+			}
+			j.Items = append(j.Items, ji)
+		}
+	case *TypedefType:
+		j.Type, err = e.encode(t.Type)
+	case *MapType:
+		if j.Type, err = e.encode(t.Type); err != nil {
+			return nil, err
+		}
+		if j.KeyType, err = e.encode(t.KeyType); err != nil {
+			return nil, err
+		}
+		j.ElemType, err = e.encode(t.ElemType)
+	case *ChanType:
+		j.Direction = t.Direction
+		if j.Type, err = e.encode(t.Type); err != nil {
+			return nil, err
+		}
+		j.ElemType, err = e.encode(t.ElemType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+func (e *typeEncoder) encodeStruct(j *jsonType, t *StructType) error {
+	j.StructName = t.StructName
+	j.StructKind = t.Kind
+	j.Methods = t.Methods
+	j.Incomplete = t.Incomplete
+	for _, f := range t.Field {
+		jf := jsonField{
+			Name:         f.Name,
+			ByteOffset:   f.ByteOffset,
+			ByteSize:     f.ByteSize,
+			BitOffset:    f.BitOffset,
+			BitSize:      f.BitSize,
+			IsArtificial: f.IsArtificial,
+			Embedded:     f.Embedded,
+		}
+		var err error
+		if jf.Type, err = e.encode(f.Type); err != nil {
+			return err
+		}
+		j.Field = append(j.Field, jf)
+	}
+	for _, b := range t.Bases {
+		jb := jsonBase{ByteOffset: b.ByteOffset, Virtual: b.Virtual}
+		var err error
+		if jb.Type, err = e.encode(b.Type); err != nil {
+			return err
+		}
+		j.Bases = append(j.Bases, jb)
+	}
+	for _, tp := range t.TemplateParam {
+		jp := jsonTemplateParam{Name: tp.Name, Value: tp.Value}
+		var err error
+		if jp.Type, err = e.encode(tp.Type); err != nil {
+			return err
+		}
+		j.TemplateParam = append(j.TemplateParam, jp)
+	}
+	return nil
+}
+
+// UnmarshalDwarfType decodes a type from its JSON encoding, as produced by
+// the MarshalJSON methods of the types.
+func UnmarshalDwarfType(data []byte) (Type, error) {
+	var j jsonType
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&j); err != nil {
+		return nil, err
+	}
+	d := &typeDecoder{types: make(map[int]Type)}
+	return d.decode(&j)
+}
+
+// A typeDecoder decodes types, remembering them by their "$id" to resolve
+// references.
+type typeDecoder struct {
+	types map[int]Type
+}
+
+func (d *typeDecoder) decode(j *jsonType) (Type, error) {
+	if j == nil {
+		return nil, nil
+	}
+	if j.Ref != 0 {
+		t, ok := d.types[j.Ref]
+		if !ok {
+			return nil, fmt.Errorf("dwarf: JSON type refers to undefined type %d", j.Ref)
+		}
+		return t, nil
+	}
+	newType, ok := newJSONType[j.Kind]
+	if !ok {
+		return nil, fmt.Errorf("dwarf: unknown JSON type kind %q", j.Kind)
+	}
+	typ := newType()
+	*typ.Common() = CommonType{
+		ByteSize:    j.ByteSize,
+		Name:        j.Name,
+		ReflectKind: j.ReflectKind,
+		Offset:      j.Offset,
+		PackageName: j.PackageName,
+	}
+	// Record the type before decoding the types it refers to, which may
+	// refer back to it.
+	if j.ID != 0 {
+		d.types[j.ID] = typ
+	}
+
+	var err error
+	switch t := typ.(type) {
+	case interface {
+		Basic() *BasicType
+	}:
+		t.Basic().BitSize = j.BitSize
+		t.Basic().BitOffset = j.BitOffset
+	case *QualType:
+		t.Qual = j.Qual
+		t.Type, err = d.decode(j.Type)
+	case *ArrayType:
+		t.StrideBitSize = j.StrideBitSize
+		t.Count = j.Count
+		t.Type, err = d.decode(j.Type)
+	case *PtrType:
+		t.Type, err = d.decode(j.Type)
+	case *StructType:
+		err = d.decodeStruct(t, j)
+	case *SliceType:
+		if err = d.decodeStruct(&t.StructType, j); err == nil {
+			t.ElemType, err = d.decode(j.ElemType)
+		}
+	case *StringType:
+		err = d.decodeStruct(&t.StructType, j)
+	case *InterfaceType:
+		t.Type, err = d.decode(j.Type)
+	case *EnumType:
+		t.EnumName = j.EnumName
+		t.Val = j.Val
+	case *FuncType:
+		if t.ReturnType, err = d.decode(j.ReturnType); err != nil {
+			return nil, err
+		}
+		for _, jp := range j.ParamType {
+			var p Type
+			if p, err = d.decode(jp); err != nil {
+				return nil, err
+			}
+			t.ParamType = append(t.ParamType, p)
+			param := Param{Type: p}
+			if i := len(t.Params); i < len(j.ParamName) {
+				param.Name = j.ParamName[i]
+			}
+			t.Params = append(t.Params, param)
+		}
+	case *NamelistType:
+		for _, ji := range j.Items {
+			item := NamelistItem{Name: ji.Name}
+			if item.Type, err = d.decode(ji.Type); err != nil {
+				return nil, err
+			}
+			t.Items = append(t.Items, item)
+		}
+	case *TypedefType:
+		t.Type, err = d.decode(j.Type)
+	case *MapType:
+		if t.Type, err = d.decode(j.Type); err != nil {
+			return nil, err
+		}
+		if t.KeyType, err = d.decode(j.KeyType); err != nil {
+			return nil, err
+		}
+		t.ElemType, err = d.decode(j.ElemType)
+	case *ChanType:
+		t.Direction = j.Direction
+		if t.Type, err = d.decode(j.Type); err != nil {
+			return nil, err
+		}
+		t.ElemType, err = d.decode(j.ElemType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+func (d *typeDecoder) decodeStruct(t *StructType, j *jsonType) error {
+	t.StructName = j.StructName
+	t.Kind = j.StructKind
+	t.Methods = j.Methods
+	t.Incomplete = j.Incomplete
+	for _, jf := range j.Field {
+		f := &StructField{
+			Name:         jf.Name,
+			ByteOffset:   jf.ByteOffset,
+			ByteSize:     jf.ByteSize,
+			BitOffset:    jf.BitOffset,
+			BitSize:      jf.BitSize,
+			IsArtificial: jf.IsArtificial,
+			Embedded:     jf.Embedded,
+		}
+		var err error
+		if f.Type, err = d.decode(jf.Type); err != nil {
+			return err
+		}
+		t.Field = append(t.Field, f)
+	}
+	for _, jb := range j.Bases {
+		b := &BaseClass{ByteOffset: jb.ByteOffset, Virtual: jb.Virtual}
+		var err error
+		if b.Type, err = d.decode(jb.Type); err != nil {
+			return err
+		}
+		t.Bases = append(t.Bases, b)
+	}
+	for _, jp := range j.TemplateParam {
+		tp := &TemplateParam{Name: jp.Name, Value: jp.Value}
+		if n, ok := jp.Value.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				tp.Value = i
+			}
+		}
+		var err error
+		if tp.Type, err = d.decode(jp.Type); err != nil {
+			return err
+		}
+		t.TemplateParam = append(t.TemplateParam, tp)
+	}
+	return nil
+}
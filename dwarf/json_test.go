@@ -0,0 +1,146 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "golang.org/x/debug/dwarf"
+)
+
+// roundTrip encodes t as JSON and decodes it again.
+func roundTrip(t *testing.T, typ Type) (Type, string) {
+	data, err := json.Marshal(typ)
+	if err != nil {
+		t.Fatalf("marshaling %s: %v", typ, err)
+	}
+	got, err := UnmarshalDwarfType(data)
+	if err != nil {
+		t.Fatalf("unmarshaling %s: %v", data, err)
+	}
+	return got, string(data)
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	i32 := &IntType{BasicType{CommonType: CommonType{Name: "int", ByteSize: 4, Offset: 1}}}
+	point := &StructType{
+		CommonType: CommonType{ByteSize: 8, Offset: 2},
+		StructName: "point",
+		Kind:       "struct",
+		Field: []*StructField{
+			{Name: "x", Type: i32, ByteOffset: 0, ByteSize: 4},
+			{Name: "y", Type: i32, ByteOffset: 4, ByteSize: 4},
+		},
+	}
+	ptr := &PtrType{CommonType: CommonType{ByteSize: 8, Offset: 3}, Type: point}
+	array := &ArrayType{CommonType: CommonType{Offset: 4}, Type: i32, StrideBitSize: 32, Count: 10}
+	incomplete := &ArrayType{CommonType: CommonType{Offset: 5}, Type: i32, Count: -1}
+	for _, typ := range []Type{i32, point, ptr, array, incomplete} {
+		got, data := roundTrip(t, typ)
+		if got.String() != typ.String() || !TypesEqual(got, typ) {
+			t.Errorf("%s: got %s after round trip through %s", typ, got, data)
+		}
+	}
+
+	// Integer template arguments are decoded as they were.
+	array4 := &StructType{
+		CommonType: CommonType{ByteSize: 16, Offset: 6},
+		StructName: "array<int, 4>",
+		Kind:       "class",
+		TemplateParam: []*TemplateParam{
+			{Name: "_Tp", Type: i32},
+			{Name: "_Nm", Type: i32, Value: int64(4)},
+		},
+	}
+	got, data := roundTrip(t, array4)
+	if st, ok := got.(*StructType); !ok || len(st.TemplateParam) != 2 || st.TemplateParam[0].Type.String() != "int" || st.TemplateParam[1].Value != int64(4) {
+		t.Errorf("template parameters were not preserved by %s", data)
+	}
+
+	// Parameter names are kept.
+	memset := &FuncType{
+		CommonType: CommonType{Offset: 7},
+		ReturnType: ptr,
+		Params:     []Param{{"s", ptr}, {"c", i32}, {"n", i32}},
+		ParamType:  []Type{ptr, i32, i32},
+	}
+	got, data = roundTrip(t, memset)
+	if got.String() != memset.String() {
+		t.Errorf("got %s after round trip through %s, want %s", got, data, memset)
+	}
+
+	// The element type is encoded once and then referred to.
+	_, data = roundTrip(t, point)
+	if n := strings.Count(data, `"kind":"int"`); n != 1 {
+		t.Errorf("int is encoded %d times in %s", n, data)
+	}
+}
+
+func TestJSONCycle(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	list, err := d.LookupTypedef("t_my_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, data := roundTrip(t, list)
+	if !strings.Contains(data, `"$ref"`) {
+		t.Errorf("cyclic type encoded without a reference: %s", data)
+	}
+	if !TypesEqual(got, list) {
+		t.Fatalf("got %s after round trip through %s", got, data)
+	}
+	// struct list {short val; t_my_list *next}
+	st, ok := UnwrapTypedef(got).(*StructType)
+	if !ok {
+		t.Fatalf("t_my_list is a %T, want a struct", UnwrapTypedef(got))
+	}
+	next, err := st.FieldByName("next")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, ok := next.Type.(*PtrType); !ok || p.Type != got {
+		t.Errorf("next is %v, want a pointer to the decoded t_my_list", next.Type)
+	}
+}
+
+func TestJSONZeroOffsets(t *testing.T) {
+	// struct s {struct s *a; int b; struct s *c}, built in memory with
+	// every offset zero.
+	st := &StructType{StructName: "s", Kind: "struct"}
+	ptr := &PtrType{CommonType: CommonType{ByteSize: 8}, Type: st}
+	i64 := &IntType{BasicType{CommonType: CommonType{Name: "int", ByteSize: 8}}}
+	st.Field = []*StructField{
+		{Name: "a", Type: ptr, ByteOffset: 0},
+		{Name: "b", Type: i64, ByteOffset: 8},
+		{Name: "c", Type: ptr, ByteOffset: 16},
+	}
+	got, data := roundTrip(t, st)
+	gst, ok := got.(*StructType)
+	if !ok || len(gst.Field) != 3 {
+		t.Fatalf("got %v after round trip through %s", got, data)
+	}
+	for _, f := range []*StructField{gst.Field[0], gst.Field[2]} {
+		if p, ok := f.Type.(*PtrType); !ok || p.Type != got {
+			t.Errorf("field %s is %v, want a pointer to the decoded struct s", f.Name, f.Type)
+		}
+	}
+	if gst.Field[1].Type.String() != "int" {
+		t.Errorf("field b is %v, want int", gst.Field[1].Type)
+	}
+}
+
+func TestUnmarshalDwarfTypeErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"kind":"unknown"}`,
+		`{"kind":"ptr","offset":1,"type":{"$ref":2}}`,
+		`not json`,
+	} {
+		if typ, err := UnmarshalDwarfType([]byte(data)); err == nil {
+			t.Errorf("UnmarshalDwarfType(%s) = %v, want error", data, typ)
+		}
+	}
+}
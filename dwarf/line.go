@@ -20,16 +20,20 @@ func (d *Data) PCToLine(pc uint64) (file string, line uint64, err error) {
 	if len(d.line) == 0 {
 		return "", 0, fmt.Errorf("PCToLine: no line table")
 	}
-	var m lineMachine
 	// Assume the first info unit is the same as us. Extremely likely. TODO?
 	if len(d.unit) == 0 {
 		return "", 0, fmt.Errorf("no info section")
 	}
-	buf := makeBuf(d, &d.unit[0], "line", 0, d.line)
+	return d.pcToLine(makeBuf(d, &d.unit[0], "line", 0, d.line), pc)
+}
+
+// pcToLine is PCToLine, using the line table in buf.
+func (d *Data) pcToLine(buf buf, pc uint64) (file string, line uint64, err error) {
+	var m lineMachine
 	if err = m.parseHeader(&buf); err != nil {
 		return "", 0, err
 	}
-	state := pcSearchState{pc: pc, newSequence: true}
+	state := pcSearchState{pc: pc - d.baseAddr, newSequence: true}
 	if err = m.evalCompilationUnit(&buf, state.findPC); err != nil {
 		return "", 0, err
 	}
@@ -42,6 +46,41 @@ func (d *Data) PCToLine(pc uint64) (file string, line uint64, err error) {
 	return m.header.file[state.lastFile].name, state.lastLine, nil
 }
 
+// lineTable returns a buf holding the line table of the compilation unit
+// entry cu, which its DW_AT_stmt_list attribute gives the offset of.
+func (d *Data) lineTable(cu *Entry) (buf, error) {
+	if len(d.line) == 0 {
+		return buf{}, fmt.Errorf("no line table")
+	}
+	off, ok := cu.Val(AttrStmtList).(int64)
+	if !ok {
+		return buf{}, fmt.Errorf("compilation unit at %#x has no line table", cu.Offset)
+	}
+	u := d.unitForOffset(cu.Offset)
+	if u == nil || off < 0 || off >= int64(len(d.line)) {
+		return buf{}, fmt.Errorf("line table offset %#x out of range", off)
+	}
+	return makeBuf(d, u, "line", Offset(off), d.line[off:]), nil
+}
+
+// lineFileName returns the name of the file with the given index in the
+// line table of the compilation unit entry cu, as used by the AttrDeclFile
+// and AttrCallFile attributes of the entries in the unit.
+func (d *Data) lineFileName(cu *Entry, index uint64) (string, error) {
+	buf, err := d.lineTable(cu)
+	if err != nil {
+		return "", err
+	}
+	var m lineMachine
+	if err := m.parseHeader(&buf); err != nil {
+		return "", err
+	}
+	if index == 0 || index >= uint64(len(m.header.file)) {
+		return "", fmt.Errorf("invalid file number %d in DWARF data", index)
+	}
+	return m.header.file[index].name, nil
+}
+
 // pcSearchState holds the state for the search PCToLine does.
 type pcSearchState struct {
 	pc uint64 // pc we are searching for.
@@ -137,7 +176,7 @@ func (d *Data) LineToPCs(file string, line uint64) ([]uint64, error) {
 	// accumulatePCs will execute for every line machine output.
 	accumulatePCs := func(m *lineMachine) (cont bool) {
 		if m.line == line && m.file == bestFile.fileNum {
-			pcs = append(pcs, m.address)
+			pcs = append(pcs, m.address+d.baseAddr)
 		}
 		return true
 	}
@@ -7,7 +7,11 @@
 // http://dwarfstd.org/doc/dwarf-2.0.0.pdf
 package dwarf // import "golang.org/x/debug/dwarf"
 
-import "encoding/binary"
+import (
+	"encoding/binary"
+	"io"
+	"sync"
+)
 
 // Data represents the DWARF debugging information
 // loaded from an executable file (for example, an ELF or Mach-O executable).
@@ -25,9 +29,27 @@ type Data struct {
 	// parsed data
 	abbrevCache map[uint32]abbrevTable
 	order       binary.ByteOrder
-	typeCache   map[Offset]Type
+	typeCache   map[Offset]Type     // guarded by typeMu
+	typeRefs    map[Offset][]*Entry // built by TypeReferences; guarded by refsMu
 	typeSigs    map[uint64]*typeUnit
 	unit        []unit
+
+	// typeMu guards typeCache, so that Type may be called concurrently.
+	// It is held for writing while a type is decoded.
+	typeMu sync.RWMutex
+
+	// refsMu guards typeRefs, so that TypeReferences may be called
+	// concurrently.
+	refsMu sync.Mutex
+
+	// pcOnce guards the building of pcIdx, the index of the code of the
+	// functions, which is done the first time a PC is looked up.
+	pcOnce sync.Once
+	pcIdx  *pcIndex
+	pcErr  error
+
+	baseAddr  uint64      // set by SetBaseAddress
+	strReader io.ReaderAt // if non-nil, used in place of str; set by SetStringReader
 }
 
 // New returns a new Data object initialized from the given parameters.
@@ -85,3 +107,51 @@ func New(abbrev, aranges, frame, info, line, pubnames, ranges, str []byte) (*Dat
 func (d *Data) AddTypes(name string, types []byte) error {
 	return d.parseTypes(name, types)
 }
+
+// SetBaseAddress sets the address at which the program is loaded, for
+// position-independent executables and shared objects, whose DWARF
+// addresses are relative to where they are loaded. The addresses passed
+// to and returned by the methods of d, such as variable locations and
+// PCs, are then shifted by addr.
+func (d *Data) SetBaseAddress(addr uint64) {
+	d.baseAddr = addr
+}
+
+// BaseAddress returns the address set by SetBaseAddress, initially zero.
+func (d *Data) BaseAddress() uint64 {
+	return d.baseAddr
+}
+
+// SetStringReader causes the strings of the .debug_str section to be read
+// from r as they are needed, in place of the section data passed to New.
+// This saves memory for programs with large string sections, of which
+// only a few strings are usually looked up.
+func (d *Data) SetStringReader(r io.ReaderAt) {
+	d.strReader = r
+	d.str = nil
+}
+
+// strChunk is the number of bytes read at a time by readString.
+const strChunk = 64
+
+// readString returns the NUL-terminated string at off in the .debug_str
+// section, read from d.strReader.
+func (d *Data) readString(off uint32) (string, error) {
+	var s []byte
+	var buf [strChunk]byte
+	for pos := int64(off); ; pos += strChunk {
+		n, err := d.strReader.ReadAt(buf[:], pos)
+		for i := 0; i < n; i++ {
+			if buf[i] == 0 {
+				return string(append(s, buf[:i]...)), nil
+			}
+		}
+		if err != nil {
+			if err == io.EOF {
+				return "", DecodeError{"str", Offset(off), "unterminated string"}
+			}
+			return "", err
+		}
+		s = append(s, buf[:n]...)
+	}
+}
@@ -0,0 +1,216 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+// This file indexes the code described by subprogram and inlined
+// subroutine entries, for looking up the function containing a PC.
+
+import "sort"
+
+// A pcRange is the address range [low, high) of some code, not shifted by
+// the base address.
+type pcRange struct {
+	low, high uint64
+}
+
+// A pcFunc is a subprogram entry that has code.
+type pcFunc struct {
+	entry  *Entry
+	cu     *Entry    // the compilation unit entry the function is in
+	ranges []pcRange // in the order they are listed
+	// inlined holds the inlined calls in the function in the order of
+	// their entries, so that an inlined call comes before the calls
+	// nested in it.
+	inlined []pcInline
+}
+
+// A pcInline is an inlined subroutine entry that has code.
+type pcInline struct {
+	entry  *Entry
+	ranges []pcRange
+}
+
+// A funcRange is one of the address ranges of a function.
+type funcRange struct {
+	pcRange
+	fn *pcFunc
+}
+
+// A pcIndex holds the address ranges of all the functions in the info
+// section, sorted by their low address.
+type pcIndex struct {
+	ranges []funcRange
+}
+
+// codeIndex returns the index of the functions in d, building it the first
+// time it is needed.
+func (d *Data) codeIndex() (*pcIndex, error) {
+	d.pcOnce.Do(func() {
+		d.pcIdx, d.pcErr = d.buildCodeIndex()
+	})
+	return d.pcIdx, d.pcErr
+}
+
+func (d *Data) buildCodeIndex() (*pcIndex, error) {
+	idx := new(pcIndex)
+	var (
+		cu    *Entry
+		base  uint64 // the base address of cu's range lists
+		depth int    // the depth of the next entry in the tree
+		// funcs holds the functions whose children are being read, with
+		// the depth of each function's entry.
+		funcs      []*pcFunc
+		funcDepths []int
+	)
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			break
+		}
+		if e.Tag == 0 {
+			depth--
+			for len(funcs) > 0 && funcDepths[len(funcs)-1] >= depth {
+				funcs, funcDepths = funcs[:len(funcs)-1], funcDepths[:len(funcs)-1]
+			}
+			continue
+		}
+		switch e.Tag {
+		case TagCompileUnit:
+			cu = e
+			base, _ = e.Val(AttrLowpc).(uint64)
+			depth = 0
+			funcs, funcDepths = funcs[:0], funcDepths[:0]
+		case TagSubprogram:
+			ranges, err := d.entryRanges(e, base)
+			if err != nil {
+				return nil, err
+			}
+			if len(ranges) == 0 {
+				break
+			}
+			fn := &pcFunc{entry: e, cu: cu, ranges: ranges}
+			for _, pr := range ranges {
+				idx.ranges = append(idx.ranges, funcRange{pr, fn})
+			}
+			if e.Children {
+				funcs, funcDepths = append(funcs, fn), append(funcDepths, depth)
+			}
+		case TagInlinedSubroutine:
+			if len(funcs) == 0 {
+				break
+			}
+			ranges, err := d.entryRanges(e, base)
+			if err != nil {
+				return nil, err
+			}
+			fn := funcs[len(funcs)-1]
+			fn.inlined = append(fn.inlined, pcInline{e, ranges})
+		}
+		if e.Children {
+			depth++
+		}
+	}
+	sort.SliceStable(idx.ranges, func(i, j int) bool {
+		return idx.ranges[i].low < idx.ranges[j].low
+	})
+	return idx, nil
+}
+
+// lookup returns the function whose code contains pc, which is not shifted
+// by the base address, or nil if there is none.
+func (idx *pcIndex) lookup(pc uint64) *pcFunc {
+	i := sort.Search(len(idx.ranges), func(i int) bool {
+		return idx.ranges[i].low > pc
+	})
+	if i > 0 && pc < idx.ranges[i-1].high {
+		return idx.ranges[i-1].fn
+	}
+	return nil
+}
+
+// entryPC returns the address of the first instruction of fn, not shifted
+// by the base address.
+func (fn *pcFunc) entryPC() uint64 {
+	if pc, ok := fn.entry.Val(AttrEntrypc).(uint64); ok {
+		return pc
+	}
+	return fn.ranges[0].low
+}
+
+// inlinedAt returns the innermost inlined call in fn whose code contains
+// pc, which is not shifted by the base address, or nil if there is none.
+func (fn *pcFunc) inlinedAt(pc uint64) *Entry {
+	var found *Entry
+	for _, in := range fn.inlined {
+		for _, pr := range in.ranges {
+			if pr.low <= pc && pc < pr.high {
+				found = in.entry
+				break
+			}
+		}
+	}
+	return found
+}
+
+// lowHighPC returns the addresses given by the DW_AT_low_pc and
+// DW_AT_high_pc attributes of e. The high PC is an address in DWARF 2
+// and 3, but DWARF 4 producers may instead give its offset from the low PC.
+func lowHighPC(e *Entry) (low, high uint64, ok bool) {
+	low, ok = e.Val(AttrLowpc).(uint64)
+	if !ok {
+		return 0, 0, false
+	}
+	switch h := e.Val(AttrHighpc).(type) {
+	case uint64:
+		return low, h, true
+	case int64:
+		return low, low + uint64(h), true
+	}
+	return 0, 0, false
+}
+
+// entryRanges returns the address ranges of the code of e, from its
+// DW_AT_low_pc and DW_AT_high_pc attributes or its DW_AT_ranges
+// attribute. base is the base address of the compilation unit, which the
+// addresses in a range list are relative to.
+func (d *Data) entryRanges(e *Entry, base uint64) ([]pcRange, error) {
+	if low, high, ok := lowHighPC(e); ok {
+		if low < high {
+			return []pcRange{{low, high}}, nil
+		}
+		return nil, nil
+	}
+	off, ok := e.Val(AttrRanges).(int64)
+	if !ok {
+		return nil, nil
+	}
+	u := d.unitForOffset(e.Offset)
+	if u == nil || off < 0 || off >= int64(len(d.ranges)) {
+		return nil, DecodeError{"ranges", Offset(off), "range list offset out of range"}
+	}
+	// A range list entry whose start is the largest address selects a
+	// new base address.
+	maxAddr := ^uint64(0) >> uint(64-8*u.asize)
+	b := makeBuf(d, u, "ranges", Offset(off), d.ranges[off:])
+	var ranges []pcRange
+	for {
+		low, high := b.addr(), b.addr()
+		if b.err != nil {
+			return nil, b.err
+		}
+		switch {
+		case low == 0 && high == 0:
+			return ranges, nil
+		case low == maxAddr:
+			base = high
+		case low < high:
+			ranges = append(ranges, pcRange{base + low, base + high})
+		}
+	}
+}
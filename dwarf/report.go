@@ -0,0 +1,76 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// DebugReport returns a human-readable summary of d, for use in bug
+// reports: the version of each compilation unit, the number of types,
+// variables and functions, which optional sections are present, and any
+// errors encountered while reading the info section.
+func (d *Data) DebugReport() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "compilation units: %d\n", len(d.unit))
+	for i := range d.unit {
+		u := &d.unit[i]
+		format := "32-bit"
+		if u.is64 {
+			format = "64-bit"
+		}
+		fmt.Fprintf(&buf, "\tunit at %#x: version %d, %s, address size %d\n", u.base, u.vers, format, u.asize)
+	}
+
+	var types, vars, funcs int
+	err := d.IterEntries(func(e *Entry) bool {
+		switch {
+		case typeTags[e.Tag]:
+			types++
+		case e.Tag == TagVariable:
+			vars++
+		case e.Tag == TagSubprogram:
+			funcs++
+		}
+		return true
+	})
+	fmt.Fprintf(&buf, "types: %d\n", types)
+	fmt.Fprintf(&buf, "variables: %d\n", vars)
+	fmt.Fprintf(&buf, "functions: %d\n", funcs)
+	fmt.Fprintf(&buf, "type units: %d\n", len(d.typeSigs))
+
+	// SetStringReader replaces the .debug_str data with a reader.
+	str := "str"
+	if d.strReader != nil {
+		str = "str (lazy)"
+	}
+	sections := []struct {
+		name    string
+		present bool
+	}{
+		{"aranges", len(d.aranges) != 0},
+		{"frame", len(d.frame) != 0},
+		{"line", len(d.line) != 0},
+		{"pubnames", len(d.pubnames) != 0},
+		{"ranges", len(d.ranges) != 0},
+		{str, len(d.str) != 0 || d.strReader != nil},
+	}
+	buf.WriteString("sections:")
+	for _, s := range sections {
+		if s.present {
+			fmt.Fprintf(&buf, " .debug_%s", s.name)
+		}
+	}
+	if len(d.typeSigs) != 0 {
+		buf.WriteString(" .debug_types")
+	}
+	buf.WriteString("\n")
+
+	if err != nil {
+		fmt.Fprintf(&buf, "error: %v\n", err)
+	}
+	return buf.String()
+}
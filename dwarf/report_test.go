@@ -0,0 +1,84 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "golang.org/x/debug/dwarf"
+)
+
+func TestDebugReport(t *testing.T) {
+	r := elfData(t, "testdata/typedef.elf").DebugReport()
+	for _, want := range []string{
+		"compilation units: 1\n",
+		"\tunit at 0x0: version 2, 32-bit, address size 8\n",
+		"functions: 1\n",
+		"sections: .debug_line .debug_str\n",
+	} {
+		if !strings.Contains(r, want) {
+			t.Errorf("report does not contain %q:\n%s", want, r)
+		}
+	}
+	if strings.Contains(r, "error") {
+		t.Errorf("report contains an error:\n%s", r)
+	}
+
+	// An info section whose only entry uses an undefined abbreviation.
+	info := []byte{
+		8, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8, // address size
+		1, // undefined abbreviation
+	}
+	d, err := New([]byte{0}, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := d.DebugReport(); !strings.Contains(r, "\nerror: ") {
+		t.Errorf("report does not contain the error:\n%s", r)
+	}
+}
+
+func TestMemoryFootprint(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	before := d.MemoryFootprint()
+	if before.SectionBytes == 0 || before.IndexBytes == 0 {
+		t.Errorf("got %+v, want nonzero section and index sizes", before)
+	}
+	if before.TypeCacheBytes != 0 {
+		t.Errorf("got %d type cache bytes before decoding any types, want 0", before.TypeCacheBytes)
+	}
+	if _, err := d.LookupTypedef("t_my_list"); err != nil {
+		t.Fatal(err)
+	}
+	after := d.MemoryFootprint()
+	if after.TypeCacheBytes == 0 {
+		t.Error("got no type cache bytes after decoding a type")
+	}
+	if after.SectionBytes != before.SectionBytes {
+		t.Errorf("section bytes changed from %d to %d", before.SectionBytes, after.SectionBytes)
+	}
+	if sum := after.SectionBytes + after.TypeCacheBytes + after.IndexBytes; after.TotalBytes <= sum {
+		t.Errorf("total %d is not more than the sum of the parts, %d", after.TotalBytes, sum)
+	}
+}
+
+func TestLazyStringsReport(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	eager := d.MemoryFootprint()
+	const size = 1 << 20
+	d.SetStringReader(bytes.NewReader(make([]byte, size)))
+	if r := d.DebugReport(); !strings.Contains(r, "sections: .debug_line .debug_str (lazy)\n") {
+		t.Errorf("report does not list the lazy .debug_str section:\n%s", r)
+	}
+	// The bytes held by the reader are counted in place of the section.
+	if lazy := d.MemoryFootprint(); lazy.SectionBytes < size || lazy.SectionBytes >= eager.SectionBytes+size {
+		t.Errorf("got %d section bytes with a %d-byte reader, %d without; want the reader counted instead of .debug_str", lazy.SectionBytes, size, eager.SectionBytes)
+	}
+}
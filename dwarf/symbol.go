@@ -11,28 +11,24 @@ import "fmt"
 // lookupEntry returns the Entry for the name. If tag is non-zero, only entries
 // with that tag are considered.
 func (d *Data) lookupEntry(name string, tag Tag) (*Entry, error) {
-	r := d.Reader()
-	for {
-		entry, err := r.Next()
-		if err != nil {
-			return nil, err
-		}
-		if entry == nil {
-			// TODO: why don't we get an error here?
-			break
-		}
+	var found *Entry
+	err := d.IterEntries(func(entry *Entry) bool {
 		if tag != 0 && tag != entry.Tag {
-			continue
+			return true
 		}
-		nameAttr := entry.Val(AttrName)
-		if nameAttr == nil {
-			continue
-		}
-		if nameAttr.(string) == name {
-			return entry, nil
+		if n, ok := entry.Val(AttrName).(string); ok && n == name {
+			found = entry
+			return false
 		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
-	return nil, fmt.Errorf("DWARF entry for %q not found", name)
+	if found == nil {
+		return nil, fmt.Errorf("DWARF entry for %q not found", name)
+	}
+	return found, nil
 }
 
 // LookupEntry returns the Entry for the named symbol.
@@ -40,6 +36,222 @@ func (d *Data) LookupEntry(name string) (*Entry, error) {
 	return d.lookupEntry(name, 0)
 }
 
+// lookupType returns the Type of the first entry with the given name and one
+// of the given tags.
+func (d *Data) lookupType(name string, tags ...Tag) (Type, error) {
+	var found *Entry
+	err := d.IterEntries(func(entry *Entry) bool {
+		for _, tag := range tags {
+			if entry.Tag == tag {
+				if n, ok := entry.Val(AttrName).(string); ok && n == name {
+					found = entry
+					return false
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("DWARF type for %q not found", name)
+	}
+	return d.Type(found.Offset)
+}
+
+// LookupTypedef returns the typedef with the given name, rather than the
+// type it names.
+func (d *Data) LookupTypedef(name string) (*TypedefType, error) {
+	t, err := d.lookupType(name, TagTypedef)
+	if err != nil {
+		return nil, err
+	}
+	tt, ok := t.(*TypedefType)
+	if !ok {
+		return nil, fmt.Errorf("DWARF type for %q is not a typedef", name)
+	}
+	return tt, nil
+}
+
+// LookupType returns the type of the first typedef, struct, union or
+// class entry in the info section with the given name.  Types are cached,
+// so looking up the same name again does not decode the type again.
+func (d *Data) LookupType(name string) (Type, error) {
+	return d.lookupType(name, TagTypedef, TagStructType, TagUnionType, TagClassType)
+}
+
+// ClassHierarchy returns the named C++ class or struct type followed by all
+// of its base classes, recursively, ordered from most derived to most base.
+// A virtual base class shared by several paths appears only once.
+func (d *Data) ClassHierarchy(typeName string) ([]*StructType, error) {
+	t, err := d.lookupType(typeName, TagClassType, TagStructType)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := t.(*StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a class", typeName)
+	}
+	var result []*StructType
+	seen := make(map[*StructType]bool)
+	var walk func(st *StructType) error
+	walk = func(st *StructType) error {
+		if seen[st] {
+			return nil
+		}
+		seen[st] = true
+		result = append(result, st)
+		for _, base := range st.Bases {
+			bt := base.Type
+			for {
+				td, ok := bt.(*TypedefType)
+				if !ok {
+					break
+				}
+				bt = td.Type
+			}
+			bst, ok := bt.(*StructType)
+			if !ok {
+				return fmt.Errorf("base class %s of %s is not a class", base.Type, st)
+			}
+			if err := walk(bst); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(st); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// TypesWithMethod returns all the struct and class types that declare a
+// member function with the given name.
+func (d *Data) TypesWithMethod(methodName string) ([]*StructType, error) {
+	var types []*StructType
+	var typeErr error
+	err := d.IterEntries(func(entry *Entry) bool {
+		if entry.Tag != TagClassType && entry.Tag != TagStructType {
+			return true
+		}
+		if entry.Val(AttrDeclaration) != nil {
+			return true
+		}
+		t, err := d.Type(entry.Offset)
+		if err != nil {
+			typeErr = err
+			return false
+		}
+		st, ok := t.(*StructType)
+		if !ok {
+			return true
+		}
+		for _, m := range st.Methods {
+			if m.Name == methodName {
+				types = append(types, st)
+				break
+			}
+		}
+		return true
+	})
+	if err == nil {
+		err = typeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return types, nil
+}
+
+// runtimeStructType returns the struct type with the given name, which is
+// expected to be defined by the Go runtime.
+func (d *Data) runtimeStructType(name string) (*StructType, error) {
+	t, err := d.lookupType(name, TagStructType)
+	if err != nil {
+		return nil, err
+	}
+	st, ok := t.(*StructType)
+	if !ok {
+		return nil, fmt.Errorf("type %s is not a struct", name)
+	}
+	return st, nil
+}
+
+// GoroutineType returns the type of the Go runtime's goroutine descriptor,
+// runtime.g.
+func (d *Data) GoroutineType() (*StructType, error) {
+	return d.runtimeStructType("runtime.g")
+}
+
+// HmapType returns the type of the Go runtime's map header, runtime.hmap.
+func (d *Data) HmapType() (*StructType, error) {
+	return d.runtimeStructType("runtime.hmap")
+}
+
+// ItabType returns the type of the Go runtime's interface table,
+// runtime.itab.
+func (d *Data) ItabType() (*StructType, error) {
+	return d.runtimeStructType("runtime.itab")
+}
+
+// HchanType returns the type of the Go runtime's channel header,
+// runtime.hchan.
+func (d *Data) HchanType() (*StructType, error) {
+	return d.runtimeStructType("runtime.hchan")
+}
+
+// TypeUsageCount returns, for each type used by a variable, the number of
+// variables of that type, keyed by the type's String.  Variables whose type
+// cannot be read are not counted.
+func (d *Data) TypeUsageCount() (map[string]int, error) {
+	count := make(map[string]int)
+	err := d.IterEntries(func(entry *Entry) bool {
+		if entry.Tag != TagVariable {
+			return true
+		}
+		off, ok := entry.Val(AttrType).(Offset)
+		if !ok {
+			return true
+		}
+		if t, err := d.Type(off); err == nil {
+			count[t.String()]++
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return count, nil
+}
+
+// AllFunctions returns the entries of all concrete functions: subprograms
+// that have code, excluding declarations and abstract instances of inlined
+// functions. The out-of-line copy of a function that is also inlined is
+// included; its name and other attributes are those of the entry its
+// DW_AT_abstract_origin refers to.
+func (d *Data) AllFunctions() ([]*Entry, error) {
+	var funcs []*Entry
+	err := d.IterEntries(func(entry *Entry) bool {
+		if entry.Tag != TagSubprogram {
+			return true
+		}
+		if entry.Val(AttrDeclaration) != nil || entry.Val(AttrInline) != nil {
+			return true
+		}
+		if entry.Val(AttrLowpc) == nil && entry.Val(AttrRanges) == nil {
+			return true
+		}
+		funcs = append(funcs, entry)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return funcs, nil
+}
+
 // LookupFunction returns the address of the named symbol, a function.
 func (d *Data) LookupFunction(name string) (uint64, error) {
 	entry, err := d.lookupEntry(name, TagSubprogram)
@@ -54,7 +266,47 @@ func (d *Data) LookupFunction(name string) (uint64, error) {
 	if !ok {
 		return 0, fmt.Errorf("symbol %q has non-uint64 LowPC attribute", name)
 	}
-	return addr, nil
+	return addr + d.baseAddr, nil
+}
+
+// A Function describes a subprogram entry.
+type Function struct {
+	Entry      *Entry
+	Name       string
+	LowPC      uint64 // zero if not known
+	HighPC     uint64 // zero if not known
+	IsNoReturn bool   // the function does not return, as with C's exit or abort
+}
+
+// Function returns a description of the subprogram entry at off.
+func (d *Data) Function(off Offset) (*Function, error) {
+	r := d.Reader()
+	r.Seek(off)
+	e, err := r.Next()
+	if err != nil {
+		return nil, err
+	}
+	if e == nil || e.Offset != off || e.Tag != TagSubprogram {
+		return nil, fmt.Errorf("no subprogram at offset %#x", off)
+	}
+	f := &Function{Entry: e}
+	f.Name, _ = e.Val(AttrName).(string)
+	if lowpc, highpc, ok := lowHighPC(e); ok {
+		f.LowPC = lowpc + d.baseAddr
+		f.HighPC = highpc + d.baseAddr
+	}
+	f.IsNoReturn, _ = e.Val(AttrNoreturn).(bool)
+	return f, nil
+}
+
+// IsNoReturn reports whether the subprogram entry at off is marked with
+// DW_AT_noreturn, as for functions declared [[noreturn]] in C11.
+func (d *Data) IsNoReturn(subprogramOff Offset) (bool, error) {
+	f, err := d.Function(subprogramOff)
+	if err != nil {
+		return false, err
+	}
+	return f.IsNoReturn, nil
 }
 
 // TODO: should LookupVariable handle both globals and locals? Locals don't
@@ -74,27 +326,94 @@ func (d *Data) LookupVariable(name string) (uint64, error) {
 	return loc, nil
 }
 
+// A ConstantEntry represents a DW_TAG_constant entry, or any other entry whose
+// value is given directly by a DW_AT_const_value attribute rather than by a
+// location in memory.
+type ConstantEntry struct {
+	*Entry
+	Name string
+	Type Type // the declared type of the constant, or *VoidType if none.
+}
+
+// Constant returns the ConstantEntry for e, which must have a
+// DW_AT_const_value attribute.
+func (d *Data) Constant(e *Entry) (*ConstantEntry, error) {
+	if e.Val(AttrConstValue) == nil {
+		return nil, fmt.Errorf("DWARF entry has no ConstValue attribute")
+	}
+	c := &ConstantEntry{Entry: e}
+	c.Name, _ = e.Val(AttrName).(string)
+	t, err := e.TypeAt(d)
+	if err != nil {
+		return nil, err
+	}
+	c.Type = t
+	return c, nil
+}
+
+// LookupConstant returns the ConstantEntry for the named constant.
+func (d *Data) LookupConstant(name string) (*ConstantEntry, error) {
+	entry, err := d.lookupEntry(name, TagConstant)
+	if err != nil {
+		return nil, fmt.Errorf("constant %s: %s", name, err)
+	}
+	return d.Constant(entry)
+}
+
+// Int returns the value of an integer constant.  The DW_FORM_data forms do
+// not record signedness, so the value is sign-extended if the constant's type,
+// after typedefs and qualifiers, is a signed integer narrower than 64 bits.
+func (c *ConstantEntry) Int() (int64, bool) {
+	v, ok := c.Val(AttrConstValue).(int64)
+	if !ok {
+		return 0, false
+	}
+	t := c.Type
+	for visited := make(map[Type]bool); !visited[t]; {
+		visited[t] = true
+		if td, ok := t.(*TypedefType); ok {
+			t = td.Type
+		} else if q, ok := t.(*QualType); ok {
+			t = q.Type
+		}
+	}
+	switch t := t.(type) {
+	case *IntType, *CharType:
+		if n := uint(t.Size()); 0 < n && n < 8 {
+			shift := 64 - 8*n
+			v = v << shift >> shift
+		}
+	}
+	return v, true
+}
+
+// Bytes returns the value of a constant encoded as a block, such as a
+// DW_FORM_block, DW_FORM_block1, etc.
+func (c *ConstantEntry) Bytes() ([]byte, bool) {
+	v, ok := c.Val(AttrConstValue).([]byte)
+	return v, ok
+}
+
+// StringValue returns the value of a constant encoded as a string.
+func (c *ConstantEntry) StringValue() (string, bool) {
+	v, ok := c.Val(AttrConstValue).(string)
+	return v, ok
+}
+
 // EntryLocation returns the address of the object referred to by the given Entry.
 func (d *Data) EntryLocation(e *Entry) (uint64, error) {
 	loc, _ := e.Val(AttrLocation).([]byte)
 	if len(loc) == 0 {
 		return 0, fmt.Errorf("DWARF entry has no Location attribute")
 	}
-	// TODO: implement the DWARF Location bytecode. What we have here only
-	// recognizes a program with a single literal opAddr bytecode.
-	if asize := d.unit[0].asize; loc[0] == opAddr && len(loc) == 1+asize {
-		switch asize {
-		case 1:
-			return uint64(loc[1]), nil
-		case 2:
-			return uint64(d.order.Uint16(loc[1:])), nil
-		case 4:
-			return uint64(d.order.Uint32(loc[1:])), nil
-		case 8:
-			return d.order.Uint64(loc[1:]), nil
-		}
+	l, err := d.EvalLocation(e, loc)
+	if err != nil {
+		return 0, err
 	}
-	return 0, fmt.Errorf("DWARF entry has an unimplemented Location op")
+	if l.Kind != LocationAddr {
+		return 0, fmt.Errorf("DWARF entry has no address")
+	}
+	return l.Addr, nil
 }
 
 // EntryTypeOffset returns the offset in the given Entry's type attribute.
@@ -110,46 +429,109 @@ func (d *Data) EntryTypeOffset(e *Entry) (Offset, error) {
 	return off, nil
 }
 
-// LookupPC returns the name of a symbol at the specified PC.
+// LookupPC returns the name of the function whose code contains the
+// specified PC.
 func (d *Data) LookupPC(pc uint64) (string, error) {
 	entry, _, err := d.EntryForPC(pc)
 	if err != nil {
 		return "", err
 	}
-	nameAttr := entry.Val(AttrName)
-	if nameAttr == nil {
-		// TODO: this shouldn't be possible.
-		return "", fmt.Errorf("LookupPC: TODO")
-	}
-	name, ok := nameAttr.(string)
+	name, ok := d.funcName(entry)
 	if !ok {
-		return "", fmt.Errorf("name for PC %#x is not a string", pc)
+		return "", fmt.Errorf("function at PC %#x has no name", pc)
 	}
 	return name, nil
 }
 
-// EntryForPC returns the entry and address for a symbol at the specified PC.
-func (d *Data) EntryForPC(pc uint64) (entry *Entry, lowpc uint64, err error) {
-	// TODO: do something better than a linear scan?
-	r := d.Reader()
-	for {
-		entry, err := r.Next()
-		if err != nil {
-			return nil, 0, err
+// funcName returns the name of the subprogram or inlined subroutine entry
+// e. The name of the out-of-line copy of an inlined function, or of the
+// definition of a C++ member function, is that of the entry that its
+// DW_AT_abstract_origin or DW_AT_specification refers to.
+func (d *Data) funcName(e *Entry) (string, bool) {
+	// Follow at most a few references, in case they form a cycle.
+	for i := 0; i < 4 && e != nil; i++ {
+		if name, ok := e.Val(AttrName).(string); ok {
+			return name, true
+		}
+		off, ok := e.Val(AttrAbstractOrigin).(Offset)
+		if !ok {
+			off, ok = e.Val(AttrSpecification).(Offset)
 		}
-		if entry == nil {
-			// TODO: why don't we get an error here.
+		if !ok {
 			break
 		}
-		if entry.Tag != TagSubprogram {
-			continue
+		r := d.Reader()
+		r.Seek(off)
+		e, _ = r.Next()
+	}
+	return "", false
+}
+
+// funcAtPC returns the function whose code contains pc.
+func (d *Data) funcAtPC(pc uint64) (*pcFunc, error) {
+	idx, err := d.codeIndex()
+	if err != nil {
+		return nil, err
+	}
+	fn := idx.lookup(pc - d.baseAddr)
+	if fn == nil {
+		return nil, fmt.Errorf("PC %#x not found", pc)
+	}
+	return fn, nil
+}
+
+// FuncNameAtPC returns the name of the function whose code contains pc.
+// It is the same as LookupPC.
+func (d *Data) FuncNameAtPC(pc uint64) (string, error) {
+	return d.LookupPC(pc)
+}
+
+// EntryForPC returns the subprogram entry of the function whose code
+// contains the specified PC, and the address of the function's first
+// instruction. The code of a function is given by its DW_AT_low_pc and
+// DW_AT_high_pc attributes or by its DW_AT_ranges attribute. The functions
+// are indexed the first time a PC is looked up.
+func (d *Data) EntryForPC(pc uint64) (entry *Entry, lowpc uint64, err error) {
+	fn, err := d.funcAtPC(pc)
+	if err != nil {
+		return nil, 0, err
+	}
+	return fn.entry, fn.entryPC() + d.baseAddr, nil
+}
+
+// AnnotateDisassembly returns the disassembled instruction instr at pc,
+// preceded by a comment line giving the function and source line it
+// belongs to. If the instruction is part of an inlined call, the comment
+// names the inlined function and the location of the call.
+func (d *Data) AnnotateDisassembly(pc uint64, instr string) (string, error) {
+	f, err := d.funcAtPC(pc)
+	if err != nil {
+		return "", err
+	}
+	fn, ok := d.funcName(f.entry)
+	if !ok {
+		return "", fmt.Errorf("function at PC %#x has no name", pc)
+	}
+	comment := "; " + fn
+	inlined := f.inlinedAt(pc - d.baseAddr)
+	if inlined != nil {
+		if name, ok := d.funcName(inlined); ok {
+			comment = "; " + name
+		}
+	}
+	if b, err := d.lineTable(f.cu); err == nil {
+		if file, line, err := d.pcToLine(b, pc); err == nil {
+			comment += fmt.Sprintf(" %s:%d", file, line)
 		}
-		lowpc, lok := entry.Val(AttrLowpc).(uint64)
-		highpc, hok := entry.Val(AttrHighpc).(uint64)
-		if !lok || !hok || pc < lowpc || highpc <= pc {
-			continue
+	}
+	if inlined != nil {
+		callFile, _ := inlined.Val(AttrCallFile).(int64)
+		callLine, _ := inlined.Val(AttrCallLine).(int64)
+		if file, err := d.lineFileName(f.cu, uint64(callFile)); err == nil {
+			comment += fmt.Sprintf(" (inline from %s:%d)", file, callLine)
+		} else {
+			comment += fmt.Sprintf(" (inline from %s)", fn)
 		}
-		return entry, lowpc, nil
 	}
-	return nil, 0, fmt.Errorf("PC %#x not found", pc)
+	return fmt.Sprintf("%s\n%#x: %s", comment, pc, instr), nil
 }
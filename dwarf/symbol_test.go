@@ -0,0 +1,631 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf_test
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	. "golang.org/x/debug/dwarf"
+)
+
+func TestConstantEntry(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		3, 0x16, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // typedef: name, type
+		4, 0x27, 0, 0x03, 0x08, 0x49, 0x13, 0x1c, 0x0b, 0, 0, // constant: name, type, data1 value
+		5, 0x27, 0, 0x03, 0x08, 0x1c, 0x0a, 0, 0, // constant: name, block1 value
+		6, 0x27, 0, 0x03, 0x08, 0x1c, 0x08, 0, 0, // constant: name, string value
+		0,
+	}
+	info := []byte{
+		74, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                              // address size
+		1,                              // 11: compile unit
+		2, 'i', 'n', 't', '8', 0, 5, 1, // 12: int8
+		2, 'u', 'i', 'n', 't', '8', 0, 8, 1, // 20: uint8
+		3, 'm', 'y', 'i', 'n', 't', 0, 12, 0, 0, 0, // 29: typedef int8 myint
+		4, 'a', 0, 12, 0, 0, 0, 0xff, // 40: int8 a = -1
+		4, 'b', 0, 29, 0, 0, 0, 0xfe, // 48: myint b = -2
+		4, 'c', 0, 20, 0, 0, 0, 0xff, // 56: uint8 c = 255
+		5, 'd', 0, 3, 1, 2, 3, // 64: d = {1, 2, 3}
+		6, 'e', 0, 'h', 'i', 0, // 71: e = "hi"
+		0, // 77: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lookup := func(name string) *ConstantEntry {
+		c, err := d.LookupConstant(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	for _, test := range []struct {
+		name string
+		want int64
+	}{
+		{"a", -1},
+		{"b", -2}, // The typedef is followed to find the signed type.
+		{"c", 255},
+	} {
+		if v, ok := lookup(test.name).Int(); !ok || v != test.want {
+			t.Errorf("%s.Int() = %d, %t, want %d", test.name, v, ok, test.want)
+		}
+	}
+	if _, ok := lookup("a").Bytes(); ok {
+		t.Error("a.Bytes() succeeded for an integer constant")
+	}
+
+	c := lookup("d")
+	if b, ok := c.Bytes(); !ok || !bytes.Equal(b, []byte{1, 2, 3}) {
+		t.Errorf("d.Bytes() = %v, %t, want [1 2 3]", b, ok)
+	}
+	if _, ok := c.Type.(*VoidType); !ok {
+		t.Errorf("d.Type = %v, want void", c.Type)
+	}
+	if _, ok := c.Int(); ok {
+		t.Error("d.Int() succeeded for a block constant")
+	}
+
+	c = lookup("e")
+	if s, ok := c.StringValue(); !ok || s != "hi" {
+		t.Errorf("e.StringValue() = %q, %t, want hi", s, ok)
+	}
+	if _, ok := c.Bytes(); ok {
+		t.Error("e.Bytes() succeeded for a string constant")
+	}
+
+	// An entry without a value is not a constant.
+	r := d.Reader()
+	r.Seek(12)
+	e, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Constant(e); err == nil {
+		t.Error("Constant of a base type entry succeeded")
+	}
+}
+
+func TestRuntimeTypes(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x13, 0, 0x03, 0x08, 0x0b, 0x0b, 0, 0, // struct: name, byte size
+		0,
+	}
+	body := []byte{1}
+	for i, name := range []string{"runtime.g", "runtime.hmap", "runtime.itab", "runtime.hchan"} {
+		body = append(body, 2)
+		body = append(body, name...)
+		body = append(body, 0, byte(8*(i+1)))
+	}
+	body = append(body, 0)
+	info := append([]byte{byte(7 + len(body)), 0, 0, 0, 2, 0, 0, 0, 0, 0, 8}, body...)
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := elfData(t, "testdata/typedef.elf")
+	for _, test := range []struct {
+		name string
+		size int64
+		fn   func(*Data) (*StructType, error)
+	}{
+		{"runtime.g", 8, (*Data).GoroutineType},
+		{"runtime.hmap", 16, (*Data).HmapType},
+		{"runtime.itab", 24, (*Data).ItabType},
+		{"runtime.hchan", 32, (*Data).HchanType},
+	} {
+		st, err := test.fn(d)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if st.StructName != test.name || st.ByteSize != test.size {
+			t.Errorf("got %s of size %d, want %s of size %d", st.StructName, st.ByteSize, test.name, test.size)
+		}
+		// A C program has no Go runtime.
+		if st, err := test.fn(c); err == nil {
+			t.Errorf("%s in typedef.elf: got %s, want an error", test.name, st)
+		}
+	}
+}
+
+func TestFunction(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x2e, 0, 0x03, 0x08, 0x11, 0x01, 0x12, 0x01, 0x87, 0x01, 0x19, 0, 0, // subprogram: name, low pc, high pc, noreturn
+		3, 0x2e, 0, 0x03, 0x08, 0x11, 0x01, 0x12, 0x01, 0, 0, // subprogram: name, low pc, high pc
+		0,
+	}
+	info := []byte{
+		47, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,         // address size
+		1,         // 11: compile unit
+		2, 'f', 0, // 12: f, which doesn't return
+		0x00, 0x10, 0, 0, 0, 0, 0, 0, // low pc
+		0x20, 0x10, 0, 0, 0, 0, 0, 0, // high pc
+		3, 'g', 0, // 31: g
+		0x20, 0x10, 0, 0, 0, 0, 0, 0, // low pc
+		0x40, 0x10, 0, 0, 0, 0, 0, 0, // high pc
+		0, // 50: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		off           Offset
+		name          string
+		lowpc, highpc uint64
+		noReturn      bool
+	}{
+		{12, "f", 0x1000, 0x1020, true},
+		{31, "g", 0x1020, 0x1040, false},
+	} {
+		f, err := d.Function(test.off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.Name != test.name || f.LowPC != test.lowpc || f.HighPC != test.highpc || f.IsNoReturn != test.noReturn {
+			t.Errorf("got %+v, want %s [%#x, %#x) with IsNoReturn %t", *f, test.name, test.lowpc, test.highpc, test.noReturn)
+		}
+		if noReturn, err := d.IsNoReturn(test.off); err != nil || noReturn != test.noReturn {
+			t.Errorf("IsNoReturn(%d) = %t, %v, want %t", test.off, noReturn, err, test.noReturn)
+		}
+	}
+	if _, err := d.Function(11); err == nil {
+		t.Error("Function of a compile unit succeeded")
+	}
+	if _, err := d.IsNoReturn(11); err == nil {
+		t.Error("IsNoReturn of a compile unit succeeded")
+	}
+
+	// In DWARF 4, the high PC may be an offset from the low PC.
+	d = elfData(t, "testdata/callsite.elf")
+	pc, err := d.LookupFunction("add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _, err := d.EntryForPC(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := d.Function(e.Offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "add" || f.LowPC != pc || f.HighPC <= f.LowPC {
+		t.Errorf("got %+v, want add starting at %#x", *f, pc)
+	}
+}
+
+func TestClassHierarchy(t *testing.T) {
+	d := elfData(t, "testdata/class.elf")
+	h, err := d.ClassHierarchy("Derived")
+	if err != nil {
+		t.Fatal("ClassHierarchy:", err)
+	}
+	var names []string
+	for _, st := range h {
+		names = append(names, st.StructName)
+	}
+	want := []string{"Derived", "Left", "Base", "Right"}
+	if len(names) != len(want) {
+		t.Fatalf("ClassHierarchy(Derived) = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("ClassHierarchy(Derived) = %v, want %v", names, want)
+		}
+	}
+	if b := h[0].Bases; len(b) != 2 || b[0].ByteOffset != 0 || b[1].ByteOffset != 8 {
+		t.Errorf("bases of Derived: got %d bases, want Left@0 and Right@8", len(b))
+	}
+
+	if _, err := d.ClassHierarchy("NoSuchClass"); err == nil {
+		t.Error("ClassHierarchy(NoSuchClass) succeeded, want error")
+	}
+}
+
+func TestTypeUsageCount(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	count, err := d.TypeUsageCount()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"*t_my_list", "*t_my_tree", "**t_ptr_const_char"} {
+		if count[name] != 1 {
+			t.Errorf("TypeUsageCount()[%q] = %d, want 1", name, count[name])
+		}
+	}
+
+	// An error reading the info section is returned.
+	abbrev := []byte{1, 0x11, 1, 0, 0, 0} // compile unit, with children
+	info := []byte{
+		9, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8, // address size
+		1, // compile unit
+		9, // no such abbreviation
+	}
+	d, err = New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.TypeUsageCount(); err == nil {
+		t.Error("TypeUsageCount with bad info section: got no error")
+	}
+}
+
+func TestAllFunctions(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	funcs, err := d.AllFunctions()
+	if err != nil {
+		t.Fatal("AllFunctions:", err)
+	}
+	if len(funcs) != 1 {
+		t.Fatalf("AllFunctions returned %d functions, want 1", len(funcs))
+	}
+	if name, _ := funcs[0].Val(AttrName).(string); name != "main" {
+		t.Errorf("AllFunctions returned %q, want main", name)
+	}
+}
+
+func TestAllFunctionsInlined(t *testing.T) {
+	d := elfData(t, "testdata/inline4.elf")
+	funcs, err := d.AllFunctions()
+	if err != nil {
+		t.Fatal("AllFunctions:", err)
+	}
+	var names []string
+	for _, e := range funcs {
+		name, _ := e.Val(AttrName).(string)
+		if origin, ok := e.Val(AttrAbstractOrigin).(Offset); ok {
+			r := d.Reader()
+			r.Seek(origin)
+			oe, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, _ = oe.Val(AttrName).(string)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	// store is only ever inlined, but twice also has an out-of-line copy.
+	want := []string{"caller", "checked", "fail", "main", "twice"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("AllFunctions returned %v, want %v", names, want)
+	}
+}
+
+func TestTypesWithMethod(t *testing.T) {
+	d := elfData(t, "testdata/class.elf")
+	types, err := d.TypesWithMethod("Area")
+	if err != nil {
+		t.Fatal("TypesWithMethod:", err)
+	}
+	var names []string
+	for _, st := range types {
+		names = append(names, st.StructName)
+		for _, m := range st.Methods {
+			if m.Name == "Area" && !m.Virtual {
+				t.Errorf("%s.Area is not virtual", st.StructName)
+			}
+		}
+	}
+	sort.Strings(names)
+	if len(names) != 2 || names[0] != "Circle" || names[1] != "Shape" {
+		t.Errorf("TypesWithMethod(Area) = %v, want [Circle Shape]", names)
+	}
+}
+
+func TestLookupTypedef(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	tt, err := d.LookupTypedef("t_my_struct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tt.Name != "t_my_struct" {
+		t.Errorf("got typedef %s, want t_my_struct", tt.Name)
+	}
+	if st, ok := tt.Type.(*StructType); !ok || st.StructName != "my_struct" {
+		t.Errorf("t_my_struct names %s, want struct my_struct", tt.Type)
+	}
+
+	// my_struct is a struct tag, not a typedef.
+	if _, err := d.LookupTypedef("my_struct"); err == nil {
+		t.Error(`LookupTypedef("my_struct"): got no error`)
+	}
+}
+
+func TestLookupType(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	for _, test := range []struct {
+		name, want string
+	}{
+		{"t_my_list", "t_my_list"},
+		{"my_struct", "struct my_struct"},
+		{"my_union", "union my_union"},
+	} {
+		typ, err := d.LookupType(test.name)
+		if err != nil {
+			t.Errorf("LookupType(%q): %v", test.name, err)
+			continue
+		}
+		if got := typ.String(); got != test.want {
+			t.Errorf("LookupType(%q) = %s, want %s", test.name, got, test.want)
+		}
+		again, err := d.LookupType(test.name)
+		if err != nil || again != typ {
+			t.Errorf("second LookupType(%q) = %v, %v, want the cached %v", test.name, again, err, typ)
+		}
+	}
+	if _, err := d.LookupType("no_such_type"); err == nil {
+		t.Error(`LookupType("no_such_type"): got no error`)
+	}
+	// Enums are not looked up.
+	if _, err := d.LookupType("my_enum"); err == nil {
+		t.Error(`LookupType("my_enum"): got no error`)
+	}
+}
+
+func TestAnnotateDisassembly(t *testing.T) {
+	d := elfData(t, "testdata/inline.elf")
+	caller, err := d.LookupFunction("caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The second instruction of caller belongs to the inlined call of store.
+	pc := caller + 3
+	got, err := d.AnnotateDisassembly(pc, "MOVL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("; store inline.c:13 (inline from inline.c:17)\n%#x: MOVL", pc); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// The last instruction is not inlined.
+	pc = caller + 0x13
+	got, err = d.AnnotateDisassembly(pc, "RET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("; caller inline.c:19\n%#x: RET", pc); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestLookupPCDWARF4(t *testing.T) {
+	// inline4.elf gives high PCs as offsets from low PCs, and describes
+	// the code of checked, which is split into a hot and a cold part, with
+	// a range list.
+	d := elfData(t, "testdata/inline4.elf")
+	caller, err := d.LookupFunction("caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		pc    uint64
+		instr string
+		want  string
+	}{
+		{caller + 3, "MOVL", "; store inline4.c:13 (inline from inline4.c:23)"},
+		{caller + 0xf, "MOVL", "; twice inline4.c:19 (inline from inline4.c:24)"},
+		{caller + 0x1f, "RET", "; caller inline4.c:26"},
+	} {
+		got, err := d.AnnotateDisassembly(test.pc, test.instr)
+		if err != nil {
+			t.Errorf("AnnotateDisassembly(%#x): %v", test.pc, err)
+			continue
+		}
+		if want := fmt.Sprintf("%s\n%#x: %s", test.want, test.pc, test.instr); got != want {
+			t.Errorf("got  %q\nwant %q", got, want)
+		}
+	}
+
+	// The addresses are those objdump gives for the out-of-line copy of
+	// twice, and for the two parts of checked.
+	const twice, checked, checkedCold = 0x1180, 0x11b0, 0x104b
+	for _, test := range []struct {
+		pc    uint64
+		fn    string
+		entry uint64
+	}{
+		{twice + 6, "twice", twice},
+		{checked + 9, "checked", checked},
+		{checkedCold + 5, "checked", checked},
+	} {
+		if name, err := d.LookupPC(test.pc); err != nil || name != test.fn {
+			t.Errorf("LookupPC(%#x) = %q, %v, want %s", test.pc, name, err, test.fn)
+		}
+		if _, lowpc, err := d.EntryForPC(test.pc); err != nil || lowpc != test.entry {
+			t.Errorf("EntryForPC(%#x) lowpc = %#x, %v, want %#x", test.pc, lowpc, err, test.entry)
+		}
+	}
+	if name, err := d.LookupPC(0x4000); err == nil {
+		t.Errorf("LookupPC(0x4000) = %q, want error", name)
+	}
+}
+
+func TestCallSites(t *testing.T) {
+	d := elfData(t, "testdata/callsite.elf")
+	main, err := d.LookupEntry("main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites, err := d.CallSites(main.Offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sites) != 1 {
+		t.Fatalf("got %d call sites, want 1", len(sites))
+	}
+	cs := sites[0]
+	if cs.CallOrigin == nil || cs.CallOrigin.Val(AttrName) != "add" {
+		t.Errorf("call origin is %v, want add", cs.CallOrigin)
+	}
+	if lowpc, err := d.LookupFunction("main"); err != nil || cs.PC <= lowpc {
+		t.Errorf("call site PC %#x is not after the start of main", cs.PC)
+	}
+	if cs.TailCall {
+		t.Error("call site is a tail call")
+	}
+	if len(cs.Parameters) != 2 {
+		t.Fatalf("got %d parameters, want 2", len(cs.Parameters))
+	}
+	for i, reg := range []byte{0x55, 0x54} { // DW_OP_reg5 (rdi), DW_OP_reg4 (rsi)
+		p := cs.Parameters[i]
+		if len(p.Location) != 1 || p.Location[0] != reg {
+			t.Errorf("parameter %d location is %x, want %x", i, p.Location, reg)
+		}
+		if len(p.Value) == 0 {
+			t.Errorf("parameter %d has no value", i)
+		}
+	}
+
+	add, err := d.LookupEntry("add")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sites, err := d.CallSites(add.Offset); err != nil || len(sites) != 0 {
+		t.Errorf("CallSites(add) = %v, %v, want none", sites, err)
+	}
+	if _, err := d.CallSites(0); err == nil {
+		t.Error("CallSites of a compilation unit: got no error")
+	}
+}
+
+func TestCallSiteTailCall(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x2e, 1, 0x03, 0x08, 0, 0, // subprogram, with children: name
+		3, 0x48, 0, 0x82, 0x01, 0x0c, 0, 0, // call site: tail call flag
+		4, 0x89, 0x82, 0x01, 0, 0x95, 0x42, 0x0c, 0, 0, // GNU call site: GNU tail call flag
+		0,
+	}
+	info := []byte{
+		21, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,         // address size
+		1,         // 11: compile unit
+		2, 'f', 0, // 12: subprogram f
+		3, 0, // call site, not a tail call
+		3, 1, // tail call
+		4, 0, // GNU call site, not a tail call
+		4, 1, // GNU tail call
+		0, 0, // end of f and compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sites, err := d.CallSites(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{false, true, false, true}
+	if len(sites) != len(want) {
+		t.Fatalf("got %d call sites, want %d", len(sites), len(want))
+	}
+	for i, cs := range sites {
+		if cs.TailCall != want[i] {
+			t.Errorf("call site %d: TailCall = %t, want %t", i, cs.TailCall, want[i])
+		}
+	}
+}
+
+func TestFuncNameAtPC(t *testing.T) {
+	for _, test := range []struct {
+		file string
+		fn   string
+	}{
+		{"testdata/inline.elf", "caller"},
+		{"testdata/callsite.elf", "add"}, // DWARF 4, with an offset for the high PC
+		{"testdata/callsite.elf", "main"},
+	} {
+		d := elfData(t, test.file)
+		pc, err := d.LookupFunction(test.fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, pc := range []uint64{pc, pc + 1} {
+			if name, err := d.FuncNameAtPC(pc); err != nil || name != test.fn {
+				t.Errorf("%s: FuncNameAtPC(%#x) = %q, %v, want %s", test.file, pc, name, err, test.fn)
+			}
+			if name, err := d.LookupPC(pc); err != nil || name != test.fn {
+				t.Errorf("%s: LookupPC(%#x) = %q, %v, want %s", test.file, pc, name, err, test.fn)
+			}
+		}
+	}
+	if name, err := elfData(t, "testdata/inline.elf").FuncNameAtPC(0); err == nil {
+		t.Errorf("FuncNameAtPC(0) = %q, want error", name)
+	}
+}
+
+func TestBaseAddress(t *testing.T) {
+	const base = 0x7f0000000000
+	d := elfData(t, "testdata/inline.elf")
+	caller, err := d.LookupFunction("caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, line, err := d.PCToLine(caller + 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	annotated, err := d.AnnotateDisassembly(caller+3, "MOVL")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d.SetBaseAddress(base)
+	if got := d.BaseAddress(); got != base {
+		t.Errorf("BaseAddress() = %#x, want %#x", got, base)
+	}
+	pc, err := d.LookupFunction("caller")
+	if err != nil || pc != caller+base {
+		t.Errorf("LookupFunction(caller) = %#x, %v, want %#x", pc, err, caller+base)
+	}
+	if name, err := d.LookupPC(pc + 3); err != nil || name != "caller" {
+		t.Errorf("LookupPC(%#x) = %q, %v, want caller", pc+3, name, err)
+	}
+	if _, lowpc, err := d.EntryForPC(pc + 3); err != nil || lowpc != pc {
+		t.Errorf("EntryForPC(%#x) lowpc = %#x, %v, want %#x", pc+3, lowpc, err, pc)
+	}
+	if f, l, err := d.PCToLine(pc + 3); err != nil || f != file || l != line {
+		t.Errorf("PCToLine(%#x) = %s:%d, %v, want %s:%d", pc+3, f, l, err, file, line)
+	}
+	if _, _, err := d.PCToLine(caller + 3); err == nil {
+		t.Errorf("PCToLine of unshifted PC %#x: got no error", caller+3)
+	}
+	want := strings.Replace(annotated, fmt.Sprintf("%#x", caller+3), fmt.Sprintf("%#x", pc+3), 1)
+	if got, err := d.AnnotateDisassembly(pc+3, "MOVL"); err != nil || got != want {
+		t.Errorf("AnnotateDisassembly = %q, %v, want %q", got, err, want)
+	}
+
+	d = elfData(t, "testdata/enum.elf")
+	addr, err := d.LookupVariable("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetBaseAddress(base)
+	if got, err := d.LookupVariable("c"); err != nil || got != addr+base {
+		t.Errorf("LookupVariable(c) = %#x, %v, want %#x", got, err, addr+base)
+	}
+}
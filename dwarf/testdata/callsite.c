@@ -0,0 +1,12 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Linux ELF:
+gcc -gdwarf-4 -O2 -m64 -o callsite.elf callsite.c
+*/
+
+__attribute__((noinline)) int add(int a, int b) { return a + b; }
+
+int main(void) { return add(3, 4) * 2; }
@@ -0,0 +1,55 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Linux ELF:
+g++ -gdwarf-2 -m64 -o class.elf class.cpp
+*/
+
+struct Base {
+	int b;
+};
+
+struct Left : Base {
+	int l;
+};
+
+struct Right {
+	long r;
+};
+
+class Derived : public Left, public Right {
+public:
+	int d;
+};
+
+Derived derived;
+
+class Shape {
+public:
+	virtual double Area();
+	virtual ~Shape() {}
+};
+
+class Circle : public Shape {
+public:
+	double radius;
+	double Area();
+};
+
+class Square : public Shape {
+public:
+	double side;
+};
+
+double Shape::Area() { return 0; }
+double Circle::Area() { return 3 * radius * radius; }
+
+Circle circle;
+Square square;
+
+int main()
+{
+	return 0;
+}
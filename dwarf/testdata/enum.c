@@ -0,0 +1,16 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Linux ELF:
+gcc -gdwarf-2 -m64 -o enum.elf enum.c
+*/
+
+typedef enum { RED, GREEN, BLUE } color;
+typedef enum shape { CIRCLE, SQUARE } t_shape;
+
+color c = GREEN;
+t_shape s = SQUARE;
+
+int main(void) { return 0; }
@@ -0,0 +1,24 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Linux ELF:
+gcc -gdwarf-2 -m64 -O2 -o inline.elf inline.c
+*/
+
+volatile int sink;
+
+static inline __attribute__((always_inline)) void store(int x) {
+	sink = x * 3;
+}
+
+__attribute__((noinline)) void caller(int x) {
+	store(x);
+	sink = 0;
+}
+
+int main(void) {
+	caller(7);
+	return 0;
+}
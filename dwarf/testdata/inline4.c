@@ -0,0 +1,49 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+/*
+Linux ELF:
+gcc -gdwarf-4 -m64 -O2 -o inline4.elf inline4.c
+*/
+
+volatile int sink;
+
+static inline __attribute__((always_inline)) void store(int x) {
+	sink = x * 3;
+}
+
+// twice is inlined into caller and also has an out-of-line copy, for fp.
+static void twice(int x) {
+	sink = x;
+	sink = x;
+}
+
+__attribute__((noinline)) void caller(int x) {
+	store(x);
+	twice(x);
+	sink = 0;
+}
+
+void (*fp)(int) = twice;
+
+__attribute__((noinline, cold)) void fail(void) {
+	sink = -1;
+}
+
+// checked is split into a hot and a cold part, so its code is described by
+// DW_AT_ranges.
+__attribute__((noinline)) void checked(int x) {
+	if (__builtin_expect(x == 42, 0)) {
+		fail();
+		sink = x * 5;
+	}
+	sink = x;
+}
+
+int main(void) {
+	caller(7);
+	fp(8);
+	checked(9);
+	return 0;
+}
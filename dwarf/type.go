@@ -9,9 +9,11 @@
 package dwarf
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
 // A Type conventionally represents a pointer to any of the
@@ -30,12 +32,39 @@ type CommonType struct {
 	Name        string       // name that can be used to refer to type
 	ReflectKind reflect.Kind // the reflect kind of the type.
 	Offset      Offset       // the offset at which this type was read
+	PackageName string       // the Go package of the unit defining the type, if known
 }
 
 func (c *CommonType) Common() *CommonType { return c }
 
 func (c *CommonType) Size() int64 { return c.ByteSize }
 
+// FullName returns the name of the type. The types that may be anonymous,
+// and so have no Name, override it to return their String instead, and
+// TypedefType overrides it to name the type at the end of its chain of
+// typedefs.
+func (c *CommonType) FullName() string { return c.Name }
+
+// QualifiedName returns the name of the type with its Go package written
+// as a quoted import path, as in "net/http".Request, so that types of the
+// same name in different packages can be told apart. Types whose names
+// are not in their PackageName, such as C types and the predeclared Go
+// types, are not qualified.
+func (c *CommonType) QualifiedName() string {
+	if c.PackageName == "" || !strings.HasPrefix(c.Name, c.PackageName+".") {
+		return c.Name
+	}
+	return strconv.Quote(c.PackageName) + c.Name[len(c.PackageName):]
+}
+
+// fullName returns the name of t, or its String if it is anonymous.
+func fullName(t Type) string {
+	if name := t.Common().Name; name != "" {
+		return name
+	}
+	return t.String()
+}
+
 // Basic types
 
 // A BasicType holds fields common to all basic types.
@@ -54,6 +83,8 @@ func (t *BasicType) String() string {
 	return "?"
 }
 
+func (t *BasicType) FullName() string { return fullName(t) }
+
 // A CharType represents a signed character type.
 type CharType struct {
 	BasicType
@@ -110,7 +141,16 @@ type QualType struct {
 
 func (t *QualType) String() string { return t.Qual + " " + t.Type.String() }
 
-func (t *QualType) Size() int64 { return t.Type.Size() }
+func (t *QualType) FullName() string { return fullName(t) }
+
+// Size returns the size of the qualified type, or -1 for a qualified void,
+// as for a DW_TAG_const_type with no DW_AT_type.
+func (t *QualType) Size() int64 {
+	if _, ok := t.Type.(*VoidType); ok {
+		return -1
+	}
+	return t.Type.Size()
+}
 
 // An ArrayType represents a fixed size array type.
 type ArrayType struct {
@@ -126,6 +166,21 @@ func (t *ArrayType) String() string {
 
 func (t *ArrayType) Size() int64 { return t.Count * t.Type.Size() }
 
+// IsIncomplete reports whether t is an incomplete array, like char x[],
+// whose number of elements is unknown. Its Count is -1.
+func (t *ArrayType) IsIncomplete() bool { return t.Count < 0 }
+
+// ElementCount returns the number of elements of t, and false if t is an
+// incomplete array.
+func (t *ArrayType) ElementCount() (int64, bool) {
+	if t.IsIncomplete() {
+		return 0, false
+	}
+	return t.Count, true
+}
+
+func (t *ArrayType) FullName() string { return fullName(t) }
+
 // A VoidType represents the C void type.
 type VoidType struct {
 	CommonType
@@ -133,6 +188,8 @@ type VoidType struct {
 
 func (t *VoidType) String() string { return "void" }
 
+func (t *VoidType) FullName() string { return fullName(t) }
+
 // A PtrType represents a pointer type.
 type PtrType struct {
 	CommonType
@@ -141,23 +198,141 @@ type PtrType struct {
 
 func (t *PtrType) String() string { return "*" + t.Type.String() }
 
+func (t *PtrType) FullName() string { return fullName(t) }
+
 // A StructType represents a struct, union, or C++ class type.
 type StructType struct {
 	CommonType
 	StructName string
 	Kind       string // "struct", "union", or "class".
 	Field      []*StructField
-	Incomplete bool // if true, struct, union, class is declared but not defined
+	Bases      []*BaseClass   // C++ base classes, in declaration order.
+	Methods    []*MethodEntry // C++ member functions, in declaration order.
+	Incomplete bool           // if true, struct, union, class is declared but not defined
+
+	// TemplateParam holds the template parameters of an instantiation of
+	// a C++ class template, in declaration order.
+	TemplateParam []*TemplateParam
+}
+
+// A TemplateParam represents a parameter of a C++ template instantiation.
+type TemplateParam struct {
+	Name  string
+	Type  Type        // the type argument, or the type of a value argument
+	Value interface{} // the value argument, or nil for a type parameter
+}
+
+// A MethodEntry represents a member function declared in a C++ class type.
+type MethodEntry struct {
+	Name    string
+	Virtual bool
+	Offset  Offset // the offset of the member function's declaration
+}
+
+// A BaseClass represents a base class of a C++ class type.
+type BaseClass struct {
+	Type       Type
+	ByteOffset int64 // zero for virtual base classes, whose location is dynamic
+	Virtual    bool
 }
 
 // A StructField represents a field in a struct, union, or C++ class type.
 type StructField struct {
-	Name       string
-	Type       Type
-	ByteOffset int64
-	ByteSize   int64
-	BitOffset  int64 // within the ByteSize bytes at ByteOffset
-	BitSize    int64 // zero if not a bit field
+	Name         string
+	Type         Type
+	ByteOffset   int64
+	ByteSize     int64
+	BitOffset    int64 // within the ByteSize bytes at ByteOffset
+	BitSize      int64 // zero if not a bit field
+	IsArtificial bool  // inserted by the compiler, as marked by DW_AT_artificial
+	Embedded     bool  // a Go embedded field, as marked by DW_AT_go_embedded_field
+}
+
+// IsEmbedded reports whether f is an embedded field, whose fields are
+// promoted to the enclosing struct: a Go embedded field, or an anonymous
+// struct or union member in C and C++.
+func (f *StructField) IsEmbedded() bool {
+	if f.Embedded {
+		return true
+	}
+	if f.Name != "" {
+		return false
+	}
+	_, ok := UnwrapTypedef(f.Type).(*StructType)
+	return ok
+}
+
+// FieldByName returns the field of t with the given name.
+// It is an error for t to have no such field or more than one.
+func (t *StructType) FieldByName(name string) (*StructField, error) {
+	var r *StructField
+	for _, f := range t.Field {
+		if f.Name == name {
+			if r != nil {
+				return nil, fmt.Errorf("struct definition repeats field %s", name)
+			}
+			r = f
+		}
+	}
+	if r == nil {
+		return nil, fmt.Errorf("struct field %s missing", name)
+	}
+	return r, nil
+}
+
+// FieldByOffset returns the field of t at the given byte offset.
+// It is an error for t to have no such field or more than one, as for
+// the fields of a union or bit fields sharing a word.
+func (t *StructType) FieldByOffset(byteOffset int64) (*StructField, error) {
+	var r *StructField
+	for _, f := range t.Field {
+		if f.ByteOffset == byteOffset {
+			if r != nil {
+				return nil, fmt.Errorf("struct has more than one field at offset %d", byteOffset)
+			}
+			r = f
+		}
+	}
+	if r == nil {
+		return nil, fmt.Errorf("struct has no field at offset %d", byteOffset)
+	}
+	return r, nil
+}
+
+// AnonymousFields returns the fields of t that have no name, such as
+// anonymous unions and structs in C and C++. Fields that are named but
+// inserted by the compiler are marked by IsArtificial instead.
+func (t *StructType) AnonymousFields() []*StructField {
+	var fields []*StructField
+	for _, f := range t.Field {
+		if f.Name == "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// EmbeddedFields returns the Go embedded fields of t. The compiler names
+// each one after its type.
+func (t *StructType) EmbeddedFields() []*StructField {
+	var fields []*StructField
+	for _, f := range t.Field {
+		if f.Embedded {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// NamedFields returns the fields of t that have a name.
+func (t *StructType) NamedFields() []*StructField {
+	var fields []*StructField
+	for _, f := range t.Field {
+		if f.Name != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
 }
 
 func (t *StructType) String() string {
@@ -192,6 +367,32 @@ func (t *StructType) Defn() string {
 	return s
 }
 
+func (t *StructType) FullName() string { return fullName(t) }
+
+// Size returns the size of t in bytes. If the DWARF gives no size for a
+// complete type, it is the end of the furthest field, so that a
+// zero-length trailing array, such as a C99 flexible array member, adds
+// nothing.
+func (t *StructType) Size() int64 {
+	if t.ByteSize > 0 || t.Incomplete {
+		return t.ByteSize
+	}
+	var size int64
+	for _, f := range t.Field {
+		n := f.ByteSize
+		if n == 0 && f.Type != nil {
+			n = f.Type.Size()
+		}
+		if n < 0 {
+			n = 0
+		}
+		if end := f.ByteOffset + n; end > size {
+			size = end
+		}
+	}
+	return size
+}
+
 // A SliceType represents a Go slice type. It looks like a StructType, describing
 // the runtime-internal structure, with extra fields.
 type SliceType struct {
@@ -231,6 +432,11 @@ func (t *InterfaceType) String() string {
 	return "Interface"
 }
 
+func (t *InterfaceType) FullName() string { return fullName(t) }
+
+// BaseType returns t, rather than the runtime's representation of t.
+func (t *InterfaceType) BaseType() Type { return t }
+
 // An EnumType represents an enumerated type.
 // The only indication of its native integer type is its ByteSize
 // (inside CommonType).
@@ -262,20 +468,60 @@ func (t *EnumType) String() string {
 	return s
 }
 
+func (t *EnumType) FullName() string { return fullName(t) }
+
+// LookupByValue returns the name of the constant of t with value v.
+// If several constants have that value, the first is returned.
+func (t *EnumType) LookupByValue(v int64) (string, bool) {
+	for _, ev := range t.Val {
+		if ev.Val == v {
+			return ev.Name, true
+		}
+	}
+	return "", false
+}
+
+// LookupByName returns the value of the constant of t with the given name.
+func (t *EnumType) LookupByName(name string) (int64, bool) {
+	for _, ev := range t.Val {
+		if ev.Name == name {
+			return ev.Val, true
+		}
+	}
+	return 0, false
+}
+
 // A FuncType represents a function type.
 type FuncType struct {
 	CommonType
 	ReturnType Type
-	ParamType  []Type
+	Params     []Param // parameters, with the names given by DW_AT_name
+
+	// ParamType holds the types of Params.
+	//
+	// Deprecated: Use Params, which also holds the names of the
+	// parameters.
+	ParamType []Type
+}
+
+// A Param represents a parameter of a function type. Its Name is empty
+// if the parameter is anonymous, as for the final ... of a variadic
+// function.
+type Param struct {
+	Name string
+	Type Type
 }
 
 func (t *FuncType) String() string {
 	s := "func("
-	for i, t := range t.ParamType {
+	for i, p := range t.Params {
 		if i > 0 {
 			s += ", "
 		}
-		s += t.String()
+		if p.Name != "" {
+			s += p.Name + " "
+		}
+		s += p.Type.String()
 	}
 	s += ")"
 	if t.ReturnType != nil {
@@ -284,6 +530,30 @@ func (t *FuncType) String() string {
 	return s
 }
 
+func (t *FuncType) FullName() string { return fullName(t) }
+
+// IsVariadic reports whether t takes a variable number of arguments,
+// that is, whether its last parameter is a DotDotDotType.
+func (t *FuncType) IsVariadic() bool {
+	if t == nil || len(t.ParamType) == 0 {
+		return false
+	}
+	_, ok := t.ParamType[len(t.ParamType)-1].(*DotDotDotType)
+	return ok
+}
+
+// FixedParamTypes returns the types of the parameters of t, leaving out
+// the final DotDotDotType of a variadic function.
+func (t *FuncType) FixedParamTypes() []Type {
+	if t == nil {
+		return nil
+	}
+	if t.IsVariadic() {
+		return t.ParamType[:len(t.ParamType)-1]
+	}
+	return t.ParamType
+}
+
 // A DotDotDotType represents the variadic ... function parameter.
 type DotDotDotType struct {
 	CommonType
@@ -291,6 +561,32 @@ type DotDotDotType struct {
 
 func (t *DotDotDotType) String() string { return "..." }
 
+func (t *DotDotDotType) FullName() string { return fullName(t) }
+
+// A NamelistType represents a Fortran NAMELIST group.
+type NamelistType struct {
+	CommonType
+	Items []NamelistItem
+}
+
+// A NamelistItem represents one variable in a NamelistType.
+type NamelistItem struct {
+	Name string
+	Type Type
+}
+
+func (t *NamelistType) String() string {
+	s := "namelist " + t.Name + " {"
+	for i, item := range t.Items {
+		if i > 0 {
+			s += "; "
+		}
+		s += item.Name + " " + item.Type.String()
+	}
+	s += "}"
+	return s
+}
+
 // A TypedefType represents a named type.
 type TypedefType struct {
 	CommonType
@@ -301,6 +597,87 @@ func (t *TypedefType) String() string { return t.Name }
 
 func (t *TypedefType) Size() int64 { return t.Type.Size() }
 
+// BaseType returns Underlying(t), so that the result can be used in a
+// type switch.
+func (t *TypedefType) BaseType() Type { return Underlying(t) }
+
+// FullName returns the name of the type at the end of t's chain of
+// typedefs, so that for
+//
+//	typedef unsigned int __uint32_t;
+//	typedef __uint32_t uint32_t;
+//	typedef uint32_t u32;
+//
+// the full name of u32 is unsigned int. If that type is anonymous, as for
+// typedef struct { int x; } point, it is the name of the innermost
+// typedef in the chain, which is the name the type is known by. The same
+// holds if the innermost typedef has no type.
+func (t *TypedefType) FullName() string {
+	inner := t
+	visited := map[*TypedefType]bool{t: true}
+	for {
+		next, ok := inner.Type.(*TypedefType)
+		if !ok {
+			break
+		}
+		if visited[next] {
+			return inner.Name
+		}
+		visited[next] = true
+		inner = next
+	}
+	if inner.Type == nil {
+		return inner.Name
+	}
+	if name := inner.Type.Common().Name; name != "" {
+		return name
+	}
+	return inner.Name
+}
+
+// UnwrapTypedef returns the type named by t, following chains of
+// typedefs, or t itself if it is not a typedef. If the chain loops, the
+// typedef at which it loops is returned.
+func UnwrapTypedef(t Type) Type {
+	return unwrap(t, false)
+}
+
+// Underlying returns the underlying type of t, in the sense of the Go
+// specification: for a typedef, the first type in its chain of typedefs
+// that is not itself a typedef, and for any other type, t itself. Maps,
+// channels and interfaces are their own underlying types, although they
+// embed a TypedefType for the runtime's representation. Underlying is
+// UnwrapTypedef by the name Go uses; like it, it does not strip
+// qualifiers, for which see UnwrapQual.
+func Underlying(t Type) Type {
+	return unwrap(t, false)
+}
+
+// UnwrapQual is like UnwrapTypedef, but also strips qualifiers such as
+// const and volatile.
+func UnwrapQual(t Type) Type {
+	return unwrap(t, true)
+}
+
+func unwrap(t Type, qual bool) Type {
+	visited := make(map[Type]bool)
+	for !visited[t] {
+		visited[t] = true
+		switch tt := t.(type) {
+		case *TypedefType:
+			t = tt.Type
+		case *QualType:
+			if !qual {
+				return t
+			}
+			t = tt.Type
+		default:
+			return t
+		}
+	}
+	return t
+}
+
 // A MapType represents a Go map type. It looks like a TypedefType, describing
 // the runtime-internal structure, with extra fields.
 type MapType struct {
@@ -316,17 +693,60 @@ func (t *MapType) String() string {
 	return "map[" + t.KeyType.String() + "]" + t.ElemType.String()
 }
 
+func (t *MapType) FullName() string { return fullName(t) }
+
+// BaseType returns t, rather than the runtime's representation of t.
+func (t *MapType) BaseType() Type { return t }
+
 // A ChanType represents a Go channel type.
 type ChanType struct {
 	TypedefType
-	ElemType Type
+	ElemType  Type
+	Direction ChanDir
 }
 
 func (t *ChanType) String() string {
 	if t.Name != "" {
 		return t.Name
 	}
-	return "chan " + t.ElemType.String()
+	return t.Direction.String() + " " + t.ElemType.String()
+}
+
+func (t *ChanType) FullName() string { return fullName(t) }
+
+// BaseType returns t, rather than the runtime's representation of t.
+func (t *ChanType) BaseType() Type { return t }
+
+// A ChanDir represents the direction of a Go channel type.
+// The DWARF generated by the Go compiler records it only in the
+// name of the type, as in "<-chan int".
+type ChanDir int
+
+const (
+	BothDir ChanDir = iota // chan T
+	SendDir                // chan<- T
+	RecvDir                // <-chan T
+)
+
+func (d ChanDir) String() string {
+	switch d {
+	case SendDir:
+		return "chan<-"
+	case RecvDir:
+		return "<-chan"
+	}
+	return "chan"
+}
+
+// chanDir returns the direction of the channel type with the given name.
+func chanDir(name string) ChanDir {
+	switch {
+	case strings.HasPrefix(name, "chan<- "):
+		return SendDir
+	case strings.HasPrefix(name, "<-chan "):
+		return RecvDir
+	}
+	return BothDir
 }
 
 // typeReader is used to read from either the info section or the
@@ -341,11 +761,79 @@ type typeReader interface {
 	AddressSize() int
 }
 
-// Type reads the type at off in the DWARF ``info'' section.
+// Type reads the type at off in the DWARF “info” section.
 func (d *Data) Type(off Offset) (Type, error) {
+	d.typeMu.RLock()
+	t, ok := d.typeCache[off]
+	d.typeMu.RUnlock()
+	if ok {
+		return t, nil
+	}
+	// Decoding a type can add any number of the types it refers to to
+	// the cache, so it is done under the write lock. readType calls
+	// itself, not Type, for those types.
+	d.typeMu.Lock()
+	defer d.typeMu.Unlock()
 	return d.readType("info", d.Reader(), off, d.typeCache)
 }
 
+// typeTags are the tags of the entries that describe types.
+var typeTags = map[Tag]bool{
+	TagArrayType:       true,
+	TagBaseType:        true,
+	TagClassType:       true,
+	TagStructType:      true,
+	TagUnionType:       true,
+	TagConstType:       true,
+	TagVolatileType:    true,
+	TagRestrictType:    true,
+	TagEnumerationType: true,
+	TagPointerType:     true,
+	TagSubroutineType:  true,
+	TagTypedef:         true,
+	TagUnspecifiedType: true,
+	TagNamelist:        true,
+}
+
+// WalkTypes calls fn for each type in the info section, in the order the
+// types appear, with the offset of the type and either the type or the
+// error that prevented it from being read.  If fn returns a non-nil
+// error, WalkTypes stops and returns that error; fn can skip types that
+// can't be read by returning nil.
+func (d *Data) WalkTypes(fn func(off Offset, t Type, err error) error) error {
+	var fnErr error
+	err := d.IterEntries(func(e *Entry) bool {
+		if !typeTags[e.Tag] {
+			return true
+		}
+		t, err := d.Type(e.Offset)
+		fnErr = fn(e.Offset, t, err)
+		return fnErr == nil
+	})
+	if fnErr != nil {
+		return fnErr
+	}
+	return err
+}
+
+// StripTypes removes from the type cache every type t for which pred(t)
+// returns true.  A subsequent call to Type for a stripped type parses it
+// again from the section data.  Types that remain in the cache may still
+// refer to stripped types.
+func (d *Data) StripTypes(pred func(Type) bool) error {
+	if pred == nil {
+		return errors.New("StripTypes: nil predicate")
+	}
+	d.typeMu.Lock()
+	defer d.typeMu.Unlock()
+	for off, t := range d.typeCache {
+		if pred(t) {
+			delete(d.typeCache, off)
+		}
+	}
+	return nil
+}
+
 func getKind(e *Entry) reflect.Kind {
 	integer, _ := e.Val(AttrGoKind).(int64)
 	return reflect.Kind(integer)
@@ -380,9 +868,9 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 			return nil
 		}
 		// Only return direct children.
-		// Skip over composite entries that happen to be nested
-		// inside this one. Most DWARF generators wouldn't generate
-		// such a thing, but clang does.
+		// Skip over the children of composite entries that happen
+		// to be nested inside this one. Most DWARF generators
+		// wouldn't generate such a thing, but clang does.
 		// See golang.org/issue/6472.
 		for {
 			kid, err1 := r.Next()
@@ -401,10 +889,11 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 				}
 				return nil
 			}
+			depth := nextDepth
 			if kid.Children {
 				nextDepth++
 			}
-			if nextDepth > 0 {
+			if depth > 0 {
 				continue
 			}
 			return kid
@@ -567,6 +1056,20 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 		//		AttrBitOffset: bit offset within bytes for bit fields
 		//		AttrBitSize: bit size for bit fields
 		//		AttrDataMemberLoc: location within struct [required for struct, class]
+		//		AttrArtificial: if true, the member was inserted by the compiler
+		//		AttrGoEmbeddedField: if true, a Go embedded field
+		//	TagInheritance to describe one C++ base class.
+		//		AttrType: type of base class [required]
+		//		AttrDataMemberLoc: location of base class within struct
+		//		AttrVirtuality: if set, a virtual base class
+		//	TagSubprogram to describe one C++ member function.
+		//		AttrName: name of member function
+		//		AttrVirtuality: if set, a virtual function
+		//	TagTemplateTypeParameter and TagTemplateValueParameter
+		//	to describe one C++ template parameter.
+		//		AttrName: name of parameter
+		//		AttrType: the type argument, or type of the value
+		//		AttrConstValue: the value, for a value parameter
 		// There is much more to handle C++, all ignored for now.
 		t := new(StructType)
 		t.ReflectKind = getKind(e)
@@ -644,6 +1147,8 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 				f.ByteSize, _ = kid.Val(AttrByteSize).(int64)
 				f.BitOffset, haveBitOffset = kid.Val(AttrBitOffset).(int64)
 				f.BitSize, _ = kid.Val(AttrBitSize).(int64)
+				f.IsArtificial, _ = kid.Val(AttrArtificial).(bool)
+				f.Embedded, _ = kid.Val(AttrGoEmbeddedField).(bool)
 				t.Field = append(t.Field, f)
 
 				bito := f.BitOffset
@@ -657,6 +1162,42 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 				}
 				lastFieldType = f.Type
 				lastFieldBitOffset = bito
+			} else if kid.Tag == TagInheritance {
+				base := new(BaseClass)
+				if base.Type = typeOf(kid, AttrType); err != nil {
+					goto Error
+				}
+				virtuality, _ := kid.Val(AttrVirtuality).(int64)
+				base.Virtual = virtuality != 0
+				switch loc := kid.Val(AttrDataMemberLoc).(type) {
+				case []byte:
+					// The location of a virtual base class is computed at
+					// run time, so only the simple form is decoded here.
+					if len(loc) > 0 && loc[0] == opPlusUconst {
+						b := makeBuf(d, unknownFormat{}, "location", 0, loc[1:])
+						base.ByteOffset = int64(b.uint())
+					}
+				case int64:
+					base.ByteOffset = loc
+				}
+				t.Bases = append(t.Bases, base)
+			} else if kid.Tag == TagSubprogram {
+				m := new(MethodEntry)
+				m.Name, _ = kid.Val(AttrName).(string)
+				virtuality, _ := kid.Val(AttrVirtuality).(int64)
+				m.Virtual = virtuality != 0
+				m.Offset = kid.Offset
+				t.Methods = append(t.Methods, m)
+			} else if kid.Tag == TagTemplateTypeParameter || kid.Tag == TagTemplateValueParameter {
+				p := new(TemplateParam)
+				if p.Type = typeOf(kid, AttrType); err != nil {
+					goto Error
+				}
+				p.Name, _ = kid.Val(AttrName).(string)
+				if kid.Tag == TagTemplateValueParameter {
+					p.Value = kid.Val(AttrConstValue)
+				}
+				t.TemplateParam = append(t.TemplateParam, p)
 			}
 		}
 		if t.Kind != "union" {
@@ -666,6 +1207,15 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 				zeroArray(lastFieldType)
 			}
 		}
+		if slice, ok := typ.(*SliceType); ok && e.Val(AttrGoElem) == nil {
+			// Binaries from before Go 1.5 may lack AttrGoElem; use the
+			// type pointed to by the array field instead.
+			for _, f := range t.Field {
+				if pt, ok := f.Type.(*PtrType); ok && f.Name == "array" {
+					slice.ElemType = pt.Type
+				}
+			}
+		}
 
 	case TagConstType, TagVolatileType, TagRestrictType:
 		// Type modifier (DWARF v2 §5.2)
@@ -742,6 +1292,7 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 		// Children:
 		//	TagFormalParameter: typed parameter
 		//		AttrType: type of parameter
+		//		AttrName: name of parameter, if any
 		//	TagUnspecifiedParameter: final ...
 		t := new(FuncType)
 		t.ReflectKind = getKind(e)
@@ -752,18 +1303,20 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 		}
 		t.ParamType = make([]Type, 0, 8)
 		for kid := next(); kid != nil; kid = next() {
-			var tkid Type
+			var param Param
 			switch kid.Tag {
 			default:
 				continue
 			case TagFormalParameter:
-				if tkid = typeOf(kid, AttrType); err != nil {
+				if param.Type = typeOf(kid, AttrType); err != nil {
 					goto Error
 				}
+				param.Name, _ = kid.Val(AttrName).(string)
 			case TagUnspecifiedParameters:
-				tkid = &DotDotDotType{}
+				param.Type = &DotDotDotType{}
 			}
-			t.ParamType = append(t.ParamType, tkid)
+			t.Params = append(t.Params, param)
+			t.ParamType = append(t.ParamType, param.Type)
 		}
 
 	case TagTypedef:
@@ -798,6 +1351,17 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 		typeCache[off] = typ
 		t.Name, _ = e.Val(AttrName).(string)
 		t.Type = typeOf(e, AttrType)
+		if c, ok := typ.(*ChanType); ok {
+			c.Direction = chanDir(t.Name)
+		}
+		if et, ok := t.Type.(*EnumType); ok && et.EnumName == "" {
+			// typedef enum { ... } name;
+			// The enum may already have been returned by Type, so
+			// name a copy of it rather than changing it.
+			named := *et
+			named.EnumName = t.Name
+			t.Type = &named
+		}
 
 	case TagUnspecifiedType:
 		// Unspecified type (DWARF v3 §5.2)
@@ -807,6 +1371,49 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 		typ = t
 		typeCache[off] = t
 		t.Name, _ = e.Val(AttrName).(string)
+
+	case TagNamelist:
+		// Fortran namelist (DWARF v3 §4.2)
+		// Attributes:
+		//	AttrName: name of namelist
+		// Children:
+		//	TagNamelistItem: one item in the namelist
+		//		AttrNamelistItem: the variable entry for the item
+		t := new(NamelistType)
+		typ = t
+		typeCache[off] = t
+		t.Name, _ = e.Val(AttrName).(string)
+		for kid := next(); kid != nil; kid = next() {
+			if kid.Tag != TagNamelistItem {
+				continue
+			}
+			voff, ok := kid.Val(AttrNamelistItem).(Offset)
+			if !ok {
+				err = DecodeError{name, kid.Offset, "namelist item has no variable"}
+				goto Error
+			}
+			vr := r.clone()
+			vr.Seek(voff)
+			v, err1 := vr.Next()
+			if err1 != nil {
+				err = err1
+				goto Error
+			}
+			if v == nil || v.Offset != voff {
+				err = DecodeError{name, kid.Offset, "no variable for namelist item"}
+				goto Error
+			}
+			var item NamelistItem
+			item.Name, _ = v.Val(AttrName).(string)
+			if item.Type = typeOf(v, AttrType); err != nil {
+				goto Error
+			}
+			t.Items = append(t.Items, item)
+		}
+
+	default:
+		err = DecodeError{name, off, "unsupported type tag " + e.Tag.String()}
+		goto Error
 	}
 
 	if err != nil {
@@ -814,6 +1421,9 @@ func (d *Data) readType(name string, r typeReader, off Offset, typeCache map[Off
 	}
 
 	typ.Common().Offset = off
+	if name == "info" {
+		typ.Common().PackageName = d.packageName(off)
+	}
 
 	{
 		b, ok := e.Val(AttrByteSize).(int64)
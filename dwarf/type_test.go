@@ -5,6 +5,12 @@
 package dwarf_test
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	. "golang.org/x/debug/dwarf"
@@ -76,6 +82,193 @@ func TestTypedefsMachO(t *testing.T) {
 
 func TestTypedefsELFDwarf4(t *testing.T) { testTypedefs(t, elfData(t, "testdata/typedef.elf4"), "elf") }
 
+func TestChanDir(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		3, 0x16, 0, 0x03, 0x08, 0x49, 0x13, 0x80, 0x52, 0x0b, 0x82, 0x52, 0x13, 0, 0, // typedef: name, type, Go kind, Go elem
+		0,
+	}
+	const intOff = 12
+	const kind = 18 // reflect.Chan
+	info := []byte{
+		77, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                         // address size
+		1,                         // 11: compile unit
+		2, 'i', 'n', 't', 0, 5, 8, // 12: int
+		3, 'c', 'h', 'a', 'n', ' ', 'i', 'n', 't', 0, intOff, 0, 0, 0, kind, intOff, 0, 0, 0, // 19: chan int
+		3, 'c', 'h', 'a', 'n', '<', '-', ' ', 'i', 'n', 't', 0, intOff, 0, 0, 0, kind, intOff, 0, 0, 0, // 38: chan<- int
+		3, '<', '-', 'c', 'h', 'a', 'n', ' ', 'i', 'n', 't', 0, intOff, 0, 0, 0, kind, intOff, 0, 0, 0, // 59: <-chan int
+		0, // 80: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		off Offset
+		dir ChanDir
+		str string
+	}{
+		{19, BothDir, "chan"},
+		{38, SendDir, "chan<-"},
+		{59, RecvDir, "<-chan"},
+	} {
+		typ, err := d.Type(test.off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ct, ok := typ.(*ChanType)
+		if !ok {
+			t.Fatalf("type at %d: got %T, want *ChanType", test.off, typ)
+		}
+		if ct.Direction != test.dir || ct.Direction.String() != test.str {
+			t.Errorf("%s: got direction %d (%s), want %d (%s)", ct, ct.Direction, ct.Direction, test.dir, test.str)
+		}
+		// Without a name, the direction is in the type's String.
+		anon := *ct
+		anon.Name = ""
+		if got, want := anon.String(), test.str+" int"; got != want {
+			t.Errorf("unnamed %s: String() = %q, want %q", ct, got, want)
+		}
+	}
+}
+
+func TestQualTypeSize(t *testing.T) {
+	i := &IntType{BasicType{CommonType: CommonType{Name: "int", ByteSize: 4}}}
+	for _, test := range []struct {
+		t    *QualType
+		want int64
+	}{
+		{&QualType{Qual: "const", Type: i}, 4},
+		{&QualType{Qual: "volatile", Type: &QualType{Qual: "const", Type: i}}, 4},
+		{&QualType{Qual: "const", Type: &VoidType{}}, -1},
+	} {
+		if got := test.t.Size(); got != test.want {
+			t.Errorf("%s: Size() = %d, want %d", test.t, got, test.want)
+		}
+	}
+
+	// A DW_TAG_const_type with no DW_AT_type is a qualified void.
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x26, 0, 0, 0, // const type
+		0,
+	}
+	info := []byte{
+		10, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8, // address size
+		1, // 11: compile unit
+		2, // 12: const void
+		0, // 13: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := d.Type(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := typ.(*QualType); !ok || typ.String() != "const void" || typ.Size() != -1 {
+		t.Errorf("got %T %s of size %d, want const void of size -1", typ, typ, typ.Size())
+	}
+}
+
+func TestNamelist(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		3, 0x34, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // variable: name, type
+		4, 0x2b, 1, 0x03, 0x08, 0, 0, // namelist, with children: name
+		5, 0x2c, 0, 0x44, 0x13, 0, 0, // namelist item: variable
+		0,
+	}
+	info := []byte{
+		60, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                                             // address size
+		1,                                             // 11: compile unit
+		2, 'i', 'n', 't', 'e', 'g', 'e', 'r', 0, 5, 4, // 12: integer
+		3, 'a', 0, 12, 0, 0, 0, // 23: integer a
+		3, 'b', 0, 12, 0, 0, 0, // 30: integer b
+		4, 'n', 'l', 0, // 37: namelist nl
+		5, 23, 0, 0, 0, // 41: a
+		5, 30, 0, 0, 0, // 46: b
+		0,                   // 51: end of nl
+		4, 'b', 'a', 'd', 0, // 52: namelist bad
+		5, 100, 0, 0, 0, // 57: no variable at 100
+		0, // 62: end of bad
+		0, // 63: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := d.Type(37)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nl, ok := typ.(*NamelistType)
+	if !ok {
+		t.Fatalf("got %T, want *NamelistType", typ)
+	}
+	if len(nl.Items) != 2 || nl.Items[0].Name != "a" || nl.Items[1].Name != "b" {
+		t.Fatalf("got items %v, want a and b", nl.Items)
+	}
+	if want := "namelist nl {a integer; b integer}"; nl.String() != want {
+		t.Errorf("got %s, want %s", nl, want)
+	}
+	if typ, err := d.Type(52); err == nil {
+		t.Errorf("namelist with a bad item: got %s, want an error", typ)
+	}
+}
+
+func TestStructMemberWithChildren(t *testing.T) {
+	// A member that has children of its own is still a member of the
+	// struct; only its children are skipped.
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		3, 0x13, 1, 0x03, 0x08, 0x0b, 0x0b, 0, 0, // struct, with children: name, byte size
+		4, 0x0d, 1, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0, 0, // member, with children: name, type, location
+		5, 0x0d, 0, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0, 0, // member: name, type, location
+		6, 0x18, 0, 0, 0, // unspecified parameters
+		0,
+	}
+	info := []byte{
+		39, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                         // address size
+		1,                         // 11: compile unit
+		2, 'i', 'n', 't', 0, 5, 4, // 12: int
+		3, 's', 0, 8, // 19: struct s
+		4, 'a', 0, 12, 0, 0, 0, 0, // 23: member a, with children
+		6,                         // 31: child of a
+		0,                         // 32: end of a
+		5, 'b', 0, 12, 0, 0, 0, 4, // 33: member b
+		0, // 41: end of s
+		0, // 42: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := d.Type(19)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct s {a int@0; b int@4}"; typ.(*StructType).Defn() != want {
+		t.Errorf("got %s, want %s", typ.(*StructType).Defn(), want)
+	}
+}
+
 func testTypedefs(t *testing.T, d *Data, kind string) {
 	r := d.Reader()
 	seen := make(map[string]bool)
@@ -121,3 +314,1045 @@ func testTypedefs(t *testing.T, d *Data, kind string) {
 		}
 	}
 }
+
+func TestStripTypes(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	e, err := d.LookupEntry("t_my_tree")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t1, err := d.Type(e.Offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t2, _ := d.Type(e.Offset); t2 != t1 {
+		t.Fatal("Type did not return the cached type")
+	}
+	err = d.StripTypes(func(t Type) bool {
+		_, ok := t.(*TypedefType)
+		return ok
+	})
+	if err != nil {
+		t.Fatal("StripTypes:", err)
+	}
+	t3, err := d.Type(e.Offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if t3 == t1 {
+		t.Error("Type returned a stripped type")
+	}
+	if t3.String() != t1.String() {
+		t.Errorf("reparsed type is %s, want %s", t3, t1)
+	}
+}
+
+func TestAnonymousEnumTypedef(t *testing.T) {
+	d := elfData(t, "testdata/enum.elf")
+	for name, want := range map[string]string{
+		"color":   "enum color {RED=0; GREEN=1; BLUE=2}",
+		"t_shape": "enum shape {CIRCLE=0; SQUARE=1}",
+	} {
+		tt, err := d.LookupTypedef(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tt.Type.String(); got != want {
+			t.Errorf("typedef %s: got %s, want %s", name, got, want)
+		}
+	}
+	// The typedef names a copy of the anonymous enum, leaving the enum
+	// that Type returns unchanged.
+	tt, err := d.LookupTypedef("color")
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, err := d.Type(tt.Type.Common().Offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := et.String(), "enum {RED=0; GREEN=1; BLUE=2}"; got != want {
+		t.Errorf("anonymous enum: got %s, want %s", got, want)
+	}
+}
+
+func TestSliceElemTypeWithoutGoElem(t *testing.T) {
+	// The DWARF for a Go slice type with no AttrGoElem attribute, as
+	// written by Go before 1.5.
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x13, 1, 0x03, 0x08, 0x0b, 0x0b, 0x80, 0x52, 0x0b, 0, 0, // struct: name, byte size, Go kind
+		3, 0x0d, 0, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0, 0, // member: name, type, location
+		4, 0x0f, 0, 0x0b, 0x0b, 0x49, 0x13, 0, 0, // pointer: byte size, type
+		5, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		0,
+	}
+	const (
+		sliceOff = 12
+		ptrOff   = 54
+		intOff   = 60
+	)
+	info := []byte{
+		64, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                                                      // address size
+		1,                                                      // 11: compile unit
+		2, '[', ']', 'i', 'n', 't', 0, 24, byte(reflect.Slice), // 12: slice
+		3, 'a', 'r', 'r', 'a', 'y', 0, ptrOff, 0, 0, 0, 0, // 21: array
+		3, 'l', 'e', 'n', 0, intOff, 0, 0, 0, 8, // 33: len
+		3, 'c', 'a', 'p', 0, intOff, 0, 0, 0, 16, // 43: cap
+		0,                     // 53: end of slice
+		4, 8, intOff, 0, 0, 0, // 54: pointer
+		5, 'i', 'n', 't', 0, 5, 8, // 60: int
+		0, // 67: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := d.Type(sliceOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, ok := typ.(*SliceType)
+	if !ok {
+		t.Fatalf("got %T, want *SliceType", typ)
+	}
+	if it, ok := st.ElemType.(*IntType); !ok || it.Name != "int" {
+		t.Errorf("got element type %s, want int", st.ElemType)
+	}
+}
+
+func TestTemplateParams(t *testing.T) {
+	// The DWARF for std::vector<int> and std::array<int, 4>, reduced to
+	// their template parameters and a member.
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x02, 1, 0x03, 0x08, 0x0b, 0x0b, 0, 0, // class: name, byte size
+		3, 0x2f, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // template type parameter: name, type
+		4, 0x30, 0, 0x03, 0x08, 0x49, 0x13, 0x1c, 0x0b, 0, 0, // template value parameter: name, type, value
+		5, 0x0d, 0, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0, 0, // member: name, type, location
+		6, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		0,
+	}
+	const (
+		vectorOff = 12
+		arrayOff  = 50
+		intOff    = 86
+	)
+	info := []byte{
+		90, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                                                              // address size
+		1,                                                              // 11: compile unit
+		2, 'v', 'e', 'c', 't', 'o', 'r', '<', 'i', 'n', 't', '>', 0, 8, // 12: class vector<int>
+		3, '_', 'T', 'p', 0, intOff, 0, 0, 0, // 26: template type parameter _Tp = int
+		5, '_', 'M', '_', 's', 'i', 'z', 'e', 0, intOff, 0, 0, 0, 0, // 35: member _M_size
+		0,                                                                         // 49: end of vector<int>
+		2, 'a', 'r', 'r', 'a', 'y', '<', 'i', 'n', 't', ',', ' ', '4', '>', 0, 16, // 50: class array<int, 4>
+		3, '_', 'T', 'p', 0, intOff, 0, 0, 0, // 66: template type parameter _Tp = int
+		4, '_', 'N', 'm', 0, intOff, 0, 0, 0, 4, // 75: template value parameter _Nm = 4
+		0,                         // 85: end of array<int, 4>
+		6, 'i', 'n', 't', 0, 5, 4, // 86: int
+		0, // 93: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		off    Offset
+		name   string
+		params string
+		fields int
+	}{
+		{vectorOff, "vector<int>", "_Tp int", 1},
+		{arrayOff, "array<int, 4>", "_Tp int, _Nm int = 4", 0},
+	} {
+		typ, err := d.Type(test.off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		st, ok := typ.(*StructType)
+		if !ok || st.StructName != test.name {
+			t.Fatalf("got %s, want class %s", typ, test.name)
+		}
+		var params []string
+		for _, p := range st.TemplateParam {
+			s := p.Name + " " + p.Type.String()
+			if p.Value != nil {
+				s += fmt.Sprintf(" = %v", p.Value)
+			}
+			params = append(params, s)
+		}
+		if got := strings.Join(params, ", "); got != test.params {
+			t.Errorf("%s: got template parameters %q, want %q", test.name, got, test.params)
+		}
+		if len(st.Field) != test.fields {
+			t.Errorf("%s: got %d fields, want %d", test.name, len(st.Field), test.fields)
+		}
+	}
+}
+
+func TestTypeHierarchy(t *testing.T) {
+	d := elfData(t, "testdata/class.elf")
+	h := d.TypeHierarchy()
+	names := func(types []Type) []string {
+		var s []string
+		for _, t := range types {
+			s = append(s, t.String())
+		}
+		sort.Strings(s)
+		return s
+	}
+	hier, err := d.ClassHierarchy("Derived")
+	if err != nil {
+		t.Fatal(err)
+	}
+	children, err := h.Children(hier[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(children), []string{"int", "struct Left", "struct Right"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Children(Derived) = %q, want %q", got, want)
+	}
+	hier, err = d.ClassHierarchy("Shape")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parents, err := h.Parent(hier[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(parents), []string{"class Circle", "class Square"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Parent(Shape) = %q, want %q", got, want)
+	}
+
+	// A struct with two fields of the same type contains it once.
+	d = layoutData(t, 8, layoutField{"a", layoutInt, 0}, layoutField{"b", layoutInt, 4})
+	h = d.TypeHierarchy()
+	s, err := d.Type(layoutStruct)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, err := d.Type(layoutInt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if children, err := h.Children(s); err != nil || len(children) != 1 || children[0] != i {
+		t.Errorf("Children(s) = %v, %v; want [int]", children, err)
+	}
+	if parents, err := h.Parent(i); err != nil || len(parents) != 1 || parents[0] != s {
+		t.Errorf("Parent(int) = %v, %v; want [struct s]", parents, err)
+	}
+
+	// An error reading the info section is returned.
+	abbrev := []byte{1, 0x11, 1, 0, 0, 0} // compile unit, with children
+	info := []byte{
+		9, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8, // address size
+		1, // compile unit
+		9, // no such abbreviation
+	}
+	d, err = New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.TypeHierarchy().Children(s); err == nil {
+		t.Error("Children with bad info section: got no error")
+	}
+}
+
+func TestWalkTypes(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	types := make(map[Offset]Type)
+	err := d.WalkTypes(func(off Offset, typ Type, err error) error {
+		if err != nil {
+			t.Errorf("type at %#x: %v", off, err)
+			return nil
+		}
+		if _, ok := types[off]; ok {
+			t.Errorf("type at %#x visited twice", off)
+		}
+		types[off] = typ
+		return nil
+	})
+	if err != nil {
+		t.Fatal("WalkTypes:", err)
+	}
+	var want int
+	d.IterEntries(func(e *Entry) bool {
+		switch e.Tag {
+		case TagArrayType, TagBaseType, TagClassType, TagStructType, TagUnionType,
+			TagConstType, TagVolatileType, TagRestrictType, TagEnumerationType,
+			TagPointerType, TagSubroutineType, TagTypedef, TagUnspecifiedType:
+			want++
+		}
+		return true
+	})
+	if len(types) == 0 || len(types) != want {
+		t.Errorf("WalkTypes visited %d types, want %d", len(types), want)
+	}
+	for name := range typedefTests {
+		tt, err := d.LookupTypedef(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if types[tt.Offset] != tt {
+			t.Errorf("WalkTypes passed %v for %s, want the cached type", types[tt.Offset], name)
+		}
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = d.WalkTypes(func(Offset, Type, error) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("WalkTypes returned %v after %d calls, want %v after 1", err, n, stop)
+	}
+}
+
+func TestFuncTypeVariadic(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	for _, test := range []struct {
+		typedef  string
+		variadic bool
+		nfixed   int
+	}{
+		{"t_func_void_of_ptr_char_dots", true, 1},
+		{"t_func_int_of_float_double", false, 2},
+		{"t_func_void_of_void", false, 0},
+	} {
+		tt, err := d.LookupTypedef(test.typedef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ft, ok := tt.Type.(*FuncType)
+		if !ok {
+			t.Fatalf("%s is %T, want *FuncType", test.typedef, tt.Type)
+		}
+		if got := ft.IsVariadic(); got != test.variadic {
+			t.Errorf("%s: IsVariadic() = %t, want %t", test.typedef, got, test.variadic)
+		}
+		fixed := ft.FixedParamTypes()
+		if len(fixed) != test.nfixed {
+			t.Errorf("%s: FixedParamTypes() = %v, want %d types", test.typedef, fixed, test.nfixed)
+		}
+		for _, p := range fixed {
+			if _, ok := p.(*DotDotDotType); ok {
+				t.Errorf("%s: FixedParamTypes() includes ...", test.typedef)
+			}
+		}
+	}
+
+	var ft *FuncType
+	if ft.IsVariadic() || ft.FixedParamTypes() != nil {
+		t.Error("nil *FuncType is variadic or has parameters")
+	}
+}
+
+func TestFuncTypeParamNames(t *testing.T) {
+	// The DWARF for func(void *dst, void *src, unsigned long n, ...) *void.
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x15, 1, 0x49, 0x13, 0, 0, // subroutine type: type
+		3, 0x05, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // formal parameter: name, type
+		4, 0x0f, 0, 0x0b, 0x0b, 0, 0, // pointer type: byte size
+		5, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		6, 0x18, 0, 0, 0, // unspecified parameters
+		0,
+	}
+	const (
+		funcOff  = 12
+		ptrOff   = 44
+		ulongOff = 46
+	)
+	info := []byte{
+		52, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                  // address size
+		1,                  // 11: compile unit
+		2, ptrOff, 0, 0, 0, // 12: subroutine type returning *void
+		3, 'd', 's', 't', 0, ptrOff, 0, 0, 0, // 17: dst *void
+		3, 's', 'r', 'c', 0, ptrOff, 0, 0, 0, // 26: src *void
+		3, 'n', 0, ulongOff, 0, 0, 0, // 35: n ulong
+		6,    // 42: ...
+		0,    // 43: end of subroutine type
+		4, 8, // 44: *void
+		5, 'u', 'l', 'o', 'n', 'g', 0, 7, 8, // 46: ulong
+		0, // 55: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := d.Type(funcOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft, ok := typ.(*FuncType)
+	if !ok {
+		t.Fatalf("got %T, want *FuncType", typ)
+	}
+	const want = "func(dst *void, src *void, n ulong, ...) *void"
+	if got := ft.String(); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	var names []string
+	for _, p := range ft.Params {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "dst,src,n," {
+		t.Errorf("got parameter names %q, want %q", got, "dst,src,n,")
+	}
+	if len(ft.ParamType) != len(ft.Params) {
+		t.Errorf("got %d parameter types for %d parameters", len(ft.ParamType), len(ft.Params))
+	}
+}
+
+func TestEnumLookup(t *testing.T) {
+	// enum { A = 1, B = 2, C = 1 }
+	typ := &EnumType{
+		EnumName: "dup",
+		Val: []*EnumValue{
+			{Name: "A", Val: 1},
+			{Name: "B", Val: 2},
+			{Name: "C", Val: 1},
+		},
+	}
+	for _, test := range []struct {
+		val  int64
+		name string
+		ok   bool
+	}{
+		{1, "A", true},
+		{2, "B", true},
+		{3, "", false},
+	} {
+		if name, ok := typ.LookupByValue(test.val); name != test.name || ok != test.ok {
+			t.Errorf("LookupByValue(%d) = %q, %t, want %q, %t", test.val, name, ok, test.name, test.ok)
+		}
+	}
+	for _, test := range []struct {
+		name string
+		val  int64
+		ok   bool
+	}{
+		{"A", 1, true},
+		{"C", 1, true},
+		{"B", 2, true},
+		{"D", 0, false},
+	} {
+		if val, ok := typ.LookupByName(test.name); val != test.val || ok != test.ok {
+			t.Errorf("LookupByName(%q) = %d, %t, want %d, %t", test.name, val, ok, test.val, test.ok)
+		}
+	}
+}
+
+func TestFieldLookup(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	tt, err := d.LookupTypedef("t_my_struct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := tt.Type.(*StructType)
+
+	if f, err := st.FieldByName("array"); err != nil || f.ByteOffset != 8 {
+		t.Errorf(`FieldByName("array") = %v, %v, want field at offset 8`, f, err)
+	}
+	if _, err := st.FieldByName("missing"); err == nil {
+		t.Error(`FieldByName("missing"): got no error`)
+	}
+	dup := &StructType{Kind: "struct", Field: []*StructField{{Name: "a"}, {Name: "a", ByteOffset: 4}}}
+	if _, err := dup.FieldByName("a"); err == nil {
+		t.Error(`FieldByName of a repeated field: got no error`)
+	}
+
+	if f, err := st.FieldByOffset(0); err != nil || f.Name != "vi" {
+		t.Errorf("FieldByOffset(0) = %v, %v, want vi", f, err)
+	}
+	if _, err := st.FieldByOffset(2); err == nil {
+		t.Error("FieldByOffset(2): got no error")
+	}
+	// The bit fields x and y share offset 4.
+	if _, err := st.FieldByOffset(4); err == nil {
+		t.Error("FieldByOffset(4): got no error")
+	}
+}
+
+func TestArtificialField(t *testing.T) {
+	d := elfData(t, "testdata/class.elf")
+	hier, err := d.ClassHierarchy("Shape")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := hier[0].FieldByName("_vptr.Shape")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsArtificial {
+		t.Error("_vptr.Shape is not artificial")
+	}
+	for _, f := range hier[0].Field {
+		if f.Name != "_vptr.Shape" && f.IsArtificial {
+			t.Errorf("field %s is artificial", f.Name)
+		}
+	}
+}
+
+func TestAnonymousFields(t *testing.T) {
+	d := elfData(t, "testdata/class.elf")
+	hier, err := d.ClassHierarchy("Shape")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shape := hier[0]
+	// _vptr.Shape is artificial, but named.
+	if got := shape.AnonymousFields(); len(got) != 0 {
+		t.Errorf("Shape has anonymous fields %v", got)
+	}
+	if got := shape.NamedFields(); len(got) != len(shape.Field) {
+		t.Errorf("Shape has %d named fields, want %d", len(got), len(shape.Field))
+	}
+
+	u := &StructType{CommonType: CommonType{ByteSize: 4}, Kind: "union"}
+	s := &StructType{
+		CommonType: CommonType{ByteSize: 8},
+		StructName: "s",
+		Kind:       "struct",
+		Field: []*StructField{
+			{Name: "a", Type: &IntType{}, ByteOffset: 0},
+			{Type: u, ByteOffset: 4},
+		},
+	}
+	if got := s.AnonymousFields(); len(got) != 1 || got[0] != s.Field[1] {
+		t.Errorf("AnonymousFields() = %v, want the union", got)
+	}
+	if got := s.NamedFields(); len(got) != 1 || got[0] != s.Field[0] {
+		t.Errorf("NamedFields() = %v, want a", got)
+	}
+}
+
+func TestUnwrapTypedef(t *testing.T) {
+	u := &UintType{BasicType{CommonType: CommonType{Name: "unsigned int", ByteSize: 4}}}
+	var chain Type = u
+	for i := 0; i < 5; i++ {
+		chain = &TypedefType{CommonType: CommonType{Name: "t" + strconv.Itoa(i)}, Type: chain}
+	}
+	one := &TypedefType{CommonType: CommonType{Name: "uint32_t"}, Type: u}
+	qual := &TypedefType{CommonType: CommonType{Name: "cuint"}, Type: &QualType{Qual: "const", Type: one}}
+	for _, test := range []struct {
+		t               Type
+		typedef, qualed Type
+	}{
+		{u, u, u},
+		{one, u, u},
+		{chain, u, u},
+		{qual, qual.Type, u},
+	} {
+		if got := UnwrapTypedef(test.t); got != test.typedef {
+			t.Errorf("UnwrapTypedef(%s) = %s, want %s", test.t, got, test.typedef)
+		}
+		if got := UnwrapQual(test.t); got != test.qualed {
+			t.Errorf("UnwrapQual(%s) = %s, want %s", test.t, got, test.qualed)
+		}
+	}
+
+	// a -> const b -> a
+	a := &TypedefType{CommonType: CommonType{Name: "a"}}
+	b := &TypedefType{CommonType: CommonType{Name: "b"}, Type: &QualType{Qual: "const", Type: a}}
+	a.Type = b
+	if got := UnwrapQual(a); got != a {
+		t.Errorf("UnwrapQual of a cycle = %s, want a", got)
+	}
+	if got := UnwrapTypedef(a); got != b.Type {
+		t.Errorf("UnwrapTypedef(a) = %s, want const a", got)
+	}
+}
+
+func TestUnderlying(t *testing.T) {
+	u := &UintType{BasicType{CommonType: CommonType{Name: "unsigned int", ByteSize: 4}}}
+	var chain Type = u
+	for i := 0; i < 3; i++ {
+		chain = &TypedefType{CommonType: CommonType{Name: "t" + strconv.Itoa(i)}, Type: chain}
+	}
+	runtime := &PtrType{Type: &StructType{StructName: "runtime.hmap", Kind: "struct"}}
+	m := &MapType{TypedefType: TypedefType{CommonType: CommonType{Name: "map[int]int"}, Type: runtime}, KeyType: u, ElemType: u}
+	c := &ChanType{TypedefType: TypedefType{CommonType: CommonType{Name: "chan int"}, Type: runtime}, ElemType: u}
+	i := &InterfaceType{TypedefType: TypedefType{CommonType: CommonType{Name: "error"}, Type: runtime}}
+	named := &TypedefType{CommonType: CommonType{Name: "main.M"}, Type: m}
+	for _, test := range []struct {
+		t, want Type
+	}{
+		{u, u},
+		{chain, u},
+		{m, m},
+		{c, c},
+		{i, i},
+		{named, m},
+	} {
+		if got := Underlying(test.t); got != test.want {
+			t.Errorf("Underlying(%s) = %s, want %s", test.t, got, test.want)
+		}
+	}
+
+	// a -> b -> a
+	a := &TypedefType{CommonType: CommonType{Name: "a"}}
+	b := &TypedefType{CommonType: CommonType{Name: "b"}, Type: a}
+	a.Type = b
+	if got := Underlying(a); got != a {
+		t.Errorf("Underlying of a cycle = %s, want a", got)
+	}
+}
+
+func TestBaseType(t *testing.T) {
+	u := &UintType{BasicType{CommonType: CommonType{Name: "unsigned int", ByteSize: 4}}}
+	chain := &TypedefType{CommonType: CommonType{Name: "t0"}, Type: u}
+	for i := 1; i < 5; i++ {
+		chain = &TypedefType{CommonType: CommonType{Name: "t" + strconv.Itoa(i)}, Type: chain}
+	}
+	if got := chain.BaseType(); got != u {
+		t.Errorf("t4.BaseType() = %s, want %s", got, u)
+	}
+
+	runtime := &PtrType{Type: &StructType{StructName: "runtime.hmap", Kind: "struct"}}
+	m := &MapType{TypedefType: TypedefType{CommonType: CommonType{Name: "map[int]int"}, Type: runtime}, KeyType: u, ElemType: u}
+	c := &ChanType{TypedefType: TypedefType{CommonType: CommonType{Name: "chan int"}, Type: runtime}, ElemType: u}
+	i := &InterfaceType{TypedefType: TypedefType{CommonType: CommonType{Name: "error"}, Type: runtime}}
+	for _, want := range []interface {
+		Type
+		BaseType() Type
+	}{m, c, i} {
+		if got := want.BaseType(); got != want {
+			t.Errorf("%s.BaseType() = %s, want %s", want, got, want)
+		}
+		named := &TypedefType{CommonType: CommonType{Name: "main.T"}, Type: &TypedefType{CommonType: CommonType{Name: "main.U"}, Type: want}}
+		if got := named.BaseType(); got != want {
+			t.Errorf("BaseType of a typedef of %s = %s, want %s", want, got, want)
+		}
+	}
+}
+
+func TestFullName(t *testing.T) {
+	u := &UintType{BasicType{CommonType: CommonType{Name: "unsigned int", ByteSize: 4}}}
+	typedef := func(name string, t Type) *TypedefType {
+		return &TypedefType{CommonType: CommonType{Name: name}, Type: t}
+	}
+	uint32 := typedef("__uint32_t", u)
+	stdUint32 := typedef("uint32_t", uint32)
+	u32 := typedef("u32", stdUint32)
+	anon := &StructType{
+		CommonType: CommonType{ByteSize: 4},
+		Kind:       "struct",
+		Field:      []*StructField{{Name: "x", Type: u}},
+	}
+	point := typedef("point", anon)
+	for _, test := range []struct {
+		t    *TypedefType
+		want string
+	}{
+		{uint32, "unsigned int"},
+		{stdUint32, "unsigned int"},
+		{u32, "unsigned int"},
+		{point, "point"},
+		{typedef("p", point), "point"},
+		{typedef("cp", &QualType{Qual: "const", Type: anon}), "cp"},
+		{typedef("opaque", nil), "opaque"},
+		{typedef("o", typedef("opaque", nil)), "opaque"},
+	} {
+		if got := test.t.FullName(); got != test.want {
+			t.Errorf("%s.FullName() = %q, want %q", test.t.Name, got, test.want)
+		}
+	}
+
+	// A chain of typedefs that loops ends at the last typedef before it
+	// repeats.
+	a := typedef("a", nil)
+	b := typedef("b", a)
+	a.Type = b
+	if got := a.FullName(); got != "b" {
+		t.Errorf("looping a.FullName() = %q, want b", got)
+	}
+
+	// Other types have their name, or if they are anonymous, their String.
+	for _, test := range []struct {
+		t interface {
+			Type
+			FullName() string
+		}
+		want string
+	}{
+		{u, "unsigned int"},
+		{anon, "struct {x unsigned int@0}"},
+		{&PtrType{Type: u}, "*unsigned int"},
+		{&PtrType{CommonType: CommonType{Name: "*main.T"}, Type: u}, "*main.T"},
+		{&ArrayType{Type: u, Count: 2}, "[2]unsigned int"},
+		{&MapType{TypedefType: TypedefType{CommonType: CommonType{Name: "map[int]int"}, Type: point}}, "map[int]int"},
+	} {
+		if got := test.t.FullName(); got != test.want {
+			t.Errorf("%s.FullName() = %q, want %q", test.t, got, test.want)
+		}
+		if got := test.t.Common().FullName(); got != test.t.Common().Name {
+			t.Errorf("%s.Common().FullName() = %q, want %q", test.t, got, test.t.Common().Name)
+		}
+	}
+
+	d := elfData(t, "testdata/typedef.elf")
+	list, err := d.LookupTypedef("t_my_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := list.FullName(); got != "t_my_list" {
+		t.Errorf("t_my_list.FullName() = %q, want t_my_list", got)
+	}
+}
+
+func TestArrayElementCount(t *testing.T) {
+	for _, test := range []struct {
+		count      int64
+		incomplete bool
+		n          int64
+	}{
+		{-1, true, 0},
+		{0, false, 0},
+		{10, false, 10},
+	} {
+		a := &ArrayType{Type: &CharType{}, Count: test.count}
+		if got := a.IsIncomplete(); got != test.incomplete {
+			t.Errorf("Count %d: IsIncomplete() = %t, want %t", test.count, got, test.incomplete)
+		}
+		if n, ok := a.ElementCount(); n != test.n || ok == test.incomplete {
+			t.Errorf("Count %d: ElementCount() = %d, %t, want %d, %t", test.count, n, ok, test.n, !test.incomplete)
+		}
+	}
+}
+
+func TestStructSize(t *testing.T) {
+	// The DWARF for struct msg {int len; char data[];}, once with its
+	// byte size and once without.
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x13, 1, 0x03, 0x08, 0, 0, // struct: name
+		3, 0x0d, 0, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0, 0, // member: name, type, location
+		4, 0x01, 1, 0x49, 0x13, 0, 0, // array: type
+		5, 0x21, 0, 0, 0, // subrange, without a count
+		6, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		7, 0x13, 1, 0x03, 0x08, 0x0b, 0x0b, 0, 0, // struct: name, byte size
+		0,
+	}
+	const (
+		msgOff   = 12
+		arrayOff = 39
+		intOff   = 46
+		charOff  = 53
+		sizedOff = 61
+	)
+	info := []byte{
+		86, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                   // address size
+		1,                   // 11: compile unit
+		2, 'm', 's', 'g', 0, // 12: struct msg
+		3, 'l', 'e', 'n', 0, intOff, 0, 0, 0, 0, // 17: len int@0
+		3, 'd', 'a', 't', 'a', 0, arrayOff, 0, 0, 0, 4, // 27: data []char@4
+		0,                   // 38: end of struct msg
+		4, charOff, 0, 0, 0, // 39: []char
+		5,                         // 44: subrange
+		0,                         // 45: end of []char
+		6, 'i', 'n', 't', 0, 5, 4, // 46: int
+		6, 'c', 'h', 'a', 'r', 0, 6, 1, // 53: char
+		7, 'm', 's', 'g', 0, 4, // 61: struct msg, of 4 bytes
+		3, 'l', 'e', 'n', 0, intOff, 0, 0, 0, 0, // 67: len int@0
+		3, 'd', 'a', 't', 'a', 0, arrayOff, 0, 0, 0, 4, // 77: data []char@4
+		0, // 88: end of struct msg
+		0, // 89: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, off := range []Offset{msgOff, sizedOff} {
+		typ, err := d.Type(off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := typ.(*StructType); !ok {
+			t.Fatalf("got %T at offset %d, want *StructType", typ, off)
+		}
+		if got := typ.Size(); got != 4 {
+			t.Errorf("%s at offset %d: Size() = %d, want 4", typ, off, got)
+		}
+	}
+
+	if got := (&StructType{Incomplete: true}).Size(); got != 0 {
+		t.Errorf("incomplete struct: Size() = %d, want 0", got)
+	}
+}
+
+func TestEmbeddedFields(t *testing.T) {
+	// The DWARF that the Go compiler generates for
+	//	type Inner struct{ A int }
+	//	type Outer struct{ Inner; B int }
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x13, 1, 0x03, 0x08, 0x0b, 0x0b, 0, 0, // struct: name, byte size
+		3, 0x0d, 0, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0, 0, // member: name, type, location
+		4, 0x0d, 0, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0x83, 0x52, 0x0c, 0, 0, // member: name, type, location, embedded
+		5, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		0,
+	}
+	const (
+		innerOff = 12
+		outerOff = 34
+		intOff   = 69
+	)
+	info := []byte{
+		73, 0, 0, 0, // unit length
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                                                         // address size
+		1,                                                         // 11: compile unit
+		2, 'm', 'a', 'i', 'n', '.', 'I', 'n', 'n', 'e', 'r', 0, 8, // 12: struct main.Inner
+		3, 'A', 0, intOff, 0, 0, 0, 0, // 25: A int@0
+		0,                                                          // 33: end of struct main.Inner
+		2, 'm', 'a', 'i', 'n', '.', 'O', 'u', 't', 'e', 'r', 0, 16, // 34: struct main.Outer
+		4, 'I', 'n', 'n', 'e', 'r', 0, innerOff, 0, 0, 0, 0, 1, // 47: embedded Inner@0
+		3, 'B', 0, intOff, 0, 0, 0, 8, // 60: B int@8
+		0,                         // 68: end of struct main.Outer
+		5, 'i', 'n', 't', 0, 5, 8, // 69: int
+		0, // 76: end of compile unit
+	}
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	typ, err := d.Type(outerOff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outer, ok := typ.(*StructType)
+	if !ok {
+		t.Fatalf("got %T, want *StructType", typ)
+	}
+	if len(outer.Field) != 2 {
+		t.Fatalf("%s has %d fields, want 2", outer, len(outer.Field))
+	}
+	if f := outer.Field[0]; !f.Embedded || !f.IsEmbedded() {
+		t.Errorf("field %s: Embedded = %t, IsEmbedded() = %t, want true", f.Name, f.Embedded, f.IsEmbedded())
+	}
+	if f := outer.Field[1]; f.Embedded || f.IsEmbedded() {
+		t.Errorf("field %s: Embedded = %t, IsEmbedded() = %t, want false", f.Name, f.Embedded, f.IsEmbedded())
+	}
+	if fields := outer.EmbeddedFields(); len(fields) != 1 || fields[0].Name != "Inner" || fields[0].Type.String() != "struct main.Inner" {
+		t.Errorf("EmbeddedFields() = %v, want just Inner", fields)
+	}
+	inner := outer.Field[0].Type.(*StructType)
+	if fields := inner.EmbeddedFields(); len(fields) != 0 {
+		t.Errorf("%s: EmbeddedFields() = %v, want none", inner, fields)
+	}
+
+	// Anonymous structs and unions in C are embedded too, but are not
+	// Go embedded fields.
+	anon := &StructType{Kind: "union", Field: []*StructField{{Name: "i", Type: outer.Field[1].Type}}}
+	c := &StructType{Kind: "struct", Field: []*StructField{{Type: anon}, {Name: "n", Type: anon}}}
+	if !c.Field[0].IsEmbedded() || c.Field[1].IsEmbedded() {
+		t.Errorf("IsEmbedded() = %t, %t for an anonymous and a named union, want true, false", c.Field[0].IsEmbedded(), c.Field[1].IsEmbedded())
+	}
+	if fields := c.EmbeddedFields(); len(fields) != 0 {
+		t.Errorf("EmbeddedFields() = %v for a C struct, want none", fields)
+	}
+
+	got, data := roundTrip(t, outer)
+	if st, ok := got.(*StructType); !ok || len(st.EmbeddedFields()) != 1 {
+		t.Errorf("embedded field was not preserved by %s", data)
+	}
+}
+
+func TestTypeConcurrent(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	e, err := d.LookupEntry("t_my_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := make(chan Type)
+	for i := 0; i < 50; i++ {
+		go func() {
+			typ, err := d.Type(e.Offset)
+			if err != nil {
+				t.Error(err)
+			}
+			if _, err := d.TypeReferences(e.Offset); err != nil {
+				t.Error(err)
+			}
+			types <- typ
+		}()
+	}
+	first := <-types
+	for i := 1; i < 50; i++ {
+		if typ := <-types; typ != first {
+			t.Errorf("got different types %p and %p for the same offset", typ, first)
+		}
+	}
+}
+
+func TestTypesEqual(t *testing.T) {
+	d2 := elfData(t, "testdata/typedef.elf")
+	d4 := elfData(t, "testdata/typedef.elf4")
+	lookup := func(d *Data, name string) Type {
+		tt, err := d.LookupTypedef(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tt
+	}
+	for name := range typedefTests {
+		a, b := lookup(d2, name), lookup(d4, name)
+		if !TypesEqual(a, a) {
+			t.Errorf("%s is not equal to itself", name)
+		}
+		// The same types, at different offsets in a different binary.
+		if !TypesEqual(a, b) {
+			t.Errorf("%s differs between DWARF versions", name)
+		}
+	}
+	if a := lookup(d2, "t_long"); !TypesEqual(a, a.(*TypedefType).Type) {
+		t.Error("t_long is not equal to the type it names")
+	}
+	for _, pair := range [][2]string{
+		{"t_my_struct", "t_my_struct1"},
+		{"t_long", "t_ushort"},
+		{"t_my_list", "t_my_tree"},
+		{"t_func_int_of_float_double", "t_func_void_of_char"},
+		{"t_ptr_const_char", "t_ptr_volatile_int"},
+	} {
+		if TypesEqual(lookup(d2, pair[0]), lookup(d4, pair[1])) {
+			t.Errorf("%s and %s are equal", pair[0], pair[1])
+		}
+	}
+
+	// Types built by hand, all at offset 0, that differ only in the
+	// targets of their pointer fields.
+	ptrStruct := func(target Type) *StructType {
+		return &StructType{
+			CommonType: CommonType{ByteSize: 8},
+			StructName: "s",
+			Kind:       "struct",
+			Field:      []*StructField{{Name: "p", Type: &PtrType{CommonType: CommonType{ByteSize: 8}, Type: target}}},
+		}
+	}
+	i32 := &IntType{BasicType{CommonType: CommonType{Name: "int32", ByteSize: 4}}}
+	f32 := &FloatType{BasicType{CommonType: CommonType{Name: "float32", ByteSize: 4}}}
+	if TypesEqual(ptrStruct(i32), ptrStruct(f32)) {
+		t.Error("structs with pointers to int32 and float32 are equal")
+	}
+	if !TypesEqual(ptrStruct(i32), ptrStruct(i32)) {
+		t.Error("structs with pointers to int32 are not equal")
+	}
+
+	// Function types are compared by their Params, names included.
+	fn := func(params ...Param) *FuncType {
+		return &FuncType{ReturnType: &VoidType{}, Params: params}
+	}
+	if TypesEqual(fn(Param{"n", i32}), fn(Param{"n", f32})) {
+		t.Error("func(n int32) and func(n float32) are equal")
+	}
+	if TypesEqual(fn(Param{"n", i32}), fn(Param{"m", i32})) {
+		t.Error("func(n int32) and func(m int32) are equal")
+	}
+	if !TypesEqual(fn(Param{"n", i32}), fn(Param{"n", i32})) {
+		t.Error("func(n int32) is not equal to itself")
+	}
+}
+
+// A layoutField is a field of a struct built by layoutData.
+type layoutField struct {
+	name   string
+	typ    byte // offset of the field's type
+	offset byte
+}
+
+// Offsets of the types in the data built by layoutData.
+const (
+	layoutInt     = 12
+	layoutChar    = 19
+	layoutIntPtr  = 27
+	layoutCharPtr = 33
+	layoutStruct  = 39
+)
+
+// layoutData returns DWARF defining int, char, pointers to them, and
+// struct s of the given size with the given fields.
+func layoutData(t *testing.T, size byte, fields ...layoutField) *Data {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x3e, 0x0b, 0x0b, 0x0b, 0, 0, // base type: name, encoding, byte size
+		3, 0x13, 1, 0x03, 0x08, 0x0b, 0x0b, 0, 0, // struct: name, byte size
+		4, 0x0d, 0, 0x03, 0x08, 0x49, 0x13, 0x38, 0x0b, 0, 0, // member: name, type, location
+		5, 0x0f, 0, 0x0b, 0x0b, 0x49, 0x13, 0, 0, // pointer: byte size, type
+		0,
+	}
+	info := []byte{
+		0, 0, 0, 0, // unit length, filled in below
+		2, 0, // version
+		0, 0, 0, 0, // abbrev offset
+		8,                         // address size
+		1,                         // 11: compile unit
+		2, 'i', 'n', 't', 0, 5, 4, // 12: int
+		2, 'c', 'h', 'a', 'r', 0, 6, 1, // 19: char
+		5, 8, layoutInt, 0, 0, 0, // 27: *int
+		5, 8, layoutChar, 0, 0, 0, // 33: *char
+		3, 's', 0, size, // 39: struct s
+	}
+	for _, f := range fields {
+		info = append(info, 4)
+		info = append(info, f.name...)
+		info = append(info, 0, f.typ, 0, 0, 0, f.offset)
+	}
+	info = append(info, 0, 0) // end of struct s and compile unit
+	info[0] = byte(len(info) - 4)
+	d, err := New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestTypesCompatible(t *testing.T) {
+	host := layoutData(t, 8, layoutField{"a", layoutInt, 0}, layoutField{"b", layoutInt, 4})
+	for _, test := range []struct {
+		name   string
+		plugin *Data
+		want   bool
+	}{
+		{"same", layoutData(t, 8, layoutField{"a", layoutInt, 0}, layoutField{"b", layoutInt, 4}), true},
+		{"padding field", layoutData(t, 12, layoutField{"a", layoutInt, 0}, layoutField{"pad", layoutInt, 4}, layoutField{"b", layoutInt, 8}), false},
+		{"moved field", layoutData(t, 8, layoutField{"a", layoutInt, 4}, layoutField{"b", layoutInt, 0}), false},
+		{"renamed field", layoutData(t, 8, layoutField{"a", layoutInt, 0}, layoutField{"c", layoutInt, 4}), false},
+		{"field type", layoutData(t, 8, layoutField{"a", layoutInt, 0}, layoutField{"b", layoutChar, 4}), false},
+	} {
+		if got := TypesCompatibleAcross(host, layoutStruct, test.plugin, layoutStruct); got != test.want {
+			t.Errorf("%s: TypesCompatibleAcross = %t, want %t", test.name, got, test.want)
+		}
+	}
+
+	// Pointers are compatible whatever they point to.
+	d := layoutData(t, 8, layoutField{"p", layoutIntPtr, 0})
+	pointers := layoutData(t, 8, layoutField{"p", layoutCharPtr, 0})
+	if !TypesCompatibleAcross(d, layoutStruct, pointers, layoutStruct) {
+		t.Error("structs holding *int and *char are not compatible")
+	}
+	if !d.TypesCompatible(layoutIntPtr, layoutCharPtr) || d.TypesCompatible(layoutInt, layoutChar) {
+		t.Error("TypesCompatible: got wrong result for pointers or base types")
+	}
+	if d.TypesCompatible(layoutInt, 1<<20) {
+		t.Error("TypesCompatible with a bad offset: got true")
+	}
+}
@@ -116,6 +116,14 @@ func (d *Data) sigToType(sig uint64) (Type, error) {
 	return t, nil
 }
 
+// signatureType is like sigToType, but may be called concurrently with
+// Type, which also reads type units.
+func (d *Data) signatureType(sig uint64) (Type, error) {
+	d.typeMu.Lock()
+	defer d.typeMu.Unlock()
+	return d.sigToType(sig)
+}
+
 // typeUnitReader is a typeReader for a tagTypeUnit.
 type typeUnitReader struct {
 	d   *Data
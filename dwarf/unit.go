@@ -4,7 +4,11 @@
 
 package dwarf
 
-import "strconv"
+import (
+	"path"
+	"sort"
+	"strconv"
+)
 
 // DWARF debug info is split into a sequence of compilation units.
 // Each unit has its own abbreviation table and address size.
@@ -17,6 +21,12 @@ type unit struct {
 	asize  int
 	vers   int
 	is64   bool // True for 64-bit DWARF format
+
+	// pkgname is the Go package name from the unit's entry, read the
+	// first time a type in the unit is decoded. Both fields are guarded by
+	// Data.typeMu.
+	pkgname     string
+	pkgnameRead bool
 }
 
 // Implement the dataFormat interface.
@@ -33,6 +43,159 @@ func (u *unit) addrsize() int {
 	return u.asize
 }
 
+// unitForOffset returns the unit containing off in the info section.
+// The units are in the order of their offsets, so they are searched
+// by bisection.
+func (d *Data) unitForOffset(off Offset) *unit {
+	i := sort.Search(len(d.unit), func(i int) bool {
+		return d.unit[i].off+Offset(len(d.unit[i].data)) > off
+	})
+	if i < len(d.unit) && d.unit[i].off <= off {
+		return &d.unit[i]
+	}
+	return nil
+}
+
+// PackageName returns the Go package name recorded, as DW_AT_go_package_name,
+// in the compilation unit entry at cuOff.
+func (d *Data) PackageName(cuOff Offset) (string, error) {
+	r := d.Reader()
+	r.Seek(cuOff)
+	e, err := r.Next()
+	if err != nil {
+		return "", err
+	}
+	if e == nil || e.Offset != cuOff || e.Tag != TagCompileUnit {
+		return "", DecodeError{"info", cuOff, "no compilation unit at offset"}
+	}
+	name, ok := e.Val(AttrGoPackageName).(string)
+	if !ok {
+		return "", DecodeError{"info", cuOff, "compilation unit has no Go package name"}
+	}
+	return name, nil
+}
+
+// packageName returns the Go package name of the unit containing off, or ""
+// if there is none. The caller must hold d.typeMu for writing.
+func (d *Data) packageName(off Offset) string {
+	u := d.unitForOffset(off)
+	if u == nil {
+		return ""
+	}
+	if !u.pkgnameRead {
+		u.pkgname, _ = d.PackageName(u.off)
+		u.pkgnameRead = true
+	}
+	return u.pkgname
+}
+
+// ObjectFiles returns the paths of the split DWARF object files that
+// contributed to d, as recorded by DW_AT_dwo_name or DW_AT_GNU_dwo_name in
+// its compilation unit entries. Relative paths are joined to the unit's
+// compilation directory. Each path is listed once, in the order of the
+// units.
+func (d *Data) ObjectFiles() ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	r := d.Reader()
+	for i := range d.unit {
+		u := &d.unit[i]
+		r.Seek(u.off)
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil || e.Tag != TagCompileUnit {
+			continue
+		}
+		name, ok := e.Val(AttrDwoName).(string)
+		if !ok {
+			name, ok = e.Val(AttrGNUDwoName).(string)
+		}
+		if !ok || name == "" {
+			continue
+		}
+		if dir, _ := e.Val(AttrCompDir).(string); dir != "" && !path.IsAbs(name) {
+			name = path.Join(dir, name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// A CompilationUnit describes a compilation unit in the info section.
+type CompilationUnit struct {
+	Offset   Offset // the offset of the unit's compile unit entry
+	Name     string // the primary source file, from DW_AT_name
+	Language int64  // the source language, from DW_AT_language
+	Producer string // the compiler, from DW_AT_producer
+	LowPC    uint64 // zero if not known
+	HighPC   uint64 // zero if not known
+
+	d *Data
+	u *unit
+}
+
+// CompilationUnits returns the compilation units of d, in the order they
+// appear in the info section.
+func (d *Data) CompilationUnits() ([]*CompilationUnit, error) {
+	var units []*CompilationUnit
+	r := d.Reader()
+	for i := range d.unit {
+		u := &d.unit[i]
+		r.Seek(u.off)
+		e, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if e == nil || e.Tag != TagCompileUnit {
+			continue
+		}
+		cu := &CompilationUnit{Offset: e.Offset, d: d, u: u}
+		cu.Name, _ = e.Val(AttrName).(string)
+		cu.Language, _ = e.Val(AttrLanguage).(int64)
+		cu.Producer, _ = e.Val(AttrProducer).(string)
+		if lowpc, highpc, ok := lowHighPC(e); ok {
+			cu.LowPC = lowpc + d.baseAddr
+			cu.HighPC = highpc + d.baseAddr
+		}
+		units = append(units, cu)
+	}
+	return units, nil
+}
+
+// Types calls fn for each type defined in the unit, in the order the
+// types appear, with the offset of the type and the type. If a type can't
+// be read, or fn returns a non-nil error, Types stops and returns that
+// error.
+func (cu *CompilationUnit) Types(fn func(off Offset, t Type) error) error {
+	end := cu.u.off + Offset(len(cu.u.data))
+	r := cu.d.Reader()
+	r.Seek(cu.u.off)
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return err
+		}
+		if e == nil || e.Offset >= end {
+			return nil
+		}
+		if !typeTags[e.Tag] {
+			continue
+		}
+		t, err := cu.d.Type(e.Offset)
+		if err != nil {
+			return err
+		}
+		if err := fn(e.Offset, t); err != nil {
+			return err
+		}
+	}
+}
+
 func (d *Data) parseUnits() ([]unit, error) {
 	// Count units.
 	nunit := 0
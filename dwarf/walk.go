@@ -0,0 +1,78 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf
+
+// A TypeVisitor's Visit method is called by Walk for each type it
+// reaches. If Visit returns false, Walk does not visit the types that
+// make up t.
+type TypeVisitor interface {
+	Visit(t Type) bool
+}
+
+// Walk traverses the type graph rooted at t in depth-first order, calling
+// v.Visit for t and then for each type it refers to: the fields and base
+// classes of structs, the elements of arrays, slices and channels, the
+// targets of pointers, typedefs and qualifiers, the keys and values of
+// maps, and the parameters and results of functions.
+// Each type is visited once, even if the graph contains cycles. Types are
+// identified by identity rather than by offset, since types that were not
+// read from DWARF, such as those built by hand, may all have offset 0.
+func Walk(t Type, v TypeVisitor) {
+	w := walker{v: v, visited: make(map[Type]bool)}
+	w.walk(t)
+}
+
+type walker struct {
+	v       TypeVisitor
+	visited map[Type]bool
+}
+
+func (w *walker) walk(t Type) {
+	if t == nil {
+		return
+	}
+	if w.visited[t] {
+		return
+	}
+	w.visited[t] = true
+	if !w.v.Visit(t) {
+		return
+	}
+	switch t := t.(type) {
+	case *StructType:
+		for _, b := range t.Bases {
+			w.walk(b.Type)
+		}
+		for _, f := range t.Field {
+			w.walk(f.Type)
+		}
+	case *SliceType:
+		w.walk(t.ElemType)
+	case *ArrayType:
+		w.walk(t.Type)
+	case *PtrType:
+		w.walk(t.Type)
+	case *QualType:
+		w.walk(t.Type)
+	case *TypedefType:
+		w.walk(t.Type)
+	case *InterfaceType:
+		w.walk(t.Type)
+	case *MapType:
+		w.walk(t.KeyType)
+		w.walk(t.ElemType)
+	case *ChanType:
+		w.walk(t.ElemType)
+	case *FuncType:
+		for _, p := range t.Params {
+			w.walk(p.Type)
+		}
+		w.walk(t.ReturnType)
+	case *NamelistType:
+		for _, item := range t.Items {
+			w.walk(item.Type)
+		}
+	}
+}
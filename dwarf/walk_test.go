@@ -0,0 +1,102 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dwarf_test
+
+import (
+	"testing"
+
+	. "golang.org/x/debug/dwarf"
+)
+
+type countVisitor struct {
+	count map[Type]int
+	skip  Type
+}
+
+func (v *countVisitor) Visit(t Type) bool {
+	v.count[t]++
+	return t != v.skip
+}
+
+func TestWalk(t *testing.T) {
+	// struct list { int val; struct list *next; }
+	intType := &IntType{BasicType{CommonType: CommonType{Name: "int", ByteSize: 4, Offset: 1}}}
+	list := &StructType{CommonType: CommonType{ByteSize: 16, Offset: 2}, StructName: "list", Kind: "struct"}
+	next := &PtrType{CommonType: CommonType{ByteSize: 8, Offset: 3}, Type: list}
+	list.Field = []*StructField{
+		{Name: "val", Type: intType},
+		{Name: "next", Type: next, ByteOffset: 8},
+	}
+	typedef := &TypedefType{CommonType: CommonType{Name: "t_list", Offset: 4}, Type: list}
+
+	v := &countVisitor{count: make(map[Type]int)}
+	Walk(typedef, v)
+	for _, typ := range []Type{typedef, list, next, intType} {
+		if got := v.count[typ]; got != 1 {
+			t.Errorf("visited %s %d times, want 1", typ, got)
+		}
+	}
+	if len(v.count) != 4 {
+		t.Errorf("visited %d types, want 4", len(v.count))
+	}
+
+	v = &countVisitor{count: make(map[Type]int), skip: list}
+	Walk(typedef, v)
+	if len(v.count) != 2 || v.count[typedef] != 1 || v.count[list] != 1 {
+		t.Errorf("Walk visited %v when skipping the children of %s, want only %s and %s", v.count, list, typedef, list)
+	}
+}
+
+func TestWalkZeroOffsets(t *testing.T) {
+	// Types built by hand, all at offset 0.
+	intType := &IntType{BasicType{CommonType: CommonType{Name: "int", ByteSize: 4}}}
+	list := &StructType{CommonType: CommonType{ByteSize: 16}, StructName: "list", Kind: "struct"}
+	next := &PtrType{CommonType: CommonType{ByteSize: 8}, Type: list}
+	list.Field = []*StructField{
+		{Name: "val", Type: intType},
+		{Name: "next", Type: next, ByteOffset: 8},
+	}
+	v := &countVisitor{count: make(map[Type]int)}
+	Walk(list, v)
+	if len(v.count) != 3 || v.count[list] != 1 || v.count[next] != 1 || v.count[intType] != 1 {
+		t.Errorf("Walk visited %v, want %s, %s and %s once each", v.count, list, next, intType)
+	}
+}
+
+func TestWalkFuncParams(t *testing.T) {
+	intType := &IntType{BasicType{CommonType: CommonType{Name: "int", ByteSize: 4, Offset: 1}}}
+	ptr := &PtrType{CommonType: CommonType{ByteSize: 8, Offset: 2}, Type: intType}
+	fn := &FuncType{CommonType: CommonType{Offset: 3}, Params: []Param{{"p", ptr}}}
+	v := &countVisitor{count: make(map[Type]int)}
+	Walk(fn, v)
+	if len(v.count) != 3 || v.count[ptr] != 1 || v.count[intType] != 1 {
+		t.Errorf("Walk visited %v, want %s, %s and %s once each", v.count, fn, ptr, intType)
+	}
+}
+
+func TestWalkELF(t *testing.T) {
+	d := elfData(t, "testdata/typedef.elf")
+	typ, err := d.LookupTypedef("t_my_list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &countVisitor{count: make(map[Type]int)}
+	Walk(typ, v)
+	seen := make(map[Offset]bool)
+	for typ, n := range v.count {
+		if n != 1 {
+			t.Errorf("visited %s %d times, want 1", typ, n)
+		}
+		off := typ.Common().Offset
+		if seen[off] {
+			t.Errorf("visited more than one type at offset %#x", off)
+		}
+		seen[off] = true
+	}
+	// t_my_list, struct list, short int, *t_my_list.
+	if len(v.count) != 4 {
+		t.Errorf("visited %d types, want 4", len(v.count))
+	}
+}
@@ -43,6 +43,11 @@ type File struct {
 	closer    io.Closer
 	gnuNeed   []verneed
 	gnuVersym []byte
+
+	// LazyStrings causes DWARF to read strings from the .debug_str
+	// section as they are needed, rather than loading the whole section.
+	// The File must not be closed while the DWARF data is in use.
+	LazyStrings bool
 }
 
 // A SectionHeader represents a single ELF section header.
@@ -576,7 +581,7 @@ func (f *File) DWARF() (*dwarf.Data, error) {
 	// are the required ones, and the debug/dwarf package
 	// does not use the others, so don't bother loading them.
 	// r: added line.
-	var names = [...]string{"abbrev", "frame", "info", "line", "str"}
+	var names = [...]string{"abbrev", "frame", "info", "line", "ranges", "str"}
 	var dat [len(names)][]byte
 	for i, name := range names {
 		name = ".debug_" + name
@@ -584,6 +589,9 @@ func (f *File) DWARF() (*dwarf.Data, error) {
 		if s == nil {
 			continue
 		}
+		if name == ".debug_str" && f.LazyStrings {
+			continue
+		}
 		b, err := s.Data()
 		if err != nil && uint64(len(b)) < s.Size {
 			return nil, err
@@ -605,11 +613,14 @@ func (f *File) DWARF() (*dwarf.Data, error) {
 		}
 	}
 
-	abbrev, frame, info, line, str := dat[0], dat[1], dat[2], dat[3], dat[4]
-	d, err := dwarf.New(abbrev, nil, frame, info, line, nil, nil, str)
+	abbrev, frame, info, line, ranges, str := dat[0], dat[1], dat[2], dat[3], dat[4], dat[5]
+	d, err := dwarf.New(abbrev, nil, frame, info, line, nil, ranges, str)
 	if err != nil {
 		return nil, err
 	}
+	if s := f.Section(".debug_str"); s != nil && f.LazyStrings {
+		d.SetStringReader(s)
+	}
 
 	// Look for DWARF4 .debug_types sections.
 	for i, s := range f.Sections {
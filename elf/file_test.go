@@ -332,3 +332,37 @@ func TestNoSectionOverlaps(t *testing.T) {
 		}
 	}
 }
+
+func TestDWARFLazyStrings(t *testing.T) {
+	const file = "../dwarf/testdata/typedef.elf"
+	names := func(lazy bool) []string {
+		f, err := Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		f.LazyStrings = lazy
+		d, err := f.DWARF()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		err = d.IterEntries(func(e *dwarf.Entry) bool {
+			if name, ok := e.Val(dwarf.AttrName).(string); ok {
+				names = append(names, name)
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+	want, got := names(false), names(true)
+	if len(want) == 0 {
+		t.Fatal("no names in DWARF data")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("names read lazily:\n%q\nwant:\n%q", got, want)
+	}
+}
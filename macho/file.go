@@ -475,7 +475,7 @@ func (f *File) DWARF() (*dwarf.Data, error) {
 	// There are many other DWARF sections, but these
 	// are the required ones, and the debug/dwarf package
 	// does not use the others, so don't bother loading them.
-	var names = [...]string{"abbrev", "frame", "info", "line", "str"}
+	var names = [...]string{"abbrev", "frame", "info", "line", "ranges", "str"}
 	var dat [len(names)][]byte
 	for i, name := range names {
 		name = "__debug_" + name
@@ -490,8 +490,8 @@ func (f *File) DWARF() (*dwarf.Data, error) {
 		dat[i] = b
 	}
 
-	abbrev, frame, info, line, str := dat[0], dat[1], dat[2], dat[3], dat[4]
-	return dwarf.New(abbrev, nil, frame, info, line, nil, nil, str)
+	abbrev, frame, info, line, ranges, str := dat[0], dat[1], dat[2], dat[3], dat[4], dat[5]
+	return dwarf.New(abbrev, nil, frame, info, line, nil, ranges, str)
 }
 
 // ImportedSymbols returns the names of all symbols
@@ -0,0 +1,130 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"log/slog"
+	"reflect"
+	"strconv"
+
+	"golang.org/x/debug/dwarf"
+)
+
+// maxAttrElements is the number of elements of an array or slice that
+// ValAttrs includes.
+const maxAttrElements = 100
+
+// ValAttrs returns the value of the given type at addr as structured
+// logging attributes. Each field of a struct becomes an attribute,
+// nested structs become groups, and the elements of arrays and slices
+// become groups keyed by index. A value that can't be read is replaced
+// by the error.
+func (p *Printer) ValAttrs(typ dwarf.Type, addr uint64) []slog.Attr {
+	p.reset()
+	st, ok := followTypedefs(typ).(*dwarf.StructType)
+	if !ok || st.ReflectKind == reflect.String {
+		return []slog.Attr{p.valAttr("value", typ, addr)}
+	}
+	attrs := make([]slog.Attr, len(st.Field))
+	for i, f := range st.Field {
+		attrs[i] = p.valAttr(f.Name, f.Type, addr+uint64(f.ByteOffset))
+	}
+	return attrs
+}
+
+// valAttr returns the value of the given type at a as an attribute with
+// the given name.
+func (p *Printer) valAttr(name string, typ dwarf.Type, a uint64) slog.Attr {
+	switch t := followTypedefs(typ).(type) {
+	case *dwarf.BoolType:
+		b, err := p.server.peekUint8(a)
+		if err != nil {
+			return slog.Any(name, err)
+		}
+		return slog.Bool(name, b != 0)
+	case *dwarf.IntType:
+		i, err := p.server.peekInt(a, t.ByteSize)
+		if err != nil {
+			return slog.Any(name, err)
+		}
+		return slog.Int64(name, i)
+	case *dwarf.UintType:
+		u, err := p.server.peekUint(a, t.ByteSize)
+		if err != nil {
+			return slog.Any(name, err)
+		}
+		return slog.Uint64(name, u)
+	case *dwarf.FloatType:
+		buf := make([]byte, t.ByteSize)
+		if err := p.server.peekBytes(a, buf); err != nil {
+			return slog.Any(name, err)
+		}
+		switch t.ByteSize {
+		case 4:
+			return slog.Float64(name, float64(p.arch.Float32(buf)))
+		case 8:
+			return slog.Float64(name, p.arch.Float64(buf))
+		}
+	case *dwarf.PtrType:
+		ptr, err := p.server.peekPtr(a)
+		if err != nil {
+			return slog.Any(name, err)
+		}
+		return slog.Any(name, ptr)
+	case *dwarf.StringType:
+		return p.stringAttr(name, t, a)
+	case *dwarf.StructType:
+		if t.ReflectKind == reflect.String {
+			return p.stringAttr(name, &dwarf.StringType{StructType: *t}, a)
+		}
+		attrs := make([]interface{}, len(t.Field))
+		for i, f := range t.Field {
+			attrs[i] = p.valAttr(f.Name, f.Type, a+uint64(f.ByteOffset))
+		}
+		return slog.Group(name, attrs...)
+	case *dwarf.ArrayType:
+		stride, ok := p.arrayStride(t)
+		if !ok || t.IsIncomplete() {
+			break
+		}
+		return p.elementsAttr(name, t.Type, a, stride, uint64(t.Count))
+	case *dwarf.SliceType:
+		s, err := p.server.peekSlice(t, a)
+		if err != nil {
+			return slog.Any(name, err)
+		}
+		size, ok := p.sizeof(t.ElemType)
+		if !ok {
+			break
+		}
+		return p.elementsAttr(name, t.ElemType, s.Address, size, s.Length)
+	}
+	// Fall back to the printed form of the value.
+	p.printBuf.Reset()
+	p.printValueAt(typ, a)
+	return slog.String(name, p.printBuf.String())
+}
+
+// stringAttr returns the string at a as an attribute.
+func (p *Printer) stringAttr(name string, t *dwarf.StringType, a uint64) slog.Attr {
+	s, err := p.server.peekString(t, a, uint64(p.maxStringBytes()))
+	if err != nil {
+		return slog.Any(name, err)
+	}
+	return slog.String(name, s)
+}
+
+// elementsAttr returns a group holding the first n elements of an array
+// starting at a, each keyed by its index.
+func (p *Printer) elementsAttr(name string, elemType dwarf.Type, a, stride, n uint64) slog.Attr {
+	if n > maxAttrElements {
+		n = maxAttrElements
+	}
+	attrs := make([]interface{}, n)
+	for i := uint64(0); i < n; i++ {
+		attrs[i] = p.valAttr(strconv.FormatUint(i, 10), elemType, a+i*stride)
+	}
+	return slog.Group(name, attrs...)
+}
@@ -0,0 +1,198 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/debug"
+	"golang.org/x/debug/dwarf"
+)
+
+// goroutineFields are the fields of runtime.g printed by SprintGoroutine,
+// if the runtime has them.
+var goroutineFields = []string{"goid", "atomicstatus", "waitreason", "waitsince", "startpc", "gopc", "waiting"}
+
+// SprintGoroutine returns a summary of the goroutine whose g struct is at
+// gAddr, where gEntry is the DWARF entry for the type runtime.g. The first
+// line gives the goroutine's ID, its status and the function it is
+// executing, as in
+//
+//	goroutine 17 [sleep]: main.main() at main.go:42 (+0x123)
+//
+// and it is followed by the most useful fields of the g struct, one per
+// line. The sudog the goroutine is waiting on, if any, is printed too.
+// If the goroutine's saved stack pointer is within its stack, the local
+// variables and parameters of the function it is executing are printed
+// last.
+func (p *Printer) SprintGoroutine(gEntry *dwarf.Entry, gAddr uint64) (string, error) {
+	t, err := p.dwarf.Type(gEntry.Offset)
+	if err != nil {
+		return "", err
+	}
+	gType, ok := followTypedefs(t).(*dwarf.StructType)
+	if !ok {
+		return "", errors.New("runtime.g is not a struct")
+	}
+	return p.sprintGoroutine(gType, gAddr)
+}
+
+func (p *Printer) sprintGoroutine(gType *dwarf.StructType, g uint64) (string, error) {
+	p.reset()
+	goid, err := p.server.peekIntStructField(gType, g, "goid")
+	if err != nil {
+		return "", err
+	}
+	var gr debug.Goroutine
+	status, err := p.server.goroutineStatus(gType, g, &gr)
+	if err != nil {
+		return "", err
+	}
+	if gr.StatusString == "" {
+		gr.StatusString = fmt.Sprintf("status %d", status)
+	}
+	p.printf("goroutine %d [%s]", goid, gr.StatusString)
+	if pc := p.goroutinePC(gType, g); pc != 0 {
+		p.write(": ")
+		p.printPCLocation(pc)
+	}
+
+	for _, name := range goroutineFields {
+		f, err := gType.FieldByName(name)
+		if err != nil {
+			continue
+		}
+		p.printf("\n\t%s: ", name)
+		if name == "waiting" {
+			p.printValueFollowingPointers(f.Type, g+uint64(f.ByteOffset))
+			continue
+		}
+		p.printValueAt(f.Type, g+uint64(f.ByteOffset))
+	}
+	p.printGoroutineLocals(gType, g)
+	return p.printBuf.String(), p.err
+}
+
+// printValueFollowingPointers prints the value of type typ at address a
+// with FollowPointers set, restoring the option afterwards.
+func (p *Printer) printValueFollowingPointers(typ dwarf.Type, a uint64) {
+	follow := p.FollowPointers
+	p.FollowPointers = true
+	defer func() { p.FollowPointers = follow }()
+	p.printValueAt(typ, a)
+}
+
+// printGoroutineLocals prints the local variables and parameters of the
+// function the goroutine whose g struct is at g is executing, found
+// through the PC and SP saved in g.sched. Nothing is printed if the
+// saved SP is not within g.stack, as for a running goroutine, or if
+// there is no frame information for the PC.
+func (p *Printer) printGoroutineLocals(gType *dwarf.StructType, g uint64) {
+	sched, err := gType.FieldByName("sched")
+	if err != nil {
+		return
+	}
+	schedType, ok := followTypedefs(sched.Type).(*dwarf.StructType)
+	if !ok {
+		return
+	}
+	stack, err := gType.FieldByName("stack")
+	if err != nil {
+		return
+	}
+	stackType, ok := followTypedefs(stack.Type).(*dwarf.StructType)
+	if !ok {
+		return
+	}
+	pc, err := p.server.peekUintStructField(schedType, g+uint64(sched.ByteOffset), "pc")
+	if err != nil || pc == 0 {
+		return
+	}
+	sp, err := p.server.peekUintStructField(schedType, g+uint64(sched.ByteOffset), "sp")
+	if err != nil {
+		return
+	}
+	lo, err := p.server.peekUintStructField(stackType, g+uint64(stack.ByteOffset), "lo")
+	if err != nil {
+		return
+	}
+	hi, err := p.server.peekUintStructField(stackType, g+uint64(stack.ByteOffset), "hi")
+	if err != nil || sp < lo || sp >= hi {
+		return
+	}
+	fpOffset, err := p.dwarf.PCToSPOffset(pc)
+	if err != nil {
+		return
+	}
+	entry, _, err := p.dwarf.EntryForPC(pc)
+	if err != nil || !entry.Children {
+		return
+	}
+	regs := &dwarf.Registers{CFA: sp + uint64(fpOffset)}
+	regs.FrameBase = regs.CFA
+	if fb, ok := entry.Val(dwarf.AttrFrameBase).([]byte); ok {
+		if loc, err := p.dwarf.EvalLocationRegs(entry, fb, regs); err == nil && loc.Kind == dwarf.LocationAddr {
+			regs.FrameBase = loc.Addr
+		}
+	}
+
+	r := p.dwarf.Reader()
+	r.Seek(entry.Offset)
+	if _, err := r.Next(); err != nil {
+		return
+	}
+	p.write("\n\tlocals:")
+	for {
+		e, err := r.Next()
+		if err != nil {
+			p.errorf("reading locals: %s", err)
+			return
+		}
+		if e == nil || e.Tag == 0 {
+			return
+		}
+		if e.Tag == dwarf.TagVariable || e.Tag == dwarf.TagFormalParameter {
+			name, _ := e.Val(dwarf.AttrName).(string)
+			p.printf("\n\t\t%s: ", name)
+			p.printNamedEntry(e, regs)
+		}
+		if e.Children {
+			r.SkipChildren()
+		}
+	}
+}
+
+// goroutinePC returns the saved PC of the goroutine whose g struct is at g,
+// or, if that isn't available, the PC of the goroutine's start function.
+// It returns zero if neither can be read.
+func (p *Printer) goroutinePC(gType *dwarf.StructType, g uint64) uint64 {
+	if sched, err := gType.FieldByName("sched"); err == nil {
+		if schedType, ok := followTypedefs(sched.Type).(*dwarf.StructType); ok {
+			if pc, err := p.server.peekUintStructField(schedType, g+uint64(sched.ByteOffset), "pc"); err == nil && pc != 0 {
+				return pc
+			}
+		}
+	}
+	pc, _ := p.server.peekUintStructField(gType, g, "startpc")
+	return pc
+}
+
+// printPCLocation prints the function containing pc, its source position,
+// and its offset from the start of the function, as in
+// "main.main() at main.go:42 (+0x123)".
+func (p *Printer) printPCLocation(pc uint64) {
+	entry, lowpc, err := p.dwarf.EntryForPC(pc)
+	if err != nil {
+		p.printf("%#x", pc)
+		return
+	}
+	name, _ := entry.Val(dwarf.AttrName).(string)
+	p.printf("%s()", name)
+	if file, line, err := p.dwarf.PCToLine(pc); err == nil {
+		p.printf(" at %s:%d", file, line)
+	}
+	p.printf(" (+%#x)", pc-lowpc)
+}
@@ -0,0 +1,17 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import "go/parser"
+
+// ValidateGoLiteralOutput returns an error if s, the output of a Printer,
+// is not a valid Go expression, to check that output meant to be pasted
+// into Go source parses. Printers with GoLiteral set print every value as
+// Go; otherwise only some values, such as numbers, strings and untruncated
+// arrays and slices, are printed in Go syntax, and structs and maps are not.
+func ValidateGoLiteralOutput(s string) error {
+	_, err := parser.ParseExpr(s)
+	return err
+}
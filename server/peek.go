@@ -16,6 +16,9 @@ import (
 
 // peekBytes reads len(buf) bytes at addr.
 func (s *Server) peekBytes(addr uint64, buf []byte) error {
+	if s.peekHook != nil {
+		return s.peekHook(addr, buf)
+	}
 	return s.ptracePeek(s.stoppedPid, uintptr(addr), buf)
 }
 
@@ -131,7 +134,7 @@ func (s *Server) peekCString(a uint64, byteLimit uint64) string {
 // peekPtrStructField reads a pointer in the field fieldName of the struct
 // of type t at addr.
 func (s *Server) peekPtrStructField(t *dwarf.StructType, addr uint64, fieldName string) (uint64, error) {
-	f, err := getField(t, fieldName)
+	f, err := t.FieldByName(fieldName)
 	if err != nil {
 		return 0, fmt.Errorf("reading field %s: %s", fieldName, err)
 	}
@@ -146,7 +149,7 @@ func (s *Server) peekPtrStructField(t *dwarf.StructType, addr uint64, fieldName
 // This function is used when the value should be non-negative, but the DWARF
 // type of the field may be signed or unsigned.
 func (s *Server) peekUintOrIntStructField(t *dwarf.StructType, addr uint64, fieldName string) (uint64, error) {
-	f, err := getField(t, fieldName)
+	f, err := t.FieldByName(fieldName)
 	if err != nil {
 		return 0, fmt.Errorf("reading field %s: %s", fieldName, err)
 	}
@@ -171,7 +174,7 @@ func (s *Server) peekUintOrIntStructField(t *dwarf.StructType, addr uint64, fiel
 // peekUintStructField reads a uint in the field fieldName of the struct
 // of type t at addr.  The size of the uint is determined by the field.
 func (s *Server) peekUintStructField(t *dwarf.StructType, addr uint64, fieldName string) (uint64, error) {
-	f, err := getField(t, fieldName)
+	f, err := t.FieldByName(fieldName)
 	if err != nil {
 		return 0, fmt.Errorf("reading field %s: %s", fieldName, err)
 	}
@@ -185,7 +188,7 @@ func (s *Server) peekUintStructField(t *dwarf.StructType, addr uint64, fieldName
 // peekIntStructField reads an int in the field fieldName of the struct
 // of type t at addr.  The size of the int is determined by the field.
 func (s *Server) peekIntStructField(t *dwarf.StructType, addr uint64, fieldName string) (int64, error) {
-	f, err := getField(t, fieldName)
+	f, err := t.FieldByName(fieldName)
 	if err != nil {
 		return 0, fmt.Errorf("reading field %s: %s", fieldName, err)
 	}
@@ -200,7 +203,7 @@ func (s *Server) peekIntStructField(t *dwarf.StructType, addr uint64, fieldName
 // at the given address.
 // At most byteLimit bytes will be read.  If the string is longer, "..." is appended.
 func (s *Server) peekStringStructField(t *dwarf.StructType, addr uint64, fieldName string, byteLimit uint64) (string, error) {
-	f, err := getField(t, fieldName)
+	f, err := t.FieldByName(fieldName)
 	if err != nil {
 		return "", fmt.Errorf("reading field %s: %s", fieldName, err)
 	}
@@ -234,6 +237,27 @@ func (s *Server) peekMapLocationAndType(t *dwarf.MapType, a uint64) (uint64, *dw
 // peekMapValues reads a map at the given address and calls fn with the addresses for each (key, value) pair.
 // If fn returns false, peekMapValues stops.
 func (s *Server) peekMapValues(t *dwarf.MapType, a uint64, fn func(keyAddr, valAddr uint64, keyType, valType dwarf.Type) bool) error {
+	return s.peekMapValuesChecked(t, a, nil, fn)
+}
+
+// An unmappedError is returned by peekMapValuesChecked for an address
+// of the map that may not be read.
+type unmappedError uint64
+
+func (e unmappedError) Error() string {
+	return fmt.Sprintf("unmapped address %#x", uint64(e))
+}
+
+// peekMapValuesChecked is like peekMapValues, but if mapped is non-nil it
+// is called before reading the map header and each bucket, and if it
+// returns false for an address, an unmappedError is returned.
+func (s *Server) peekMapValuesChecked(t *dwarf.MapType, a uint64, mapped func(uint64) bool, fn func(keyAddr, valAddr uint64, keyType, valType dwarf.Type) bool) error {
+	check := func(a uint64) error {
+		if mapped != nil && !mapped(a) {
+			return unmappedError(a)
+		}
+		return nil
+	}
 	a, st, err := s.peekMapLocationAndType(t, a)
 	if err != nil {
 		return err
@@ -242,6 +266,9 @@ func (s *Server) peekMapValues(t *dwarf.MapType, a uint64, fn func(keyAddr, valA
 		// The pointer was nil, so the map is empty.
 		return nil
 	}
+	if err := check(a); err != nil {
+		return err
+	}
 	// Gather information about the struct type and the map bucket type.
 	b, err := s.peekUintStructField(st, a, "B")
 	if err != nil {
@@ -255,7 +282,7 @@ func (s *Server) peekMapValues(t *dwarf.MapType, a uint64, fn func(keyAddr, valA
 	if err != nil {
 		return fmt.Errorf("reading map: %s", err)
 	}
-	bf, err := getField(st, "buckets")
+	bf, err := st.FieldByName("buckets")
 	if err != nil {
 		return fmt.Errorf("reading map: %s", err)
 	}
@@ -268,13 +295,13 @@ func (s *Server) peekMapValues(t *dwarf.MapType, a uint64, fn func(keyAddr, valA
 		return errors.New("bad map bucket type: not a pointer to a struct")
 	}
 	bucketSize := uint64(bucketPtrType.Type.Size())
-	tophashField, err := getField(bt, "tophash")
+	tophashField, err := bt.FieldByName("tophash")
 	if err != nil {
 		return fmt.Errorf("reading map: %s", err)
 	}
 	bucketCnt := uint64(tophashField.Type.Size())
 	tophashFieldOffset := uint64(tophashField.ByteOffset)
-	keysField, err := getField(bt, "keys")
+	keysField, err := bt.FieldByName("keys")
 	if err != nil {
 		return fmt.Errorf("reading map: %s", err)
 	}
@@ -285,7 +312,7 @@ func (s *Server) peekMapValues(t *dwarf.MapType, a uint64, fn func(keyAddr, valA
 	keyType := keysType.Type
 	keysStride := uint64(keysType.StrideBitSize / 8)
 	keysFieldOffset := uint64(keysField.ByteOffset)
-	valuesField, err := getField(bt, "values")
+	valuesField, err := bt.FieldByName("values")
 	if err != nil {
 		return fmt.Errorf("reading map: %s", err)
 	}
@@ -296,7 +323,7 @@ func (s *Server) peekMapValues(t *dwarf.MapType, a uint64, fn func(keyAddr, valA
 	valueType := valuesType.Type
 	valuesStride := uint64(valuesType.StrideBitSize / 8)
 	valuesFieldOffset := uint64(valuesField.ByteOffset)
-	overflowField, err := getField(bt, "overflow")
+	overflowField, err := bt.FieldByName("overflow")
 	if err != nil {
 		return fmt.Errorf("reading map: %s", err)
 	}
@@ -319,6 +346,9 @@ func (s *Server) peekMapValues(t *dwarf.MapType, a uint64, fn func(keyAddr, valA
 			// Iterate through the linked list of buckets.
 			// TODO: check for repeated bucket pointers.
 			for bucketAddr != 0 {
+				if err := check(bucketAddr); err != nil {
+					return err
+				}
 				// Iterate through each entry in the bucket.
 				for j := uint64(0); j < bucketCnt; j++ {
 					tophash, err := s.peekUint8(bucketAddr + tophashFieldOffset + j)
@@ -6,7 +6,16 @@ package server
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"golang.org/x/debug/arch"
 	"golang.org/x/debug/dwarf"
@@ -18,56 +27,461 @@ type typeAndAddress struct {
 	Address uint64
 }
 
+// maxSmallCycleSet is the number of elements a cycleSet holds in its slice
+// before it switches to a map.
+const maxSmallCycleSet = 32
+
+// A cycleSet is a set of typeAndAddresses, used to detect cyclic data.
+// Sets are almost always small, so they are searched linearly; a map is
+// only used for larger sets. The zero value is an empty set.
+type cycleSet struct {
+	small []typeAndAddress
+	large map[typeAndAddress]bool // Non-nil once small is full.
+}
+
+func (s *cycleSet) contains(ta typeAndAddress) bool {
+	if s.large != nil {
+		return s.large[ta]
+	}
+	for _, x := range s.small {
+		if x == ta {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *cycleSet) add(ta typeAndAddress) {
+	if s.large == nil && len(s.small) < maxSmallCycleSet {
+		s.small = append(s.small, ta)
+		return
+	}
+	if s.large == nil {
+		s.large = make(map[typeAndAddress]bool, 2*maxSmallCycleSet)
+		for _, x := range s.small {
+			s.large[x] = true
+		}
+	}
+	s.large[ta] = true
+}
+
+// clear empties the set, keeping the slice for reuse. A map, which may
+// have grown large, is dropped.
+func (s *cycleSet) clear() {
+	for i := range s.small {
+		s.small[i] = typeAndAddress{} // Don't retain the types.
+	}
+	s.small = s.small[:0]
+	s.large = nil
+}
+
 // Routines to print a value using DWARF type descriptions.
 // TODO: Does this deserve its own package? It has no dependencies on Server.
 
+// PrinterOptions configures how a Printer prints values, and limits how
+// much of each value it prints. A zero field selects the default.
+type PrinterOptions struct {
+	// MaxArrayElements is the number of elements printed for each array;
+	// any remaining elements are truncated to "...". The default is 100.
+	MaxArrayElements int64
+
+	// MaxMapEntries is the number of entries printed for each map; any
+	// remaining entries are truncated to "...". The default is 8.
+	MaxMapEntries int
+
+	// MaxStringBytes is the number of bytes printed for each string;
+	// longer strings are truncated to "...". The default is 100.
+	MaxStringBytes int
+
+	// MaxDepth is the depth of nested values, such as fields of fields,
+	// that is printed; deeper values are printed as "<max depth>". The
+	// default is 64.
+	MaxDepth int
+
+	// FollowPointers causes the value a pointer points to to be printed
+	// after the pointer, as in "(*0x1234) 42". Cyclic data is printed
+	// only until the cycle is detected.
+	FollowPointers bool
+
+	// Indent, if non-empty, causes the elements of structs, arrays,
+	// slices and maps to be printed on separate lines, each indented by
+	// a copy of Indent per level of nesting: for example "\t", or four
+	// spaces.
+	Indent string
+
+	// ResolveFuncNames causes function pointers to be followed by the name
+	// of the function they point to, as in "0x401000 /* main.f */", and
+	// functions to be printed with their name rather than their type, as
+	// in "main.f @0x401000".
+	ResolveFuncNames bool
+
+	// ShowArtificialFields causes struct fields inserted by the compiler,
+	// which are marked as artificial in the DWARF, to be printed. By
+	// default they are left out.
+	ShowArtificialFields bool
+
+	// SortMapKeys causes map entries to be printed in order of their keys,
+	// rather than in the order they are stored, so that the output is
+	// deterministic. Integer keys are sorted numerically and string keys
+	// lexicographically; keys of other types are sorted by their printed
+	// form.
+	SortMapKeys bool
+
+	// GoLiteral causes values to be printed in Go syntax where possible,
+	// so that the output can be pasted into a Go test as a want value:
+	// structs as T{Field: value}, maps as map[K]V{key: value}, chars as
+	// integers and nil pointers as nil. Numbers, strings, arrays and
+	// slices are always printed in Go syntax.
+	GoLiteral bool
+
+	// MapChunkSize, if positive, causes the output to be flushed after
+	// every MapChunkSize entries of a map when printing to a writer, as
+	// with Fprint, so that at most that many entries are held in memory
+	// at once. By default the output is flushed in chunks of a fixed
+	// number of bytes.
+	MapChunkSize int
+
+	// ReverseFields causes the fields of structs to be printed from last
+	// to first, for comparison with documentation of formats that list
+	// fields in that order.
+	ReverseFields bool
+
+	// NilString is printed for nil pointers, such as "NULL" or "nullptr"
+	// to match the language of the program. If empty, "nil" is printed.
+	NilString string
+
+	// ValidateAddrFn, if non-nil, is called before reading a pointer or,
+	// with FollowPointers, the value it points to, and before reading
+	// through the pointers in interfaces, slices, channels and maps. If it
+	// returns false, "<unmapped @addr>" is printed instead of reading the
+	// address.
+	ValidateAddrFn func(addr uint64) bool
+
+	// FieldFilter, if non-nil, selects the fields of structs to print:
+	// only fields for which it returns true are printed. NewFieldFilter
+	// returns a FieldFilter for a list of field names.
+	FieldFilter func(*dwarf.StructField) bool
+
+	// MapKeyLess, if non-nil, causes map entries to be printed in the order
+	// it defines, as with SortMapKeys. It is called with the types and
+	// addresses of two keys, and reports whether the first key sorts before
+	// the second.
+	MapKeyLess func(keyTypeA, keyTypeB dwarf.Type, addrA, addrB uint64) bool
+}
+
+// Default print limits, used for zero fields of PrinterOptions.
+const (
+	defaultMaxArrayElements = 100
+	defaultMaxMapEntries    = 8
+	defaultMaxStringBytes   = 100
+	defaultMaxDepth         = 64
+)
+
 // A Printer pretty-prints values in the target address space.
 // It can be reused after each printing operation to avoid unnecessary
 // allocations. However, it is not safe for concurrent access.
 type Printer struct {
-	err      error // Sticky error value.
-	server   *Server
-	dwarf    *dwarf.Data
-	arch     *arch.Architecture
-	printBuf bytes.Buffer            // Accumulates the output.
-	visited  map[typeAndAddress]bool // Prevents looping on cyclic data.
+	err       error   // Sticky error value.
+	errs      []error // All errors, for Errors.
+	truncated bool    // Whether any output was elided.
+	server    *Server
+	dwarf     *dwarf.Data
+	arch      *arch.Architecture
+	printBuf  bytes.Buffer // Accumulates the output.
+	visited   cycleSet     // Prevents looping on cyclic data.
+	budget    int64        // If positive, the maximum size of the output.
+	exceeded  bool         // Whether printing stopped because the budget was used up.
+	out       io.Writer    // If non-nil, printBuf is flushed to out as it fills.
+	outErr    error        // The first error writing to out.
+	flushed   int64        // The number of bytes flushed to out.
+	prefixLen int64        // The length of the goroutine prefix, which the budget doesn't count.
+	depth     int          // The nesting depth of the value being printed.
+	level     int          // The indentation level, if there is an Indent.
+
+	// goroutineID, if non-zero, is the ID of the goroutine whose values are
+	// being printed; see SetGoroutineID.
+	goroutineID uint64
+
+	PrinterOptions
+
+	// StackRange, if non-empty, is the half-open range [StackRange[0],
+	// StackRange[1]) of addresses occupied by the stack. Pointers into
+	// the stack are printed as <stack @addr>, since the stack contents
+	// may no longer be valid, as in a core dump.
+	StackRange [2]uint64
+
+	// MaxMapPrint is the number of values printed for each map, if
+	// MaxMapEntries is zero.
+	//
+	// Deprecated: Use PrinterOptions.MaxMapEntries.
+	MaxMapPrint int
+
+	// MaxMapKeyLen, if positive, is the number of bytes printed for each
+	// string key of a map; longer keys are truncated to "...".
+	MaxMapKeyLen int
+
+	// BeforeField and AfterField, if non-nil, are called before and after
+	// printing each field of a struct, with the field and its address.
+	// For the top-level value being printed, they are called with a nil
+	// field, and with address 0 if the value is not in memory, as for a
+	// variable in a register. They may use Annotate to add to the output.
+	BeforeField func(field *dwarf.StructField, addr uint64)
+	AfterField  func(field *dwarf.StructField, addr uint64)
+
+	// CycleSentinelFormat is the format used in place of a value that is
+	// already being printed, to avoid looping on cyclic data. It is passed
+	// the value's type and address, in that order; explicit argument
+	// indexes such as "<cycle at %#[2]x>" can be used to omit the type.
+	// If empty, "(%v %#x)" is used.
+	CycleSentinelFormat string
+
+	// ErrorHandler, if non-nil, returns the text to print in place of a
+	// value that could not be printed because of err. By default, the
+	// error is printed in angle brackets.
+	ErrorHandler func(err error) string
+
+	// HTMLMode makes the printer produce HTML for embedding in a debug web
+	// page: type names link to /type/{name}, addresses link to
+	// /mem/{addr}, struct fields are laid out in a table, and all other
+	// text is escaped.
+	HTMLMode bool
+}
+
+// write appends s to printBuf, unless that would exceed the output budget.
+func (p *Printer) write(s string) {
+	if p.exceeded {
+		return
+	}
+	if p.budget > 0 {
+		if room := p.budget + p.prefixLen - p.flushed - int64(p.printBuf.Len()); int64(len(s)) > room {
+			p.printBuf.WriteString(p.cutOutput(s, int(room)))
+			p.exceeded = true
+			p.truncated = true
+			return
+		}
+	}
+	p.printBuf.WriteString(s)
+	if p.out != nil && p.printBuf.Len() >= flushSize {
+		p.flush()
+	}
+}
+
+// cutOutput returns the longest prefix of s of at most n bytes that does not
+// split a UTF-8 sequence or, in HTML mode, a character reference such as
+// "&lt;".
+func (p *Printer) cutOutput(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	s = s[:n]
+	if p.HTMLMode {
+		if i := strings.LastIndexByte(s, '&'); i >= 0 && strings.IndexByte(s[i:], ';') < 0 {
+			s = s[:i]
+		}
+	}
+	return s
+}
+
+// flushSize is the amount of output buffered before it is flushed to the
+// writer passed to Fprint or FprintEntry.
+const flushSize = 4096
+
+// flush writes the contents of printBuf to out.
+func (p *Printer) flush() {
+	if p.outErr == nil {
+		_, p.outErr = p.out.Write(p.printBuf.Bytes())
+	}
+	p.flushed += int64(p.printBuf.Len())
+	p.printBuf.Reset()
+}
+
+// fprint performs the printing operation print, writing the output to w.
+func (p *Printer) fprint(w io.Writer, print func()) error {
+	p.reset()
+	p.out = w
+	p.printGoroutinePrefix()
+	print()
+	p.flush()
+	p.out = nil
+	if p.outErr != nil {
+		return p.outErr
+	}
+	return p.err
 }
 
 // printf prints to printBuf.
 func (p *Printer) printf(format string, args ...interface{}) {
-	fmt.Fprintf(&p.printBuf, format, args...)
+	p.write(fmt.Sprintf(format, args...))
+}
+
+// Annotate adds s to the output of the printing operation in progress.
+// It is intended for use by the BeforeField and AfterField callbacks.
+func (p *Printer) Annotate(s string) {
+	p.write(s)
+}
+
+// printMarker prints text that is not part of a value, such as "nil",
+// in angle brackets.
+func (p *Printer) printMarker(format string, args ...interface{}) {
+	s := fmt.Sprintf(format, args...)
+	if p.HTMLMode {
+		p.write("&lt;" + html.EscapeString(s) + "&gt;")
+		return
+	}
+	p.write("<" + s + ">")
+}
+
+// printChar prints a C character as its value n followed by c quoted, as
+// in "65 'A'". Bytes outside ASCII are quoted as hex escapes, as in
+// "255 '\xff'", since they are not characters on their own.
+func (p *Printer) printChar(n interface{}, c byte) {
+	var s string
+	if c < utf8.RuneSelf {
+		s = fmt.Sprintf("%d %q", n, rune(c))
+	} else {
+		s = fmt.Sprintf(`%d '\x%02x'`, n, c)
+	}
+	if p.HTMLMode {
+		s = html.EscapeString(s)
+	}
+	p.write(s)
+}
+
+// printNil prints a nil pointer.
+func (p *Printer) printNil() {
+	if p.NilString == "" {
+		p.write("nil")
+		return
+	}
+	if p.HTMLMode {
+		p.write(html.EscapeString(p.NilString))
+		return
+	}
+	p.write(p.NilString)
+}
+
+// typeName returns the name of t, as a link in HTML mode.
+func (p *Printer) typeName(t dwarf.Type) string {
+	s := t.String()
+	if !p.HTMLMode {
+		return s
+	}
+	name := t.Common().Name
+	if name == "" {
+		name = s
+	}
+	return `<a href="/type/` + url.PathEscape(name) + `">` + html.EscapeString(s) + `</a>`
+}
+
+// addrLink returns a as a hexadecimal string, as a link in HTML mode.
+func (p *Printer) addrLink(a uint64) string {
+	s := fmt.Sprintf("%#x", a)
+	if !p.HTMLMode {
+		return s
+	}
+	return `<a href="/mem/` + s + `">` + s + `</a>`
 }
 
-// errorf prints the error to printBuf, then sets the sticky error for the
-// printer, if not already set.
+// errorf prints the error to printBuf and records it, then sets the sticky
+// error for the printer, if not already set.
 func (p *Printer) errorf(format string, args ...interface{}) {
-	fmt.Fprintf(&p.printBuf, "<"+format+">", args...)
+	err := fmt.Errorf(format, args...)
+	if p.ErrorHandler != nil {
+		p.write(p.ErrorHandler(err))
+	} else {
+		p.printMarker("%s", err)
+	}
+	p.errs = append(p.errs, err)
 	if p.err != nil {
 		return
 	}
-	p.err = fmt.Errorf(format, args...)
+	p.err = err
+}
+
+// Errors returns all the errors encountered by the last printing
+// operation, in the order they occurred. The error returned by the
+// operation itself is the first of them.
+func (p *Printer) Errors() []error {
+	return p.errs
 }
 
 // NewPrinter returns a printer that can use the Server to access and print
 // values of the specified architecture described by the provided DWARF data.
-func NewPrinter(arch *arch.Architecture, dwarf *dwarf.Data, server *Server) *Printer {
-	return &Printer{
-		server:  server,
-		arch:    arch,
-		dwarf:   dwarf,
-		visited: make(map[typeAndAddress]bool),
+// If opts is given, its limits are used in place of the defaults.
+func NewPrinter(arch *arch.Architecture, dwarf *dwarf.Data, server *Server, opts ...PrinterOptions) *Printer {
+	p := &Printer{
+		server: server,
+		arch:   arch,
+		dwarf:  dwarf,
+	}
+	if len(opts) > 0 {
+		p.PrinterOptions = opts[0]
 	}
+	return p
 }
 
 // reset resets the Printer. It must be called before starting a new
 // printing operation.
 func (p *Printer) reset() {
 	p.err = nil
+	p.errs = nil
+	p.truncated = false
+	p.exceeded = false
 	p.printBuf.Reset()
-	// Just wipe the map rather than reallocating. It's almost always tiny.
-	for k := range p.visited {
-		delete(p.visited, k)
+	p.outErr = nil
+	p.flushed = 0
+	p.depth = 0
+	p.level = 0
+	p.visited.clear()
+	p.prefixLen = 0
+}
+
+// printGoroutinePrefix starts the output with the "[goroutine id] " prefix
+// set by SetGoroutineID. It is not counted against the output budget, and
+// is omitted in GoLiteral mode, where it would make the output invalid Go.
+func (p *Printer) printGoroutinePrefix() {
+	if p.goroutineID == 0 || p.GoLiteral {
+		return
 	}
+	n := p.printBuf.Len()
+	fmt.Fprintf(&p.printBuf, "[goroutine %d] ", p.goroutineID)
+	p.prefixLen = int64(p.printBuf.Len() - n)
+}
+
+// SetGoroutineID sets the ID of the goroutine in whose context values are
+// being printed. The output of each later call to Sprint, Fprint,
+// SprintEntry, FprintEntry and SprintFrameEntry is prefixed with
+// "[goroutine id] ", to
+// correlate it with stack traces such as those from runtime.Stack, unless
+// GoLiteral is set. An id of zero removes the prefix.
+func (p *Printer) SetGoroutineID(id uint64) {
+	p.goroutineID = id
+}
+
+// WithOutputBudget returns a new Printer with the same settings as p, whose
+// printing operations produce at most maxBytes bytes of output. When the
+// budget is used up, printing stops and BudgetExceeded reports true.
+func (p *Printer) WithOutputBudget(maxBytes int64) *Printer {
+	q := new(Printer)
+	*q = *p
+	q.printBuf = bytes.Buffer{}
+	q.visited = cycleSet{}
+	q.budget = maxBytes
+	q.reset()
+	return q
+}
+
+// Truncated reports whether any part of the output of the last printing
+// operation was elided, such as the trailing elements of a long array or
+// the tail of a long string.
+func (p *Printer) Truncated() bool {
+	return p.truncated
+}
+
+// BudgetExceeded reports whether the last printing operation stopped
+// because the output budget set by WithOutputBudget was used up.
+func (p *Printer) BudgetExceeded() bool {
+	return p.exceeded
 }
 
 // Sprint returns the pretty-printed value of the item with the given name, such as "main.global".
@@ -77,48 +491,234 @@ func (p *Printer) Sprint(name string) (string, error) {
 		return "", err
 	}
 	p.reset()
+	p.printGoroutinePrefix()
+	p.printNamedEntry(entry, nil)
+	return p.printBuf.String(), p.err
+}
+
+// Fprint writes the pretty-printed value of the item with the given name,
+// such as "main.global", to w. Unlike Sprint, it does not hold the whole
+// output in memory.
+func (p *Printer) Fprint(w io.Writer, name string) error {
+	entry, err := p.dwarf.LookupEntry(name)
+	if err != nil {
+		return err
+	}
+	return p.fprint(w, func() { p.printNamedEntry(entry, nil) })
+}
+
+// printNamedEntry pretty-prints the value of the item described by entry,
+// which is found by name and so must give its own location. regs, if
+// non-nil, holds the registers of the stack frame the item is in.
+func (p *Printer) printNamedEntry(entry *dwarf.Entry, regs *dwarf.Registers) {
 	switch entry.Tag {
-	case dwarf.TagVariable: // TODO: What other entries have global location attributes?
+	case dwarf.TagVariable, dwarf.TagFormalParameter:
 		var a uint64
-		iface := entry.Val(dwarf.AttrLocation)
-		if iface != nil {
-			a = p.decodeLocation(iface.([]byte))
+		var value []byte
+		if iface := entry.Val(dwarf.AttrLocation); iface != nil {
+			var ok bool
+			if a, value, ok = p.decodeLocation(entry, iface.([]byte), regs); !ok {
+				break
+			}
+		}
+		if value != nil {
+			if typ := p.entryType(entry); typ != nil {
+				if p.BeforeField != nil {
+					p.BeforeField(nil, 0)
+				}
+				p.printImplicitValue(typ, value)
+				if p.AfterField != nil {
+					p.AfterField(nil, 0)
+				}
+			}
+			break
 		}
 		p.printEntryValueAt(entry, a)
 	default:
 		p.errorf("unrecognized entry type %s", entry.Tag)
 	}
-	return p.printBuf.String(), p.err
 }
 
-// Figure 24 of DWARF v4.
-const (
-	locationAddr = 0x03
-)
+// decodeLocation evaluates the DWARF location expression data, read from
+// entry, describing a variable, using the registers regs if they are
+// non-nil. It returns the variable's address, shifted by the base address
+// of the DWARF data, or, if the variable has no address because the
+// compiler supplied its value in the expression or it is in a register,
+// the value in target byte order. If the expression can't be evaluated,
+// it records the error and returns ok == false.
+func (p *Printer) decodeLocation(entry *dwarf.Entry, data []byte, regs *dwarf.Registers) (a uint64, value []byte, ok bool) {
+	var loc dwarf.Location
+	var err error
+	if regs != nil {
+		loc, err = p.dwarf.EvalLocationRegs(entry, data, regs)
+	} else {
+		loc, err = p.dwarf.EvalLocation(entry, data)
+	}
+	if err != nil {
+		p.errorf("decoding location: %s", err)
+		return 0, nil, false
+	}
+	switch loc.Kind {
+	case dwarf.LocationValue, dwarf.LocationRegister:
+		value = make([]byte, 8)
+		p.arch.ByteOrder.PutUint64(value, loc.Value)
+		return 0, value, true
+	case dwarf.LocationImplicit:
+		return 0, loc.Implicit, true
+	}
+	return loc.Addr, nil, true
+}
 
-// decodeLocation decodes the dwarf data describing an address.
-func (p *Printer) decodeLocation(data []byte) uint64 {
-	switch data[0] {
-	case locationAddr:
-		return p.arch.Uintptr(data[1:])
-	default:
-		p.errorf("unimplemented location type %#x", data[0])
+// SprintFrameEntry returns the pretty-printed value of the local variable
+// or parameter described by entry, in the stack frame whose registers are
+// regs. Its location may refer to the registers, such as through
+// DW_OP_fbreg, or be a register itself.
+func (p *Printer) SprintFrameEntry(entry *dwarf.Entry, regs *dwarf.Registers) (string, error) {
+	p.reset()
+	p.printGoroutinePrefix()
+	p.printNamedEntry(entry, regs)
+	return p.printBuf.String(), p.err
+}
+
+// SprintAllGlobals returns the pretty-printed values of the global
+// variables whose names begin with pkgPrefix, such as "main.", keyed by
+// name. The value of a variable that can't be printed, for example
+// because it has no location or its location can't be decoded, is the
+// error instead. Declarations of variables defined elsewhere are skipped.
+func (p *Printer) SprintAllGlobals(pkgPrefix string) (map[string]string, error) {
+	vals := make(map[string]string)
+	r := p.dwarf.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		if entry.Tag == dwarf.TagSubprogram {
+			// Skip local variables.
+			r.SkipChildren()
+			continue
+		}
+		if entry.Tag != dwarf.TagVariable {
+			continue
+		}
+		if decl, _ := entry.Val(dwarf.AttrDeclaration).(bool); decl {
+			// The variable is defined by another entry.
+			continue
+		}
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		if !strings.HasPrefix(name, pkgPrefix) {
+			continue
+		}
+		if entry.Val(dwarf.AttrLocation) == nil {
+			vals[name] = "no location"
+			continue
+		}
+		p.reset()
+		p.printNamedEntry(entry, nil)
+		if p.err != nil {
+			vals[name] = p.err.Error()
+		} else {
+			vals[name] = p.printBuf.String()
+		}
 	}
-	return 0
+	return vals, nil
 }
 
 // SprintEntry returns the pretty-printed value of the item with the specified DWARF Entry and address.
 func (p *Printer) SprintEntry(entry *dwarf.Entry, a uint64) (string, error) {
 	p.reset()
+	p.printGoroutinePrefix()
 	p.printEntryValueAt(entry, a)
 	return p.printBuf.String(), p.err
 }
 
+// FprintEntry writes the pretty-printed value of the item with the
+// specified DWARF Entry and address to w.
+func (p *Printer) FprintEntry(w io.Writer, entry *dwarf.Entry, a uint64) error {
+	return p.fprint(w, func() { p.printEntryValueAt(entry, a) })
+}
+
+// SprintAt returns the pretty-printed value of the specified type at
+// address a, for values that have no DWARF Entry of their own, such as
+// objects on the heap.
+func (p *Printer) SprintAt(typ dwarf.Type, a uint64) (string, error) {
+	p.reset()
+	p.printFieldAt(nil, typ, a)
+	return p.printBuf.String(), p.err
+}
+
+// SprintSliceRange returns the pretty-printed elements low to high-1 of
+// the slice of the specified type at address a, each preceded by its
+// index, as in "[]int{[3]: 42, [4]: 43}". The bounds must satisfy
+// 0 <= low <= high <= len. A high of -1 means the end of the slice, with
+// at most MaxArrayElements elements printed.
+func (p *Printer) SprintSliceRange(typ *dwarf.SliceType, a uint64, low, high int64) (string, error) {
+	p.reset()
+	s, err := p.server.peekSlice(typ, a)
+	if err != nil {
+		return "", err
+	}
+	length := int64(s.Length)
+	limited := high == -1
+	if limited {
+		high = length
+	}
+	if low < 0 || low > high || high > length {
+		return "", fmt.Errorf("slice bounds [%d:%d] out of range for length %d", low, high, length)
+	}
+	n := high - low
+	if max := p.maxArrayElements(); limited && n > max {
+		n = max
+	}
+	size, ok := p.sizeof(typ.ElemType)
+	if !ok {
+		return "", fmt.Errorf("can't determine element size")
+	}
+	p.printf("%s{", p.typeName(typ))
+	p.beginElems()
+	elem := s.Address + uint64(low)*size
+	for i := int64(0); i < n; i++ {
+		p.printElemSep(int(i), ", ")
+		p.printf("[%d]: ", low+i)
+		p.printValueAt(typ.ElemType, elem)
+		elem += size
+	}
+	shown := int(n)
+	if low+n < high {
+		p.printElemSep(shown, ", ")
+		p.printf("...")
+		p.truncated = true
+		shown++
+	}
+	p.endElems(shown)
+	p.printf("}")
+	return p.printBuf.String(), p.err
+}
+
+// SprintMapKey returns the pretty-printed key of an entry of a map of the
+// specified type, where the key is at address keyAddr.
+func (p *Printer) SprintMapKey(typ *dwarf.MapType, keyAddr uint64) (string, error) {
+	p.reset()
+	p.printMapKeyAt(typ.KeyType, keyAddr)
+	return p.printBuf.String(), p.err
+}
+
+// SprintMapVal returns the pretty-printed value of an entry of a map of the
+// specified type, where the value is at address valAddr.
+func (p *Printer) SprintMapVal(typ *dwarf.MapType, valAddr uint64) (string, error) {
+	p.reset()
+	p.printValueAt(typ.ElemType, valAddr)
+	return p.printBuf.String(), p.err
+}
+
 // printEntryValueAt pretty-prints the data at the specified address.
 // using the type information in the Entry.
 func (p *Printer) printEntryValueAt(entry *dwarf.Entry, a uint64) {
 	if a == 0 {
-		p.printf("<nil>")
+		p.printNil()
 		return
 	}
 	switch entry.Tag {
@@ -128,30 +728,155 @@ func (p *Printer) printEntryValueAt(entry *dwarf.Entry, a uint64) {
 		p.errorf("unrecognized entry type %s", entry.Tag)
 		return
 	}
+	if typ := p.entryType(entry); typ != nil {
+		p.printFieldAt(nil, typ, a)
+	}
+}
+
+// entryType returns the type of the item described by entry, or nil after
+// recording an error.
+func (p *Printer) entryType(entry *dwarf.Entry) dwarf.Type {
 	iface := entry.Val(dwarf.AttrType)
 	if iface == nil {
 		p.errorf("no type")
-		return
+		return nil
 	}
 	typ, err := p.dwarf.Type(iface.(dwarf.Offset))
 	if err != nil {
 		p.errorf("type lookup: %v", err)
+		return nil
+	}
+	return typ
+}
+
+// printImplicitValue pretty-prints a value of the given type that is held
+// in buf rather than in the program's memory. Values produced by
+// DW_OP_stack_value are a full 8 bytes and are trimmed to the type's size.
+func (p *Printer) printImplicitValue(typ dwarf.Type, buf []byte) {
+	for {
+		t, ok := typ.(*dwarf.TypedefType)
+		if !ok {
+			break
+		}
+		typ = t.Type
+	}
+	size := int(typ.Size())
+	if size <= 0 || len(buf) < size {
+		p.errorf("implicit value of %d bytes does not fit type %s", len(buf), typ)
+		return
+	}
+	if p.arch.ByteOrder == binary.BigEndian {
+		buf = buf[len(buf)-size:]
+	} else {
+		buf = buf[:size]
+	}
+	switch typ := typ.(type) {
+	case *dwarf.BoolType:
+		p.printf("%t", buf[0] != 0)
+	case *dwarf.IntType:
+		p.printf("%d", p.arch.IntN(buf))
+	case *dwarf.UintType:
+		p.printf("%d", p.arch.UintN(buf))
+	case *dwarf.PtrType:
+		p.printf("%#x", p.arch.UintN(buf))
+	case *dwarf.FloatType:
+		switch size {
+		case 4:
+			p.printf("%g", p.arch.Float32(buf))
+		case 8:
+			p.printf("%g", p.arch.Float64(buf))
+		default:
+			p.errorf("unrecognized float size %d", size)
+		}
+	default:
+		p.errorf("unimplemented implicit value of type %s", typ)
+	}
+}
+
+// printFieldAt pretty-prints the data at the specified address, which holds
+// the given struct field, calling the BeforeField and AfterField callbacks.
+// The field is nil for a top-level value.
+func (p *Printer) printFieldAt(field *dwarf.StructField, typ dwarf.Type, a uint64) {
+	if p.BeforeField != nil {
+		p.BeforeField(field, a)
+	}
+	if field != nil && field.BitSize != 0 {
+		p.printBitFieldAt(field, a-uint64(field.ByteOffset))
+	} else {
+		p.printValueAt(typ, a)
+	}
+	if p.AfterField != nil {
+		p.AfterField(field, a)
+	}
+}
+
+// printBitFieldAt prints the value of a bit field of the struct at a.
+// The field's BitOffset is DW_AT_bit_offset, which counts from the most
+// significant bit of the integer in the ByteSize bytes holding the field,
+// so it is passed to ExtractBitField unchanged.
+func (p *Printer) printBitFieldAt(field *dwarf.StructField, a uint64) {
+	size := field.ByteSize
+	if size == 0 {
+		size = field.Type.Size()
+	}
+	if size <= 0 || size > 8 {
+		p.errorf("bad bit field %s", field.Name)
 		return
 	}
-	p.printValueAt(typ, a)
+	buf := make([]byte, size)
+	if err := p.server.peekBytes(a+uint64(field.ByteOffset), buf); err != nil {
+		p.errorf("reading bit field: %s", err)
+		return
+	}
+	u, ok := p.arch.ExtractBitField(buf, field.BitOffset, field.BitSize)
+	if !ok {
+		p.errorf("bad bit field %s", field.Name)
+		return
+	}
+	bits := uint(field.BitSize)
+	switch followTypedefs(field.Type).(type) {
+	case *dwarf.IntType, *dwarf.CharType:
+		p.printf("%d", int64(u<<(64-bits))>>(64-bits))
+	case *dwarf.BoolType:
+		p.printf("%t", u != 0)
+	default:
+		p.printf("%d", u)
+	}
 }
 
+// defaultCycleSentinelFormat is the default value of
+// Printer.CycleSentinelFormat.
+const defaultCycleSentinelFormat = "(%v %#x)"
+
 // printValueAt pretty-prints the data at the specified address.
 // using the provided type information.
 func (p *Printer) printValueAt(typ dwarf.Type, a uint64) {
+	if p.exceeded {
+		return
+	}
+	maxDepth := p.MaxDepth
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxDepth
+	}
+	if p.depth >= maxDepth {
+		p.printMarker("max depth")
+		p.truncated = true
+		return
+	}
+	p.depth++
+	defer func() { p.depth-- }()
 	if a != 0 {
 		// Check if we are repeating the same type and address.
 		ta := typeAndAddress{typ, a}
-		if p.visited[ta] {
-			p.printf("(%v %#x)", typ, a)
+		if p.visited.contains(ta) {
+			format := p.CycleSentinelFormat
+			if format == "" {
+				format = defaultCycleSentinelFormat
+			}
+			p.printf(format, p.typeName(typ), a)
 			return
 		}
-		p.visited[ta] = true
+		p.visited.add(ta)
 	}
 	switch typ := typ.(type) {
 	case *dwarf.BoolType:
@@ -165,10 +890,26 @@ func (p *Printer) printValueAt(typ dwarf.Type, a uint64) {
 			p.printf("%t", b != 0)
 		}
 	case *dwarf.PtrType:
-		if ptr, err := p.server.peekPtr(a); err != nil {
+		if !p.mapped(a) {
+			p.printMarker("unmapped @%#x", a)
+		} else if ptr, err := p.server.peekPtr(a); err != nil {
 			p.errorf("reading pointer: %s", err)
+		} else if ptr == 0 {
+			p.printNil()
+		} else if p.inStack(ptr) {
+			p.printMarker("stack @%#x", ptr)
+		} else if p.FollowPointers && !isVoid(typ.Type) {
+			p.printf("(*%s) ", p.addrLink(ptr))
+			if p.mapped(ptr) {
+				p.printValueAt(typ.Type, ptr)
+			} else {
+				p.printMarker("unmapped @%#x", ptr)
+			}
 		} else {
-			p.printf("%#x", ptr)
+			p.printf("%s", p.addrLink(ptr))
+			if _, fn := typ.Type.(*dwarf.FuncType); fn && p.ResolveFuncNames {
+				p.printFuncNameComment(ptr)
+			}
 		}
 	case *dwarf.IntType:
 		// Sad we can't tell a rune from an int32.
@@ -183,6 +924,28 @@ func (p *Printer) printValueAt(typ dwarf.Type, a uint64) {
 		} else {
 			p.printf("%d", u)
 		}
+	case *dwarf.CharType:
+		if i, err := p.server.peekInt(a, typ.ByteSize); err != nil {
+			p.errorf("reading char: %s", err)
+		} else if p.GoLiteral {
+			p.printf("%d", i)
+		} else {
+			p.printChar(i, byte(i))
+		}
+	case *dwarf.UcharType:
+		if u, err := p.server.peekUint(a, typ.ByteSize); err != nil {
+			p.errorf("reading unsigned char: %s", err)
+		} else if p.GoLiteral {
+			p.printf("%d", u)
+		} else {
+			p.printChar(u, byte(u))
+		}
+	case *dwarf.AddrType:
+		if u, err := p.server.peekUint(a, int64(p.arch.PointerSize)); err != nil {
+			p.errorf("reading address: %s", err)
+		} else {
+			p.printf("%s", p.addrLink(u))
+		}
 	case *dwarf.FloatType:
 		buf := make([]byte, typ.ByteSize)
 		if err := p.server.peekBytes(a, buf); err != nil {
@@ -212,19 +975,38 @@ func (p *Printer) printValueAt(typ dwarf.Type, a uint64) {
 			p.errorf("unrecognized complex size %d", typ.ByteSize)
 		}
 	case *dwarf.StructType:
-		if typ.Kind != "struct" {
-			// Could be "class" or "union".
-			p.errorf("can't handle struct type %s", typ.Kind)
+		if typ.ReflectKind == reflect.String {
+			// A Go string header that was not recognized as a StringType.
+			p.printStringAt(&dwarf.StringType{StructType: *typ}, a)
+			return
+		}
+		if typ.Kind == "union" {
+			p.printUnionAt(typ, a)
+			return
+		}
+		// A class is printed just like a struct.
+		if p.HTMLMode {
+			p.printStructTableAt(typ, a)
+			return
+		}
+		if p.GoLiteral {
+			p.printStructLiteralAt(typ, a)
 			return
 		}
 		p.printf("%s {", typ.String())
-		for i, field := range typ.Field {
-			if i != 0 {
-				p.printf(", ")
+		p.beginElems()
+		fields := p.structFields(typ)
+		for i, field := range fields {
+			p.printElemSep(i, ", ")
+			if field.Embedded {
+				p.printf("%s: ", embeddedTypeName(field))
 			}
-			p.printValueAt(field.Type, a+uint64(field.ByteOffset))
+			p.printFieldAt(field, field.Type, a+uint64(field.ByteOffset))
 		}
+		p.endElems(len(fields))
 		p.printf("}")
+	case *dwarf.EnumType:
+		p.printEnumAt(typ, a)
 	case *dwarf.ArrayType:
 		p.printArrayAt(typ, a)
 	case *dwarf.InterfaceType:
@@ -238,38 +1020,312 @@ func (p *Printer) printValueAt(typ dwarf.Type, a uint64) {
 	case *dwarf.StringType:
 		p.printStringAt(typ, a)
 	case *dwarf.TypedefType:
+		switch typ.Type.(type) {
+		case nil:
+			// The typedef's type has not been read, as for some
+			// forward declarations in C.
+			p.printMarker("forward-declared typedef %s", typ.Name)
+		case *dwarf.VoidType:
+			p.printMarker("void")
+		default:
+			p.printValueAt(typ.Type, a)
+		}
+	case *dwarf.QualType:
 		p.printValueAt(typ.Type, a)
 	case *dwarf.FuncType:
-		p.printf("%v @%#x ", typ, a)
+		name := p.typeName(typ)
+		if p.ResolveFuncNames && p.dwarf != nil {
+			if fn, err := p.dwarf.FuncNameAtPC(a); err == nil {
+				name = fn
+				if p.HTMLMode {
+					name = html.EscapeString(name)
+				}
+			}
+		}
+		p.printf("%s @%s ", name, p.addrLink(a))
 	case *dwarf.VoidType:
 		p.printf("void")
+	case *dwarf.UnspecifiedType:
+		// Values of unknown types, such as GCC's __unknown_type, are
+		// printed as raw bytes, if they have a size.
+		if typ.ByteSize <= 0 {
+			p.printMarker("unspecified")
+			return
+		}
+		n := typ.ByteSize
+		if max := int64(p.maxStringBytes()); n > max {
+			n = max
+		}
+		buf := make([]byte, n)
+		if err := p.server.peekBytes(a, buf); err != nil {
+			p.errorf("reading value of unspecified type: %s", err)
+			return
+		}
+		p.printf("%#x", buf)
+		if n < typ.ByteSize {
+			p.printf("...")
+			p.truncated = true
+		}
+	case *dwarf.NamelistType:
+		// The items are separate variables; just describe them.
+		p.printf("%s", p.typeName(typ))
 	default:
 		p.errorf("unimplemented type %v", typ)
 	}
 }
 
+// printStructLiteralAt prints a struct in Go syntax, as T{Field: value}.
+// Fields without a name are printed as just their value, and embedded
+// fields are keyed by their type name.
+func (p *Printer) printStructLiteralAt(typ *dwarf.StructType, a uint64) {
+	p.printf("%s{", literalTypeName(typ))
+	p.beginElems()
+	fields := p.structFields(typ)
+	for i, field := range fields {
+		p.printElemSep(i, ", ")
+		switch {
+		case field.Embedded:
+			p.printf("%s: ", embeddedTypeName(field))
+		case field.Name != "":
+			p.printf("%s: ", field.Name)
+		}
+		p.printFieldAt(field, field.Type, a+uint64(field.ByteOffset))
+	}
+	p.endElems(len(fields))
+	p.printf("}")
+}
+
+// isVoid reports whether t is the type pointed to by a void pointer: a
+// VoidType, or the zero-size UnspecifiedType used by DWARF 3 and later.
+func isVoid(t dwarf.Type) bool {
+	switch t := t.(type) {
+	case *dwarf.VoidType:
+		return true
+	case *dwarf.UnspecifiedType:
+		return t.ByteSize == 0
+	}
+	return false
+}
+
+// literalTypeName returns the name of a struct type as used in a Go
+// composite literal, without the "struct" keyword.
+func literalTypeName(typ *dwarf.StructType) string {
+	if typ.StructName != "" {
+		return typ.StructName
+	}
+	return typ.String()
+}
+
+// embeddedTypeName returns the name by which a Go embedded field is
+// referred to: the name of its type, without the package, type arguments
+// or a pointer.
+func embeddedTypeName(f *dwarf.StructField) string {
+	t := f.Type
+	if ptr, ok := t.(*dwarf.PtrType); ok {
+		t = ptr.Type
+	}
+	var name string
+	switch t := t.(type) {
+	case nil:
+	case *dwarf.StructType:
+		name = t.StructName
+	default:
+		name = t.Common().Name
+	}
+	if name == "" {
+		return f.Name
+	}
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// printUnionAt prints a union as the value of its first field. The other
+// fields share the same storage, so they are only counted.
+func (p *Printer) printUnionAt(typ *dwarf.StructType, a uint64) {
+	if p.GoLiteral {
+		p.printf("%s{", literalTypeName(typ))
+	} else {
+		p.printf("%s {", p.typeName(typ))
+	}
+	switch {
+	case typ.Incomplete:
+		p.printMarker("incomplete")
+	case len(typ.Field) > 0:
+		f := typ.Field[0]
+		name := f.Name
+		if p.HTMLMode {
+			name = html.EscapeString(name)
+		}
+		p.printf("%s: ", name)
+		p.printFieldAt(f, f.Type, a+uint64(f.ByteOffset))
+		switch n := len(typ.Field) - 1; n {
+		case 0:
+		case 1:
+			p.printf(" /* or 1 other field */")
+		default:
+			p.printf(" /* or %d other fields */", n)
+		}
+	}
+	p.printf("}")
+}
+
+// beginElems starts printing the elements of a compound value, after its
+// opening brace.
+func (p *Printer) beginElems() {
+	p.level++
+}
+
+// printElemSep prints what precedes the i'th element of a compound value:
+// sep, for all but the first element, or, if there is an Indent, a new
+// line.
+func (p *Printer) printElemSep(i int, sep string) {
+	if p.Indent == "" {
+		if i > 0 {
+			p.write(sep)
+		}
+		return
+	}
+	if i > 0 {
+		p.write(strings.TrimRight(sep, " "))
+	}
+	p.write("\n" + strings.Repeat(p.Indent, p.level))
+}
+
+// endElems finishes printing the n elements of a compound value, before
+// its closing brace.
+func (p *Printer) endElems(n int) {
+	p.level--
+	if p.Indent != "" && n > 0 {
+		p.write("\n" + strings.Repeat(p.Indent, p.level))
+	}
+}
+
+// structFields returns the fields of typ that are printed, in the order
+// they are printed.
+func (p *Printer) structFields(typ *dwarf.StructType) []*dwarf.StructField {
+	fields := typ.Field
+	for i, f := range typ.Field {
+		if !p.showField(f) {
+			// Copy the fields up to the first one left out, and the
+			// printed fields after it.
+			fields = append([]*dwarf.StructField(nil), typ.Field[:i]...)
+			for _, f := range typ.Field[i+1:] {
+				if p.showField(f) {
+					fields = append(fields, f)
+				}
+			}
+			break
+		}
+	}
+	if !p.ReverseFields {
+		return fields
+	}
+	reversed := make([]*dwarf.StructField, len(fields))
+	for i, f := range fields {
+		reversed[len(reversed)-1-i] = f
+	}
+	return reversed
+}
+
+// showField reports whether the struct field f is printed.
+func (p *Printer) showField(f *dwarf.StructField) bool {
+	if f.IsArtificial && !p.ShowArtificialFields {
+		return false
+	}
+	return p.FieldFilter == nil || p.FieldFilter(f)
+}
+
+// NewFieldFilter returns a FieldFilter that selects the fields with the
+// given names.
+func NewFieldFilter(names ...string) func(*dwarf.StructField) bool {
+	m := make(map[string]bool, len(names))
+	for _, name := range names {
+		m[name] = true
+	}
+	return func(f *dwarf.StructField) bool { return m[f.Name] }
+}
+
+// printStructTableAt prints a struct as an HTML table with a row per field.
+func (p *Printer) printStructTableAt(typ *dwarf.StructType, a uint64) {
+	p.printf("%s <table>", p.typeName(typ))
+	for _, field := range p.structFields(typ) {
+		name := field.Name
+		if field.Embedded {
+			name = embeddedTypeName(field)
+		}
+		p.printf("<tr><td>%s</td><td>", html.EscapeString(name))
+		p.printFieldAt(field, field.Type, a+uint64(field.ByteOffset))
+		p.printf("</td></tr>")
+	}
+	p.printf("</table>")
+}
+
+// printEnumAt prints the name of the enumeration constant at a, or its
+// value if it matches no constant.
+func (p *Printer) printEnumAt(typ *dwarf.EnumType, a uint64) {
+	u, err := p.server.peekUint(a, typ.ByteSize)
+	if err != nil {
+		p.errorf("reading enum: %s", err)
+		return
+	}
+	// The constants of an enum with an unsigned underlying type may have
+	// large positive values, so accept a match either way.
+	i := int64(u)
+	if bits := uint(typ.ByteSize * 8); bits < 64 {
+		i = i << (64 - bits) >> (64 - bits)
+	}
+	if name, ok := typ.LookupByValue(i); ok {
+		p.printf("%s", name)
+		return
+	}
+	if name, ok := typ.LookupByValue(int64(u)); ok {
+		p.printf("%s", name)
+		return
+	}
+	name := "enum"
+	if typ.EnumName != "" {
+		name = typ.EnumName
+	}
+	p.printf("%d /* unknown %s value */", i, name)
+}
+
 func (p *Printer) printArrayAt(typ *dwarf.ArrayType, a uint64) {
 	elemType := typ.Type
-	length := typ.Count
+	length, ok := typ.ElementCount()
+	if !ok {
+		// An incomplete array, like char x[], whose length is unknown.
+		p.printf("[...]%s", p.typeName(elemType))
+		p.truncated = true
+		return
+	}
 	stride, ok := p.arrayStride(typ)
 	if !ok {
 		p.errorf("can't determine element size")
 	}
-	p.printf("%s{", typ)
+	p.printf("%s{", p.typeName(typ))
+	max := p.maxArrayElements()
 	n := length
-	if n > 100 {
-		n = 100 // TODO: Have a way to control this?
+	if n > max {
+		n = max
 	}
+	p.beginElems()
 	for i := int64(0); i < n; i++ {
-		if i != 0 {
-			p.printf(", ")
-		}
+		p.printElemSep(int(i), ", ")
 		p.printValueAt(elemType, a)
 		a += stride // TODO: Alignment and padding - not given by Type
 	}
 	if n < length {
-		p.printf(", ...")
+		p.printElemSep(int(n), ", ")
+		p.printf("...")
+		p.truncated = true
+		n++
 	}
+	p.endElems(int(n))
 	p.printf("}")
 }
 
@@ -285,39 +1341,63 @@ func (p *Printer) printInterfaceAt(t *dwarf.InterfaceType, a uint64) {
 		p.errorf("bad interface type: not a typedef of a struct")
 		return
 	}
+	if len(st.Field) > 0 && st.Field[0].Name == "_type" {
+		p.printEfaceAt(st, a)
+		return
+	}
 	p.printf("(")
 	tab, err := p.server.peekPtrStructField(st, a, "tab")
 	if err != nil {
 		p.errorf("reading interface type: %s", err)
 	} else {
-		f, err := getField(st, "tab")
+		f, err := st.FieldByName("tab")
 		if err != nil {
 			p.errorf("%s", err)
-		} else {
+		} else if tab == 0 || p.checkMapped(tab) {
 			p.printTypeOfInterface(f.Type, tab)
 		}
 	}
 	p.printf(", ")
+	p.printInterfaceData(st, a)
+	p.printf(")")
+}
+
+// printEfaceAt prints an empty interface, whose struct st has the layout
+// of runtime.eface: a _type pointer to the dynamic type, rather than the
+// tab pointer to an itab of a non-empty interface, and a data pointer.
+func (p *Printer) printEfaceAt(st *dwarf.StructType, a uint64) {
+	p.printf("(")
+	typeAddr, err := p.server.peekPtrStructField(st, a, "_type")
+	if err != nil {
+		p.errorf("reading interface type: %s", err)
+	} else if typeAddr == 0 || p.checkMapped(typeAddr) {
+		p.printRuntimeType(st.Field[0].Type, typeAddr)
+	}
+	p.printf(", ")
+	p.printInterfaceData(st, a)
+	p.printf(")")
+}
+
+// printInterfaceData prints the data pointer of the interface at a, whose
+// layout is given by st.
+func (p *Printer) printInterfaceData(st *dwarf.StructType, a uint64) {
 	data, err := p.server.peekPtrStructField(st, a, "data")
 	if err != nil {
 		p.errorf("reading interface value: %s", err)
 	} else if data == 0 {
-		p.printf("<nil>")
+		p.printNil()
 	} else {
-		p.printf("%#x", data)
+		p.printf("%s", p.addrLink(data))
 	}
-	p.printf(")")
 }
 
 // printTypeOfInterface prints the type of the given tab pointer.
 func (p *Printer) printTypeOfInterface(t dwarf.Type, a uint64) {
 	if a == 0 {
-		p.printf("<nil>")
+		p.printNil()
 		return
 	}
 	// t should be a pointer to a typedef binding a struct which contains a field _type.
-	// _type should be a pointer to a typedef binding a struct which contains a field _string.
-	// _string is the name of the type.
 	t1, ok := t.(*dwarf.PtrType)
 	if !ok {
 		p.errorf("bad type")
@@ -333,12 +1413,29 @@ func (p *Printer) printTypeOfInterface(t dwarf.Type, a uint64) {
 		p.errorf("bad type")
 		return
 	}
-	typeField, err := getField(t3, "_type")
+	typeField, err := t3.FieldByName("_type")
 	if err != nil {
 		p.errorf("%s", err)
 		return
 	}
-	t4, ok := typeField.Type.(*dwarf.PtrType)
+	typeAddr, err := p.server.peekPtrStructField(t3, a, "_type")
+	if err != nil {
+		p.errorf("reading interface type: %s", err)
+		return
+	}
+	p.printRuntimeType(typeField.Type, typeAddr)
+}
+
+// printRuntimeType prints the name of the runtime type descriptor at a,
+// given the type t of a _type field pointing to it.
+func (p *Printer) printRuntimeType(t dwarf.Type, a uint64) {
+	if a == 0 {
+		p.printNil()
+		return
+	}
+	// t should be a pointer to a typedef binding a struct which contains a field _string.
+	// _string is the name of the type.
+	t4, ok := t.(*dwarf.PtrType)
 	if !ok {
 		p.errorf("bad type")
 		return
@@ -353,7 +1450,7 @@ func (p *Printer) printTypeOfInterface(t dwarf.Type, a uint64) {
 		p.errorf("bad type")
 		return
 	}
-	stringField, err := getField(t6, "_string")
+	stringField, err := t6.FieldByName("_string")
 	if err != nil {
 		p.errorf("%s", err)
 		return
@@ -368,12 +1465,7 @@ func (p *Printer) printTypeOfInterface(t dwarf.Type, a uint64) {
 		p.errorf("bad type")
 		return
 	}
-	typeAddr, err := p.server.peekPtrStructField(t3, a, "_type")
-	if err != nil {
-		p.errorf("reading interface type: %s", err)
-		return
-	}
-	stringAddr, err := p.server.peekPtrStructField(t6, typeAddr, "_string")
+	stringAddr, err := p.server.peekPtrStructField(t6, a, "_string")
 	if err != nil {
 		p.errorf("reading interface type: %s", err)
 		return
@@ -381,37 +1473,220 @@ func (p *Printer) printTypeOfInterface(t dwarf.Type, a uint64) {
 	p.printStringAt(stringType, stringAddr)
 }
 
-// maxMapValuesToPrint values are printed for each map; any remaining values are
-// truncated to "...".
-const maxMapValuesToPrint = 8
-
 func (p *Printer) printMapAt(typ *dwarf.MapType, a uint64) {
+	maxMapPrint := p.maxMapEntries()
+	if p.SortMapKeys || p.MapKeyLess != nil {
+		p.printSortedMapAt(typ, a, maxMapPrint)
+		return
+	}
+	start, sep, colon, end := p.mapSyntax(typ)
 	count := 0
 	fn := func(keyAddr, valAddr uint64, keyType, valType dwarf.Type) (stop bool) {
 		count++
-		if count > maxMapValuesToPrint {
+		if count > maxMapPrint {
 			return false
 		}
-		if count > 1 {
-			p.printf(" ")
-		}
-		p.printValueAt(keyType, keyAddr)
-		p.printf(":")
+		p.printElemSep(count-1, sep)
+		p.printMapKeyAt(keyType, keyAddr)
+		p.printf("%s", colon)
 		p.printValueAt(valType, valAddr)
+		p.endMapChunk(count)
 		return true
 	}
-	p.printf("map[")
-	if err := p.server.peekMapValues(typ, a, fn); err != nil {
+	p.printf("%s", start)
+	p.beginElems()
+	p.peekMapValues(typ, a, fn)
+	if count > maxMapPrint {
+		p.printElemSep(maxMapPrint, sep)
+		p.printf("...")
+		p.truncated = true
+	}
+	p.endElems(count)
+	p.printf("%s", end)
+}
+
+// peekMapValues calls fn for each entry of the map at a, like
+// Server.peekMapValues, first checking the map's pointers with
+// ValidateAddrFn.
+func (p *Printer) peekMapValues(typ *dwarf.MapType, a uint64, fn func(keyAddr, valAddr uint64, keyType, valType dwarf.Type) bool) {
+	err := p.server.peekMapValuesChecked(typ, a, p.mapped, fn)
+	if u, ok := err.(unmappedError); ok {
+		p.printMarker("unmapped @%#x", uint64(u))
+	} else if err != nil {
 		p.errorf("reading map values: %s", err)
 	}
-	if count > maxMapValuesToPrint {
-		p.printf(" ...")
+}
+
+// endMapChunk flushes the output after the n'th entry of a map, if that
+// ends a chunk of MapChunkSize entries and there is a writer to flush to.
+func (p *Printer) endMapChunk(n int) {
+	if p.MapChunkSize > 0 && n%p.MapChunkSize == 0 && p.out != nil {
+		p.flush()
+	}
+}
+
+// mapSyntax returns the text that opens a map, separates its entries,
+// separates each key from its value, and closes the map: "map[", " ",
+// ":" and "]", or in Go syntax "map[K]V{", ", ", ": " and "}".
+func (p *Printer) mapSyntax(typ *dwarf.MapType) (start, sep, colon, end string) {
+	if p.GoLiteral {
+		return p.typeName(typ) + "{", ", ", ": ", "}"
+	}
+	return "map[", " ", ":", "]"
+}
+
+// A mapEntry holds the location of a key/value pair of a map, along with
+// the key's value if it is used for sorting.
+type mapEntry struct {
+	keyAddr, valAddr uint64
+	keyType, valType dwarf.Type
+	intKey           int64
+	uintKey          uint64
+	stringKey        string
+}
+
+type byIntKey []mapEntry
+
+func (e byIntKey) Len() int           { return len(e) }
+func (e byIntKey) Less(i, j int) bool { return e[i].intKey < e[j].intKey }
+func (e byIntKey) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+type byUintKey []mapEntry
+
+func (e byUintKey) Len() int           { return len(e) }
+func (e byUintKey) Less(i, j int) bool { return e[i].uintKey < e[j].uintKey }
+func (e byUintKey) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+type byStringKey []mapEntry
+
+func (e byStringKey) Len() int           { return len(e) }
+func (e byStringKey) Less(i, j int) bool { return e[i].stringKey < e[j].stringKey }
+func (e byStringKey) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+
+// byKeyLess sorts map entries using a Printer's MapKeyLess function.
+type byKeyLess struct {
+	entries []mapEntry
+	less    func(keyTypeA, keyTypeB dwarf.Type, addrA, addrB uint64) bool
+}
+
+func (e byKeyLess) Len() int { return len(e.entries) }
+func (e byKeyLess) Less(i, j int) bool {
+	a, b := &e.entries[i], &e.entries[j]
+	return e.less(a.keyType, b.keyType, a.keyAddr, b.keyAddr)
+}
+func (e byKeyLess) Swap(i, j int) { e.entries[i], e.entries[j] = e.entries[j], e.entries[i] }
+
+// maxSortedMapKeySize is the number of bytes of each string key that are
+// compared when sorting a map.
+const maxSortedMapKeySize = 1024
+
+// printSortedMapAt prints a map with its entries sorted by key.
+// It must read every entry of the map, not just those that are printed.
+func (p *Printer) printSortedMapAt(typ *dwarf.MapType, a uint64, maxMapPrint int) {
+	var entries []mapEntry
+	fn := func(keyAddr, valAddr uint64, keyType, valType dwarf.Type) (stop bool) {
+		entries = append(entries, mapEntry{keyAddr: keyAddr, valAddr: valAddr, keyType: keyType, valType: valType})
+		return true
+	}
+	start, sep, colon, end := p.mapSyntax(typ)
+	p.printf("%s", start)
+	p.peekMapValues(typ, a, fn)
+	p.sortMapEntries(entries)
+	p.beginElems()
+	for i, e := range entries {
+		p.printElemSep(i, sep)
+		if i == maxMapPrint {
+			p.printf("...")
+			p.truncated = true
+			break
+		}
+		p.printMapKeyAt(e.keyType, e.keyAddr)
+		p.printf("%s", colon)
+		p.printValueAt(e.valType, e.valAddr)
+		p.endMapChunk(i + 1)
+	}
+	p.endElems(len(entries))
+	p.printf("%s", end)
+}
+
+// sortMapEntries sorts entries by key. If MapKeyLess is set, it is used to
+// compare the keys. Otherwise integer and string keys are compared by value,
+// and other keys by their printed form. If any key can't be read, the
+// entries are left in their original order.
+func (p *Printer) sortMapEntries(entries []mapEntry) {
+	if len(entries) < 2 {
+		return
+	}
+	if p.MapKeyLess != nil {
+		sort.Stable(byKeyLess{entries, p.MapKeyLess})
+		return
+	}
+	var err error
+	switch kt := followTypedefs(entries[0].keyType).(type) {
+	case *dwarf.IntType:
+		for i := range entries {
+			if entries[i].intKey, err = p.server.peekInt(entries[i].keyAddr, kt.ByteSize); err != nil {
+				return
+			}
+		}
+		sort.Stable(byIntKey(entries))
+	case *dwarf.UintType:
+		for i := range entries {
+			if entries[i].uintKey, err = p.server.peekUint(entries[i].keyAddr, kt.ByteSize); err != nil {
+				return
+			}
+		}
+		sort.Stable(byUintKey(entries))
+	case *dwarf.StringType:
+		for i := range entries {
+			if entries[i].stringKey, err = p.server.peekString(kt, entries[i].keyAddr, maxSortedMapKeySize); err != nil {
+				return
+			}
+		}
+		sort.Stable(byStringKey(entries))
+	default:
+		// The keys are printed by a separate Printer, so that the output
+		// and callbacks of p are unaffected. It has the limits of p and
+		// reads only what p would read.
+		q := NewPrinter(p.arch, p.dwarf, p.server, PrinterOptions{
+			MaxArrayElements: p.MaxArrayElements,
+			MaxMapEntries:    p.maxMapEntries(),
+			MaxStringBytes:   p.MaxStringBytes,
+			MaxDepth:         p.MaxDepth,
+			ValidateAddrFn:   p.ValidateAddrFn,
+		})
+		q.StackRange = p.StackRange
+		for i := range entries {
+			if entries[i].stringKey, err = q.sprintKey(entries[i].keyType, entries[i].keyAddr); err != nil {
+				return
+			}
+		}
+		sort.Stable(byStringKey(entries))
+	}
+}
+
+// sprintKey returns the printed form of a map key, for sorting.
+func (p *Printer) sprintKey(keyType dwarf.Type, a uint64) (string, error) {
+	p.reset()
+	p.printValueAt(keyType, a)
+	return p.printBuf.String(), p.err
+}
+
+// printMapKeyAt prints a map key, truncating string keys to MaxMapKeyLen.
+func (p *Printer) printMapKeyAt(keyType dwarf.Type, a uint64) {
+	if st, ok := keyType.(*dwarf.StringType); ok && p.MaxMapKeyLen > 0 {
+		p.printStringLimitAt(st, a, uint64(p.MaxMapKeyLen))
+	} else {
+		p.printValueAt(keyType, a)
 	}
-	p.printf("]")
 }
 
 func (p *Printer) printChannelAt(ct *dwarf.ChanType, a uint64) {
-	p.printf("(chan %s ", ct.ElemType)
+	dir := ct.Direction.String()
+	if p.HTMLMode {
+		dir = html.EscapeString(dir)
+	}
+	p.printf("(%s %s ", dir, p.typeName(ct.ElemType))
 	defer p.printf(")")
 
 	a, err := p.server.peekPtr(a)
@@ -420,10 +1695,15 @@ func (p *Printer) printChannelAt(ct *dwarf.ChanType, a uint64) {
 		return
 	}
 	if a == 0 {
-		p.printf("<nil>")
+		p.printNil()
+		return
+	}
+	p.printf("%s", p.addrLink(a))
+	if !p.mapped(a) {
+		p.printf(" ")
+		p.checkMapped(a)
 		return
 	}
-	p.printf("%#x", a)
 
 	// ct is a typedef for a pointer to a struct.
 	pt, ok := ct.TypedefType.Type.(*dwarf.PtrType)
@@ -452,6 +1732,54 @@ func (p *Printer) printChannelAt(ct *dwarf.ChanType, a uint64) {
 	if qcount != 0 || dataqsiz != 0 {
 		p.printf(" [%d/%d]", qcount, dataqsiz)
 	}
+	if qcount == 0 || dataqsiz == 0 {
+		return
+	}
+	if qcount > dataqsiz {
+		// Not a valid channel; print only what fits in the buffer.
+		qcount = dataqsiz
+	}
+
+	// Print the buffered values, which are in a ring buffer starting at
+	// the index recvx.
+	buf, err := p.server.peekPtrStructField(st, a, "buf")
+	if err != nil {
+		p.errorf("reading channel: %s", err)
+		return
+	}
+	recvx, err := p.server.peekUintOrIntStructField(st, a, "recvx")
+	if err != nil {
+		// Assume the buffer starts at its first element.
+		recvx = 0
+	}
+	if !p.mapped(buf) {
+		p.printf(" ")
+		p.checkMapped(buf)
+		return
+	}
+	size, ok := p.sizeof(ct.ElemType)
+	if !ok {
+		p.errorf("can't determine element size")
+		return
+	}
+	n := qcount
+	if max := uint64(p.maxArrayElements()); n > max {
+		n = max
+	}
+	p.printf(" {")
+	p.beginElems()
+	for i := uint64(0); i < n; i++ {
+		p.printElemSep(int(i), ", ")
+		p.printValueAt(ct.ElemType, buf+(recvx+i)%dataqsiz*size)
+	}
+	if n < qcount {
+		p.printElemSep(int(n), ", ")
+		p.printf("...")
+		p.truncated = true
+		n++
+	}
+	p.endElems(int(n))
+	p.printf("}")
 }
 
 func (p *Printer) printSliceAt(typ *dwarf.SliceType, a uint64) {
@@ -473,31 +1801,108 @@ func (p *Printer) printSliceAt(typ *dwarf.SliceType, a uint64) {
 		p.errorf("reading slice: %s", err)
 		return
 	}
+	if length > 0 && !p.checkMapped(ptr) {
+		return
+	}
 	elemType := typ.ElemType
 	size, ok := p.sizeof(typ.ElemType)
 	if !ok {
 		p.errorf("can't determine element size")
 	}
-	p.printf("%s{", typ)
+	p.printf("%s{", p.typeName(typ))
+	p.beginElems()
 	for i := uint64(0); i < length; i++ {
-		if i != 0 {
-			p.printf(", ")
-		}
+		p.printElemSep(int(i), ", ")
 		p.printValueAt(elemType, ptr)
 		ptr += size // TODO: Alignment and padding - not given by Type
 	}
+	p.endElems(int(length))
 	p.printf("}")
 }
 
 func (p *Printer) printStringAt(typ *dwarf.StringType, a uint64) {
-	const maxStringSize = 100
-	if s, err := p.server.peekString(typ, a, maxStringSize); err != nil {
+	p.printStringLimitAt(typ, a, uint64(p.maxStringBytes()))
+}
+
+// maxArrayElements returns the number of elements printed for each array.
+func (p *Printer) maxArrayElements() int64 {
+	if p.MaxArrayElements > 0 {
+		return p.MaxArrayElements
+	}
+	return defaultMaxArrayElements
+}
+
+// maxMapEntries returns the number of entries printed for each map,
+// using the deprecated MaxMapPrint if MaxMapEntries is not set.
+func (p *Printer) maxMapEntries() int {
+	if p.MaxMapEntries > 0 {
+		return p.MaxMapEntries
+	}
+	if p.MaxMapPrint > 0 {
+		return p.MaxMapPrint
+	}
+	return defaultMaxMapEntries
+}
+
+// maxStringBytes returns the number of bytes printed for each string.
+func (p *Printer) maxStringBytes() int {
+	if p.MaxStringBytes > 0 {
+		return p.MaxStringBytes
+	}
+	return defaultMaxStringBytes
+}
+
+// printStringLimitAt prints the string at a, truncated to limit bytes.
+func (p *Printer) printStringLimitAt(typ *dwarf.StringType, a uint64, limit uint64) {
+	if s, err := p.server.peekString(typ, a, limit); err != nil {
 		p.errorf("reading string: %s", err)
 	} else {
-		p.printf("%q", s)
+		// peekString appends "..." to a truncated string.
+		if uint64(len(s)) > limit {
+			p.truncated = true
+		}
+		if p.HTMLMode {
+			p.write(html.EscapeString(strconv.Quote(s)))
+		} else {
+			p.printf("%q", s)
+		}
+	}
+}
+
+// checkMapped reports whether a may be read, according to ValidateAddrFn,
+// and if not prints an "unmapped" marker in place of the value at a.
+func (p *Printer) checkMapped(a uint64) bool {
+	if p.mapped(a) {
+		return true
+	}
+	p.printMarker("unmapped @%#x", a)
+	return false
+}
+
+// mapped reports whether a may be read, according to ValidateAddrFn.
+func (p *Printer) mapped(a uint64) bool {
+	return p.ValidateAddrFn == nil || p.ValidateAddrFn(a)
+}
+
+// printFuncNameComment prints the name of the function at pc, if known, as
+// a comment.
+func (p *Printer) printFuncNameComment(pc uint64) {
+	if p.dwarf == nil {
+		return
+	}
+	if name, err := p.dwarf.FuncNameAtPC(pc); err == nil {
+		if p.HTMLMode {
+			name = html.EscapeString(name)
+		}
+		p.printf(" /* %s */", name)
 	}
 }
 
+// inStack reports whether a lies within the printer's StackRange.
+func (p *Printer) inStack(a uint64) bool {
+	return p.StackRange[0] <= a && a < p.StackRange[1]
+}
+
 // sizeof returns the byte size of the type.
 func (p *Printer) sizeof(typ dwarf.Type) (uint64, bool) {
 	size := typ.Size() // Will be -1 if ByteSize is not set.
@@ -520,20 +1925,3 @@ func (p *Printer) arrayStride(t *dwarf.ArrayType) (uint64, bool) {
 	}
 	return p.sizeof(t.Type)
 }
-
-// getField finds the *dwarf.StructField in a dwarf.StructType with name fieldName.
-func getField(t *dwarf.StructType, fieldName string) (*dwarf.StructField, error) {
-	var r *dwarf.StructField
-	for _, f := range t.Field {
-		if f.Name == fieldName {
-			if r != nil {
-				return nil, fmt.Errorf("struct definition repeats field %s", fieldName)
-			}
-			r = f
-		}
-	}
-	if r == nil {
-		return nil, fmt.Errorf("struct field %s missing", fieldName)
-	}
-	return r, nil
-}
@@ -0,0 +1,2530 @@
+// Copyright 2015 The Go Authors.  All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/slog"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/debug/arch"
+	"golang.org/x/debug/dwarf"
+	"golang.org/x/debug/elf"
+)
+
+// fakeMemory is a sparse image of a target's address space.
+type fakeMemory map[uint64]byte
+
+func (m fakeMemory) peek(addr uint64, buf []byte) error {
+	for i := range buf {
+		b, ok := m[addr+uint64(i)]
+		if !ok {
+			return fmt.Errorf("bad address %#x", addr+uint64(i))
+		}
+		buf[i] = b
+	}
+	return nil
+}
+
+func (m fakeMemory) write(addr uint64, data []byte) {
+	for i, b := range data {
+		m[addr+uint64(i)] = b
+	}
+}
+
+func (m fakeMemory) writeUint(addr uint64, size int, v uint64) {
+	for i := 0; i < size; i++ {
+		m[addr+uint64(i)] = byte(v >> (8 * uint(i)))
+	}
+}
+
+// newTestPrinter returns a Printer for amd64 that reads from mem.
+func newTestPrinter(mem fakeMemory) *Printer {
+	s := &Server{arch: arch.AMD64, peekHook: mem.peek}
+	return NewPrinter(&arch.AMD64, nil, s)
+}
+
+// sprintValue prints the value of type typ at address a.
+func (p *Printer) sprintValue(typ dwarf.Type, a uint64) (string, error) {
+	p.reset()
+	p.printValueAt(typ, a)
+	return p.printBuf.String(), p.err
+}
+
+func TestPrintStackRange(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	p := newTestPrinter(mem)
+	p.StackRange = [2]uint64{0x7000, 0x8000}
+	ptr := &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: &dwarf.VoidType{}}
+	for _, test := range []struct {
+		ptr  uint64
+		want string
+	}{
+		{0x7000, "<stack @0x7000>"},
+		{0x7ff0, "<stack @0x7ff0>"},
+		{0x8000, "0x8000"}, // The range is half-open.
+		{0x6fff, "0x6fff"},
+	} {
+		mem.writeUint(addr, 8, test.ptr)
+		s, err := p.sprintValue(ptr, addr)
+		if err != nil {
+			t.Errorf("%#x: %v", test.ptr, err)
+		} else if s != test.want {
+			t.Errorf("%#x: got %s, want %s", test.ptr, s, test.want)
+		}
+	}
+
+	// With no StackRange, no pointer is in the stack.
+	p.StackRange = [2]uint64{}
+	mem.writeUint(addr, 8, 0x7ff0)
+	if s, err := p.sprintValue(ptr, addr); err != nil || s != "0x7ff0" {
+		t.Errorf("no stack range: got %s, %v, want 0x7ff0", s, err)
+	}
+}
+
+func TestPrintChanDir(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, 0)
+	p := newTestPrinter(mem)
+	i32 := &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4, Name: "int32"}}}
+	for dir, want := range map[dwarf.ChanDir]string{
+		dwarf.BothDir: "(chan int32 nil)",
+		dwarf.SendDir: "(chan<- int32 nil)",
+		dwarf.RecvDir: "(<-chan int32 nil)",
+	} {
+		typ := &dwarf.ChanType{
+			TypedefType: dwarf.TypedefType{CommonType: dwarf.CommonType{ByteSize: 8}},
+			ElemType:    i32,
+			Direction:   dir,
+		}
+		if s, err := p.sprintValue(typ, addr); err != nil || s != want {
+			t.Errorf("got %s, %v, want %s", s, err, want)
+		}
+	}
+}
+
+func TestPrintMapMaxPrint(t *testing.T) {
+	const addr, heap, data = 0x1000, 0x10000, 0x20000
+	i64 := &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8, Name: "int64"}}}
+	u8 := &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 1, Name: "uint8"}}}
+	ptrTo := func(t dwarf.Type) *dwarf.PtrType {
+		return &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: t}
+	}
+	str := &dwarf.StringType{StructType: dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16, Name: "string"},
+		StructName: "string",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "str", Type: ptrTo(u8), ByteOffset: 0},
+			{Name: "len", Type: i64, ByteOffset: 8},
+		},
+	}}
+
+	// writeMap writes a map with n entries at addr, in the layout read
+	// by peekMapValues, calling write to fill in each entry.
+	mem := make(fakeMemory)
+	writeMap := func(keyType dwarf.Type, n int, write func(i int, keyAddr, valAddr uint64)) *dwarf.MapType {
+		const bucketCnt = 8
+		keySize := uint64(keyType.Size())
+		valsOffset := 8 + bucketCnt*keySize
+		overflowOffset := valsOffset + bucketCnt*8
+		bucket := &dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: int64(overflowOffset) + 8},
+			StructName: "bucket",
+			Kind:       "struct",
+		}
+		bucket.Field = []*dwarf.StructField{
+			{Name: "tophash", Type: &dwarf.ArrayType{Type: u8, StrideBitSize: 8, Count: bucketCnt}, ByteOffset: 0},
+			{Name: "keys", Type: &dwarf.ArrayType{Type: keyType, StrideBitSize: 8 * int64(keySize), Count: bucketCnt}, ByteOffset: 8},
+			{Name: "values", Type: &dwarf.ArrayType{Type: i64, StrideBitSize: 64, Count: bucketCnt}, ByteOffset: int64(valsOffset)},
+			{Name: "overflow", Type: ptrTo(bucket), ByteOffset: int64(overflowOffset)},
+		}
+		hmap := &dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: 32},
+			StructName: "hmap",
+			Kind:       "struct",
+			Field: []*dwarf.StructField{
+				{Name: "count", Type: i64, ByteOffset: 0},
+				{Name: "B", Type: u8, ByteOffset: 8},
+				{Name: "buckets", Type: ptrTo(bucket), ByteOffset: 16},
+				{Name: "oldbuckets", Type: ptrTo(bucket), ByteOffset: 24},
+			},
+		}
+		b := uint(0)
+		for n > bucketCnt<<b {
+			b++
+		}
+		buckets := heap + uint64(hmap.ByteSize)
+		mem.writeUint(addr, 8, heap)
+		mem.writeUint(heap, 8, uint64(n))
+		mem.writeUint(heap+8, 1, uint64(b))
+		mem.writeUint(heap+16, 8, buckets)
+		mem.writeUint(heap+24, 8, 0)
+		mem.write(buckets, make([]byte, (1<<b)*bucket.ByteSize))
+		for i := 0; i < n; i++ {
+			ba := buckets + uint64(i/bucketCnt)*uint64(bucket.ByteSize)
+			j := uint64(i % bucketCnt)
+			mem.writeUint(ba+j, 1, 4+j)
+			write(i, ba+8+j*keySize, ba+valsOffset+j*8)
+		}
+		return &dwarf.MapType{
+			TypedefType: dwarf.TypedefType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: ptrTo(hmap)},
+			KeyType:     keyType,
+			ElemType:    i64,
+		}
+	}
+
+	typ := writeMap(i64, 10, func(i int, keyAddr, valAddr uint64) {
+		mem.writeUint(keyAddr, 8, uint64(i))
+		mem.writeUint(valAddr, 8, uint64(10*i))
+	})
+	p := newTestPrinter(mem)
+	for _, test := range []struct {
+		max  int
+		want string
+	}{
+		{0, "map[0:0 1:10 2:20 3:30 4:40 5:50 6:60 7:70 ...]"},
+		{3, "map[0:0 1:10 2:20 ...]"},
+		{10, "map[0:0 1:10 2:20 3:30 4:40 5:50 6:60 7:70 8:80 9:90]"},
+	} {
+		p.MaxMapPrint = test.max
+		if s, err := p.sprintValue(typ, addr); err != nil || s != test.want {
+			t.Errorf("MaxMapPrint %d: got %s, %v, want %s", test.max, s, err, test.want)
+		}
+	}
+	// MaxMapEntries, which replaces MaxMapPrint, takes precedence.
+	p.MaxMapPrint = 3
+	p.MaxMapEntries = 2
+	if s, err := p.sprintValue(typ, addr); err != nil || s != "map[0:0 1:10 ...]" {
+		t.Errorf("MaxMapEntries 2, MaxMapPrint 3: got %s, %v, want map[0:0 1:10 ...]", s, err)
+	}
+	p.MaxMapEntries = 0
+
+	// MaxMapKeyLen limits string keys.
+	typ = writeMap(str, 1, func(i int, keyAddr, valAddr uint64) {
+		mem.write(data, []byte("hello"))
+		mem.writeUint(keyAddr, 8, data)
+		mem.writeUint(keyAddr+8, 8, 5)
+		mem.writeUint(valAddr, 8, 1)
+	})
+	p.MaxMapKeyLen = 2
+	if s, err := p.sprintValue(typ, addr); err != nil || s != `map["he...":1]` {
+		t.Errorf("MaxMapKeyLen 2: got %s, %v, want map[\"he...\":1]", s, err)
+	}
+}
+
+func TestSprintMapKeyVal(t *testing.T) {
+	const keyAddr, valAddr, data = 0x1000, 0x1010, 0x2000
+	mem := make(fakeMemory)
+	mem.writeUint(keyAddr, 8, data)
+	mem.writeUint(keyAddr+8, 8, 5)
+	mem.write(data, []byte("hello"))
+	mem.writeUint(valAddr, 4, 1)
+	mem.writeUint(valAddr+4, 4, 2)
+	str := &dwarf.StringType{StructType: dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16, Name: "string"},
+		StructName: "string",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "str", Type: &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: intType(1)}, ByteOffset: 0},
+			{Name: "len", Type: intType(8), ByteOffset: 8},
+		},
+	}}
+	typ := &dwarf.MapType{KeyType: str, ElemType: pointStruct()}
+	p := newTestPrinter(mem)
+	if s, err := p.SprintMapKey(typ, keyAddr); err != nil || s != `"hello"` {
+		t.Errorf("SprintMapKey: got %s, %v, want \"hello\"", s, err)
+	}
+	if s, err := p.SprintMapVal(typ, valAddr); err != nil || s != "struct point {1, 2}" {
+		t.Errorf("SprintMapVal: got %s, %v, want struct point {1, 2}", s, err)
+	}
+
+	// MaxMapKeyLen limits keys, but not values.
+	p.MaxMapKeyLen = 2
+	if s, err := p.SprintMapKey(typ, keyAddr); err != nil || s != `"he..."` {
+		t.Errorf("SprintMapKey with MaxMapKeyLen 2: got %s, %v, want \"he...\"", s, err)
+	}
+	strMap := &dwarf.MapType{KeyType: intType(4), ElemType: str}
+	if s, err := p.SprintMapVal(strMap, keyAddr); err != nil || s != `"hello"` {
+		t.Errorf("SprintMapVal with MaxMapKeyLen 2: got %s, %v, want \"hello\"", s, err)
+	}
+}
+
+func TestPrintNamelist(t *testing.T) {
+	typ := &dwarf.NamelistType{
+		CommonType: dwarf.CommonType{Name: "nl"},
+		Items:      []dwarf.NamelistItem{{Name: "a", Type: intType(4)}, {Name: "b", Type: intType(8)}},
+	}
+	p := newTestPrinter(make(fakeMemory))
+	s, err := p.sprintValue(typ, 0x1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "namelist nl {a int32; b int64}"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestPrintComplex(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, uint64(math.Float32bits(1.5)))
+	mem.writeUint(addr+4, 4, uint64(math.Float32bits(-2.25)))
+	mem.writeUint(addr+8, 8, math.Float64bits(3))
+	mem.writeUint(addr+16, 8, math.Float64bits(0.5))
+
+	var reads []int
+	p := newTestPrinter(mem)
+	p.server.peekHook = func(a uint64, buf []byte) error {
+		reads = append(reads, len(buf))
+		return mem.peek(a, buf)
+	}
+
+	c64 := &dwarf.ComplexType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 8, Name: "complex64"}}}
+	s, err := p.sprintValue(c64, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(1.5-2.25i)"; s != want {
+		t.Errorf("complex64: got %s, want %s", s, want)
+	}
+	if len(reads) != 1 || reads[0] != 8 {
+		t.Errorf("complex64: got reads of %v bytes, want [8]", reads)
+	}
+
+	c128 := &dwarf.ComplexType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 16, Name: "complex128"}}}
+	s, err = p.sprintValue(c128, addr+8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(3+0.5i)"; s != want {
+		t.Errorf("complex128: got %s, want %s", s, want)
+	}
+
+	if _, err := p.sprintValue(c64, 0x2000); err == nil {
+		t.Error("complex64 at unmapped address: got no error")
+	}
+}
+
+func intType(size int64) *dwarf.IntType {
+	return &dwarf.IntType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: size, Name: fmt.Sprintf("int%d", 8*size)}}}
+}
+
+// pointStruct returns the type of struct point {x, y int32}.
+func pointStruct() *dwarf.StructType {
+	return &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 8},
+		StructName: "point",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "x", Type: intType(4), ByteOffset: 0, ByteSize: 4},
+			{Name: "y", Type: intType(4), ByteOffset: 4, ByteSize: 4},
+		},
+	}
+}
+
+func TestPrintFieldCallbacks(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 3)
+	mem.writeUint(addr+4, 4, 4)
+	p := newTestPrinter(mem)
+	p.BeforeField = func(f *dwarf.StructField, a uint64) {
+		if f != nil {
+			p.Annotate(fmt.Sprintf("%s@%#x=", f.Name, a))
+		}
+	}
+	p.AfterField = func(f *dwarf.StructField, a uint64) {
+		if f == nil {
+			p.Annotate(" /* done */")
+		}
+	}
+	p.reset()
+	p.printFieldAt(nil, pointStruct(), addr)
+	want := "struct point {x@0x1000=3, y@0x1004=4} /* done */"
+	if s := p.printBuf.String(); s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+	if s, err := p.SprintAt(pointStruct(), addr); err != nil || s != want {
+		t.Errorf("SprintAt: got %s, %v; want %s", s, err, want)
+	}
+}
+
+func TestPrintTruncated(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.write(addr, make([]byte, 200))
+	p := newTestPrinter(mem)
+
+	if _, err := p.sprintValue(pointStruct(), addr); err != nil {
+		t.Fatal(err)
+	}
+	if p.Truncated() {
+		t.Error("struct: Truncated() = true, want false")
+	}
+
+	long := &dwarf.ArrayType{Type: intType(1), StrideBitSize: 8, Count: 150}
+	s, err := p.sprintValue(long, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.Truncated() {
+		t.Errorf("array: Truncated() = false for %s", s)
+	}
+}
+
+func TestSprintAt(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	slice := writeSlice(mem, addr, data, 3)
+	p := newTestPrinter(mem)
+	for _, test := range []struct {
+		typ  dwarf.Type
+		addr uint64
+		want string
+	}{
+		{intType(4), data + 8, "2"},
+		{pointStruct(), data, "struct point {0, 1}"},
+		{slice, addr, "[]int32{0, 1, 2}"},
+	} {
+		s, err := p.SprintAt(test.typ, test.addr)
+		if err != nil {
+			t.Errorf("SprintAt(%s, %#x): %v", test.typ, test.addr, err)
+			continue
+		}
+		if s != test.want {
+			t.Errorf("SprintAt(%s, %#x) = %s, want %s", test.typ, test.addr, s, test.want)
+		}
+	}
+}
+
+func TestSprintSliceRange(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	typ := writeSlice(mem, addr, data, 10)
+	p := newTestPrinter(mem)
+	p.MaxArrayElements = 4
+	for _, test := range []struct {
+		low, high int64
+		want      string
+	}{
+		{3, 5, "[]int32{[3]: 3, [4]: 4}"},
+		{0, 0, "[]int32{}"},
+		{10, 10, "[]int32{}"},
+		{8, -1, "[]int32{[8]: 8, [9]: 9}"},
+		{2, -1, "[]int32{[2]: 2, [3]: 3, [4]: 4, [5]: 5, ...}"},
+		{0, 10, "[]int32{[0]: 0, [1]: 1, [2]: 2, [3]: 3, [4]: 4, [5]: 5, [6]: 6, [7]: 7, [8]: 8, [9]: 9}"},
+	} {
+		s, err := p.SprintSliceRange(typ, addr, test.low, test.high)
+		if err != nil {
+			t.Errorf("SprintSliceRange(%d, %d): %v", test.low, test.high, err)
+			continue
+		}
+		if s != test.want {
+			t.Errorf("SprintSliceRange(%d, %d) = %s, want %s", test.low, test.high, s, test.want)
+		}
+	}
+	for _, bounds := range [][2]int64{{-1, 2}, {3, 2}, {0, 11}, {11, -1}, {2, -2}} {
+		if s, err := p.SprintSliceRange(typ, addr, bounds[0], bounds[1]); err == nil {
+			t.Errorf("SprintSliceRange(%d, %d) = %s, want error", bounds[0], bounds[1], s)
+		}
+	}
+}
+
+func TestSprintAllGlobals(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x0b, 0x0b, 0x3e, 0x0b, 0, 0, // base type: name, byte size, encoding
+		3, 0x34, 0, 0x03, 0x08, 0x49, 0x13, 0x02, 0x0a, 0, 0, // variable: name, type, location
+		4, 0x34, 0, 0x03, 0x08, 0x49, 0x13, 0, 0, // variable: name, type
+		5, 0x34, 0, 0x03, 0x08, 0x49, 0x13, 0x3c, 0x19, 0, 0, // variable: name, type, declaration
+		0,
+	}
+	const header = 11
+	body := []byte{1}
+	intOff := header + len(body)
+	body = append(body, 2, 'i', 'n', 't', 0, 4, 5) // int, 4 bytes, signed
+	variable := func(name string, loc ...byte) {
+		body = append(body, 3)
+		body = append(body, name...)
+		body = append(body, 0, byte(intOff), 0, 0, 0, byte(len(loc)))
+		body = append(body, loc...)
+	}
+	addr := func(a uint64) []byte {
+		return []byte{0x03, byte(a), byte(a >> 8), 0, 0, 0, 0, 0, 0} // DW_OP_addr
+	}
+	variable("main.a", addr(0x1000)...)
+	variable("main.b", addr(0x1008)...)
+	variable("main.bad", 0xe0) // DW_OP_lo_user
+	variable("other.c", addr(0x1010)...)
+	body = append(body, 4)
+	body = append(body, "main.noloc"...)
+	body = append(body, 0, byte(intOff), 0, 0, 0)
+	body = append(body, 5)
+	body = append(body, "main.decl"...)
+	body = append(body, 0, byte(intOff), 0, 0, 0)
+	body = append(body, 0)
+	info := append([]byte{byte(header - 4 + len(body)), 0, 0, 0, 2, 0, 0, 0, 0, 0, 8}, body...)
+	d, err := dwarf.New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem := make(fakeMemory)
+	mem.writeUint(0x1000, 4, 7)
+	mem.writeUint(0x1008, 4, uint64(0xfffffffd))
+	mem.writeUint(0x1010, 4, 1)
+	p := newTestPrinter(mem)
+	p.dwarf = d
+	vals, err := p.SprintAllGlobals("main.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vals) != 4 {
+		t.Errorf("got %d globals, want 4: %v", len(vals), vals)
+	}
+	for name, want := range map[string]string{"main.a": "7", "main.b": "-3"} {
+		if got := vals[name]; got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if got := vals["main.bad"]; !strings.Contains(got, "location") {
+		t.Errorf("main.bad = %q, want a location error", got)
+	}
+	if got := vals["main.noloc"]; got != "no location" {
+		t.Errorf("main.noloc = %q, want no location", got)
+	}
+	if got, ok := vals["main.decl"]; ok {
+		t.Errorf("main.decl = %q, want it skipped", got)
+	}
+
+	// Sprint prints only the error for a variable whose location can't
+	// be decoded, not the value at address 0 as well, and prints a
+	// variable with no location as nil.
+	if s, err := p.Sprint("main.bad"); err == nil || s != "<"+err.Error()+">" {
+		t.Errorf(`Sprint("main.bad") = %q, %v; want only the error`, s, err)
+	}
+	if s, err := p.Sprint("main.noloc"); err != nil || s != "nil" {
+		t.Errorf(`Sprint("main.noloc") = %q, %v; want nil`, s, err)
+	}
+}
+
+func TestSprintFrameEntry(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x0b, 0x0b, 0x3e, 0x0b, 0, 0, // base type: name, byte size, encoding
+		3, 0x34, 0, 0x03, 0x08, 0x49, 0x13, 0x02, 0x0a, 0, 0, // variable: name, type, location
+		4, 0x05, 0, 0x03, 0x08, 0x49, 0x13, 0x02, 0x0a, 0, 0, // formal parameter: name, type, location
+		0,
+	}
+	const header = 11
+	body := []byte{1}
+	intOff := header + len(body)
+	body = append(body, 2, 'i', 'n', 't', 0, 4, 5) // int, 4 bytes, signed
+	entry := func(abbrev byte, name string, loc ...byte) {
+		body = append(body, abbrev)
+		body = append(body, name...)
+		body = append(body, 0, byte(intOff), 0, 0, 0, byte(len(loc)))
+		body = append(body, loc...)
+	}
+	entry(3, "x", 0x91, 0x78) // DW_OP_fbreg -8
+	entry(4, "y", 0x53)       // DW_OP_reg3
+	body = append(body, 0)
+	info := append([]byte{byte(header - 4 + len(body)), 0, 0, 0, 2, 0, 0, 0, 0, 0, 8}, body...)
+	d, err := dwarf.New(abbrev, nil, nil, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mem := make(fakeMemory)
+	mem.writeUint(0x1000-8, 4, 7)
+	p := newTestPrinter(mem)
+	p.dwarf = d
+	regs := &dwarf.Registers{
+		Reg:       map[int]uint64{3: uint64(0xfffffffe)},
+		FrameBase: 0x1000,
+	}
+	r := d.Reader()
+	var entries []*dwarf.Entry
+	for _, want := range []string{"7", "-2"} {
+		var e *dwarf.Entry
+		for e == nil || e.Tag != dwarf.TagVariable && e.Tag != dwarf.TagFormalParameter {
+			if e, err = r.Next(); err != nil || e == nil {
+				t.Fatalf("reading entries: %v", err)
+			}
+		}
+		entries = append(entries, e)
+		s, err := p.SprintFrameEntry(e, regs)
+		if err != nil {
+			t.Errorf("%s: %v", e.Val(dwarf.AttrName), err)
+		} else if s != want {
+			t.Errorf("%s = %s, want %s", e.Val(dwarf.AttrName), s, want)
+		}
+		// Without the registers, the location can't be found.
+		if s, err := p.SprintFrameEntry(e, nil); err == nil {
+			t.Errorf("%s without registers = %s, want an error", e.Val(dwarf.AttrName), s)
+		}
+	}
+
+	// The field callbacks are called for the variable itself, with
+	// address 0 for the one in a register.
+	p.BeforeField = func(f *dwarf.StructField, a uint64) { p.Annotate(fmt.Sprintf("[%#x] ", a)) }
+	p.AfterField = func(f *dwarf.StructField, a uint64) { p.Annotate(" /* done */") }
+	for i, want := range []string{"[0xff8] 7 /* done */", "[0x0] -2 /* done */"} {
+		if s, err := p.SprintFrameEntry(entries[i], regs); err != nil || s != want {
+			t.Errorf("%s with callbacks = %s, %v; want %s", entries[i].Val(dwarf.AttrName), s, err, want)
+		}
+	}
+}
+
+func TestPrinterErrors(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr+4, 4, 5) // Only the second field is readable.
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 12},
+		StructName: "s",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "a", Type: intType(4), ByteOffset: 0},
+			{Name: "b", Type: intType(4), ByteOffset: 4},
+			{Name: "c", Type: intType(4), ByteOffset: 8},
+		},
+	}
+	p := newTestPrinter(mem)
+	s, err := p.SprintAt(typ, addr)
+	if err == nil {
+		t.Fatalf("got %s with no error", s)
+	}
+	if !strings.Contains(s, ", 5, ") {
+		t.Errorf("got %s, want the readable field printed", s)
+	}
+	errs := p.Errors()
+	if len(errs) != 2 {
+		t.Fatalf("got errors %v, want 2", errs)
+	}
+	if errs[0] != err {
+		t.Errorf("first error is %v, want %v", errs[0], err)
+	}
+	for i, a := range []uint64{addr, addr + 8} {
+		if want := fmt.Sprintf("%#x", a); !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("error %d is %v, want one mentioning %s", i, errs[i], want)
+		}
+	}
+
+	if _, err := p.SprintAt(intType(4), addr+4); err != nil || len(p.Errors()) != 0 {
+		t.Errorf("after a successful print: got %v, %v, want no errors", err, p.Errors())
+	}
+}
+
+func TestSetGoroutineID(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 3)
+	mem.writeUint(addr+4, 4, 4)
+	p := newTestPrinter(mem)
+	p.SetGoroutineID(42)
+	var buf bytes.Buffer
+	if err := p.fprint(&buf, func() { p.printValueAt(intType(4), addr) }); err != nil {
+		t.Fatal(err)
+	}
+	if want := "[goroutine 42] 3"; buf.String() != want {
+		t.Errorf("fprint: got %s, want %s", buf.String(), want)
+	}
+
+	// Values printed without an entry, such as by SprintAt, are parts of
+	// a larger output and have no prefix.
+	s, err := p.SprintAt(pointStruct(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct point {3, 4}"; s != want {
+		t.Errorf("SprintAt: got %s, want %s", s, want)
+	}
+
+	// The prefix isn't counted against the output budget.
+	q := p.WithOutputBudget(1)
+	buf.Reset()
+	q.fprint(&buf, func() { q.printValueAt(intType(4), addr) })
+	if want := "[goroutine 42] 3"; buf.String() != want || q.BudgetExceeded() {
+		t.Errorf("with budget: got %s (BudgetExceeded %t), want %s", buf.String(), q.BudgetExceeded(), want)
+	}
+
+	p.GoLiteral = true
+	buf.Reset()
+	p.fprint(&buf, func() { p.printValueAt(intType(4), addr) })
+	if want := "3"; buf.String() != want {
+		t.Errorf("GoLiteral: got %s, want %s", buf.String(), want)
+	}
+	p.GoLiteral = false
+
+	p.SetGoroutineID(0)
+	buf.Reset()
+	p.fprint(&buf, func() { p.printValueAt(intType(4), addr) })
+	if want := "3"; buf.String() != want {
+		t.Errorf("after SetGoroutineID(0): got %s, want %s", buf.String(), want)
+	}
+}
+
+// stringHeader returns the type of a Go string header, struct {str *uint8; len int}.
+func stringHeader() *dwarf.StructType {
+	return &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16, Name: "string", ReflectKind: reflect.String},
+		StructName: "string",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "str", Type: &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: intType(1)}, ByteOffset: 0},
+			{Name: "len", Type: intType(8), ByteOffset: 8},
+		},
+	}
+}
+
+func TestPrintStringStruct(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, data)
+	mem.writeUint(addr+8, 8, 5)
+	mem.write(data, []byte("hello"))
+	p := newTestPrinter(mem)
+	s, err := p.sprintValue(stringHeader(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"hello"`; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func uintType(size int64) *dwarf.UintType {
+	return &dwarf.UintType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: size, Name: fmt.Sprintf("uint%d", 8*size)}}}
+}
+
+func ptrTo(t dwarf.Type) *dwarf.PtrType {
+	return &dwarf.PtrType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: t}
+}
+
+// writeMap writes a Go map from int64 keys to int64 values at addr, in the
+// layout read by peekMapValues, using the memory starting at heap for the
+// map's header and buckets. Entries are stored in the order given.
+func writeMap(mem fakeMemory, addr, heap uint64, keys, vals []int64) *dwarf.MapType {
+	i64 := intType(8)
+	return writeMapEntries(mem, addr, heap, i64, i64, len(keys), func(i int, keyAddr, valAddr uint64) {
+		mem.writeUint(keyAddr, 8, uint64(keys[i]))
+		mem.writeUint(valAddr, 8, uint64(vals[i]))
+	})
+}
+
+// writeMapEntries writes a Go map with n entries, of the given key and
+// value types, as for writeMap. The i'th entry is written by calling
+// write with the addresses of its key and value.
+func writeMapEntries(mem fakeMemory, addr, heap uint64, keyType, valType dwarf.Type, n int, write func(i int, keyAddr, valAddr uint64)) *dwarf.MapType {
+	const bucketCnt = 8
+	keySize, valSize := uint64(keyType.Size()), uint64(valType.Size())
+	valsOffset := 8 + bucketCnt*keySize
+	overflowOffset := valsOffset + bucketCnt*valSize
+	bucket := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: int64(overflowOffset) + 8},
+		StructName: "bucket",
+		Kind:       "struct",
+	}
+	bucket.Field = []*dwarf.StructField{
+		{Name: "tophash", Type: &dwarf.ArrayType{Type: uintType(1), StrideBitSize: 8, Count: bucketCnt}, ByteOffset: 0},
+		{Name: "keys", Type: &dwarf.ArrayType{Type: keyType, StrideBitSize: 8 * int64(keySize), Count: bucketCnt}, ByteOffset: 8},
+		{Name: "values", Type: &dwarf.ArrayType{Type: valType, StrideBitSize: 8 * int64(valSize), Count: bucketCnt}, ByteOffset: int64(valsOffset)},
+		{Name: "overflow", Type: ptrTo(bucket), ByteOffset: int64(overflowOffset)},
+	}
+	hmap := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 32},
+		StructName: "hmap",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "count", Type: intType(8), ByteOffset: 0},
+			{Name: "B", Type: uintType(1), ByteOffset: 8},
+			{Name: "buckets", Type: ptrTo(bucket), ByteOffset: 16},
+			{Name: "oldbuckets", Type: ptrTo(bucket), ByteOffset: 24},
+		},
+	}
+	b := uint(0)
+	for n > bucketCnt<<b {
+		b++
+	}
+	buckets := heap + uint64(hmap.ByteSize)
+	mem.writeUint(addr, 8, heap)
+	mem.writeUint(heap, 8, uint64(n))
+	mem.writeUint(heap+8, 1, uint64(b))
+	mem.writeUint(heap+16, 8, buckets)
+	mem.writeUint(heap+24, 8, 0)
+	mem.write(buckets, make([]byte, (1<<b)*bucket.ByteSize))
+	for i := 0; i < n; i++ {
+		ba := buckets + uint64(i/bucketCnt)*uint64(bucket.ByteSize)
+		j := uint64(i % bucketCnt)
+		mem.writeUint(ba+j, 1, 4+j)
+		write(i, ba+8+j*keySize, ba+valsOffset+j*valSize)
+	}
+	return &dwarf.MapType{
+		TypedefType: dwarf.TypedefType{CommonType: dwarf.CommonType{ByteSize: 8}, Type: ptrTo(hmap)},
+		KeyType:     keyType,
+		ElemType:    valType,
+	}
+}
+
+func TestPrintSortedMap(t *testing.T) {
+	const addr, heap = 0x1000, 0x10000
+	mem := make(fakeMemory)
+	mt := writeMap(mem, addr, heap, []int64{10, 9, -1, 2}, []int64{100, 90, -10, 20})
+	p := newTestPrinter(mem)
+
+	s, err := p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[10:100 9:90 -1:-10 2:20]"; s != want {
+		t.Errorf("unsorted: got %s, want %s", s, want)
+	}
+
+	p.SortMapKeys = true
+	s, err = p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[-1:-10 2:20 9:90 10:100]"; s != want {
+		t.Errorf("sorted: got %s, want %s", s, want)
+	}
+
+	p.MaxMapPrint = 2
+	s, err = p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[-1:-10 2:20 ...]"; s != want {
+		t.Errorf("sorted and truncated: got %s, want %s", s, want)
+	}
+}
+
+func TestPrintSortedMapStructKeys(t *testing.T) {
+	const addr, heap = 0x1000, 0x10000
+	mem := make(fakeMemory)
+	mt := writeMapEntries(mem, addr, heap, pointStruct(), intType(8), 2, func(i int, keyAddr, valAddr uint64) {
+		mem.writeUint(keyAddr, 4, uint64(2-i))
+		mem.writeUint(keyAddr+4, 4, 0)
+		mem.writeUint(valAddr, 8, uint64(i))
+	})
+	p := newTestPrinter(mem)
+	calls := 0
+	p.BeforeField = func(*dwarf.StructField, uint64) { calls++ }
+	if _, err := p.sprintValue(mt, addr); err != nil {
+		t.Fatal(err)
+	}
+	unsortedCalls := calls
+
+	// The keys are printed once more for sorting, without the callbacks.
+	p.SortMapKeys = true
+	calls = 0
+	s, err := p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[struct point {1, 0}:1 struct point {2, 0}:0]"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+	if calls != unsortedCalls {
+		t.Errorf("BeforeField called %d times when sorting, %d times when not", calls, unsortedCalls)
+	}
+}
+
+func TestPrintMapKeyLess(t *testing.T) {
+	const addr, heap = 0x1000, 0x10000
+	mem := make(fakeMemory)
+	mt := writeMap(mem, addr, heap, []int64{10, 9, -1, 2}, []int64{100, 90, -10, 20})
+	p := newTestPrinter(mem)
+
+	key := func(a uint64) int64 {
+		buf := make([]byte, 8)
+		if err := mem.peek(a, buf); err != nil {
+			t.Fatal(err)
+		}
+		return arch.AMD64.Int64(buf)
+	}
+	// Sort the keys in decreasing order.
+	p.MapKeyLess = func(keyTypeA, keyTypeB dwarf.Type, addrA, addrB uint64) bool {
+		if keyTypeA != mt.KeyType || keyTypeB != mt.KeyType {
+			t.Errorf("MapKeyLess called with key types %v and %v, want %v", keyTypeA, keyTypeB, mt.KeyType)
+		}
+		return key(addrA) > key(addrB)
+	}
+	s, err := p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[10:100 9:90 2:20 -1:-10]"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestPrintSortedMapOtherKeys(t *testing.T) {
+	const addr, heap = 0x1000, 0x10000
+	mem := make(fakeMemory)
+	mt := writeMap(mem, addr, heap, []int64{0xa, 0x9, 0x20, 0x2}, []int64{1, 2, 3, 4})
+	// Pointer keys are sorted by their printed form.
+	keyType := ptrTo(intType(8))
+	hmap := mt.Type.(*dwarf.PtrType).Type.(*dwarf.StructType)
+	bucket := hmap.Field[2].Type.(*dwarf.PtrType).Type.(*dwarf.StructType)
+	bucket.Field[1].Type.(*dwarf.ArrayType).Type = keyType
+	mt.KeyType = keyType
+	p := newTestPrinter(mem)
+
+	s, err := p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[0xa:1 0x9:2 0x20:3 0x2:4]"; s != want {
+		t.Errorf("unsorted: got %s, want %s", s, want)
+	}
+
+	p.SortMapKeys = true
+	s, err = p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[0x2:4 0x20:3 0x9:2 0xa:1]"; s != want {
+		t.Errorf("sorted: got %s, want %s", s, want)
+	}
+
+	// The keys are sorted as they are printed, with the same StackRange
+	// and ValidateAddrFn.
+	p.StackRange = [2]uint64{0x2, 0x3}
+	s, err = p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[0x20:3 0x9:2 0xa:1 <stack @0x2>:4]"; s != want {
+		t.Errorf("sorted with StackRange: got %s, want %s", s, want)
+	}
+	p.StackRange = [2]uint64{}
+	const lastKey = heap + 32 + 8 + 3*8 // the key 0x2, in the first bucket
+	p.ValidateAddrFn = func(a uint64) bool { return a != lastKey }
+	s, err = p.sprintValue(mt, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "map[0x20:3 0x9:2 0xa:1 <unmapped @0x10040>:4]"; s != want {
+		t.Errorf("sorted with ValidateAddrFn: got %s, want %s", s, want)
+	}
+}
+
+func TestPrintOutputBudget(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.write(addr, make([]byte, 100))
+	p := newTestPrinter(mem)
+	q := p.WithOutputBudget(10)
+	arr := &dwarf.ArrayType{Type: intType(1), StrideBitSize: 8, Count: 100}
+
+	s, err := q.sprintValue(arr, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s) != 10 {
+		t.Errorf("got %d bytes of output (%q), want 10", len(s), s)
+	}
+	if !q.BudgetExceeded() {
+		t.Error("BudgetExceeded() = false, want true")
+	}
+
+	// The original printer is not limited.
+	s, err = p.sprintValue(pointStruct(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct point {0, 0}"; s != want || p.BudgetExceeded() {
+		t.Errorf("got %s, BudgetExceeded=%t; want %s, false", s, p.BudgetExceeded(), want)
+	}
+}
+
+func TestPrintOutputBudgetBoundary(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	str := &dwarf.StringType{StructType: *stringHeader()}
+	for _, test := range []struct {
+		s      string
+		html   bool
+		budget int64
+		want   string
+	}{
+		// The budget ends inside the two bytes of é.
+		{"h\u00e9llo", false, 3, `"h`},
+		{"h\u00e9llo", false, 4, "\"h\u00e9"},
+		// The budget ends inside "&lt;".
+		{"<b>", true, 8, "&#34;"},
+		{"<b>", true, 9, "&#34;&lt;"},
+	} {
+		mem.writeUint(addr, 8, data)
+		mem.writeUint(addr+8, 8, uint64(len(test.s)))
+		mem.write(data, []byte(test.s))
+		p := newTestPrinter(mem)
+		p.HTMLMode = test.html
+		q := p.WithOutputBudget(test.budget)
+		s, err := q.sprintValue(str, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.want || !q.BudgetExceeded() {
+			t.Errorf("%q with budget %d: got %q, BudgetExceeded() = %t; want %q, true", test.s, test.budget, s, q.BudgetExceeded(), test.want)
+		}
+	}
+}
+
+func TestPrintCycleSentinel(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 7)
+	mem.writeUint(addr+4, 4, 8)
+	// A struct whose only field is itself, which is printed as a cycle.
+	st := &dwarf.StructType{StructName: "loop", Kind: "struct"}
+	st.Field = []*dwarf.StructField{{Name: "self", Type: st}}
+
+	p := newTestPrinter(mem)
+	s, _ := p.sprintValue(st, addr)
+	if want := "struct loop {(struct loop 0x1000)}"; s != want {
+		t.Errorf("default: got %s, want %s", s, want)
+	}
+	p.CycleSentinelFormat = "<cycle at %#[2]x>"
+	s, _ = p.sprintValue(st, addr)
+	if want := "struct loop {<cycle at 0x1000>}"; s != want {
+		t.Errorf("custom: got %s, want %s", s, want)
+	}
+}
+
+func TestPrintErrorHandler(t *testing.T) {
+	p := newTestPrinter(make(fakeMemory))
+	s, err := p.sprintValue(intType(4), 0x1000)
+	if err == nil {
+		t.Fatal("reading unmapped memory: got no error")
+	}
+	if want := "<reading integer: bad address 0x1000>"; s != want {
+		t.Errorf("default: got %s, want %s", s, want)
+	}
+
+	p.ErrorHandler = func(err error) string {
+		return fmt.Sprintf(`{"error": %q}`, err)
+	}
+	s, _ = p.sprintValue(intType(4), 0x1000)
+	if want := `{"error": "reading integer: bad address 0x1000"}`; s != want {
+		t.Errorf("custom: got %s, want %s", s, want)
+	}
+}
+
+func TestPrintImplicitValue(t *testing.T) {
+	p := newTestPrinter(make(fakeMemory))
+	tests := []struct {
+		typ  dwarf.Type
+		buf  []byte
+		want string
+	}{
+		// Stack values are 8 bytes, whatever the size of the type.
+		{intType(4), []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, "-2"},
+		{uintType(2), []byte{0x34, 0x12, 0, 0, 0, 0, 0, 0}, "4660"},
+		{ptrTo(intType(4)), []byte{0x00, 0x10, 0, 0, 0, 0, 0, 0}, "0x1000"},
+		// Implicit values are the size of the type.
+		{&dwarf.FloatType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}}, []byte{0, 0, 0xc0, 0x3f}, "1.5"},
+	}
+	for _, test := range tests {
+		p.reset()
+		p.printImplicitValue(test.typ, test.buf)
+		if got := p.printBuf.String(); got != test.want || p.err != nil {
+			t.Errorf("printImplicitValue(%s, %x) = %q, %v; want %q", test.typ, test.buf, got, p.err, test.want)
+		}
+	}
+
+	p.reset()
+	p.printImplicitValue(intType(8), []byte{1, 2})
+	if p.err == nil {
+		t.Error("short implicit value: got no error")
+	}
+}
+
+func TestPrintHTML(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, data)
+	mem.writeUint(addr+8, 8, 4)
+	mem.writeUint(addr+16, 8, 0x3000)
+	mem.writeUint(addr+24, 8, 0)
+	mem.write(data, []byte("<b>&"))
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 32, Name: "main.T<int>"},
+		StructName: "main.T<int>",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "s", Type: stringHeader(), ByteOffset: 0},
+			{Name: "p", Type: ptrTo(intType(4)), ByteOffset: 16},
+			{Name: "q", Type: ptrTo(intType(4)), ByteOffset: 24},
+			{Name: "bad", Type: intType(4), ByteOffset: 0x4000},
+		},
+	}
+	p := newTestPrinter(mem)
+	p.HTMLMode = true
+	s, err := p.sprintValue(typ, addr)
+	if err == nil {
+		t.Error("reading unmapped field: got no error")
+	}
+	want := `<a href="/type/main.T%3Cint%3E">struct main.T&lt;int&gt;</a> <table>` +
+		`<tr><td>s</td><td>&#34;&lt;b&gt;&amp;&#34;</td></tr>` +
+		`<tr><td>p</td><td><a href="/mem/0x3000">0x3000</a></td></tr>` +
+		`<tr><td>q</td><td>nil</td></tr>` +
+		`<tr><td>bad</td><td>&lt;reading integer: bad address 0x5000&gt;</td></tr>` +
+		`</table>`
+	if s != want {
+		t.Errorf("got  %s\nwant %s", s, want)
+	}
+}
+
+func TestPrintCharAndAddr(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.write(addr, []byte{'A', 0xff, '<', 0x80})
+	mem.writeUint(addr+8, 8, 0x4000)
+	basic := func(size int64) dwarf.BasicType {
+		return dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: size}}
+	}
+	tests := []struct {
+		typ     dwarf.Type
+		a       uint64
+		want    string
+		wantErr string
+	}{
+		{&dwarf.CharType{BasicType: basic(1)}, addr, "65 'A'", ""},
+		{&dwarf.CharType{BasicType: basic(1)}, addr + 1, `-1 '\xff'`, ""},
+		{&dwarf.UcharType{BasicType: basic(1)}, addr + 1, `255 '\xff'`, ""},
+		{&dwarf.UcharType{BasicType: basic(1)}, addr + 3, `128 '\x80'`, ""},
+		{&dwarf.AddrType{BasicType: basic(8)}, addr + 8, "0x4000", ""},
+		{&dwarf.CharType{BasicType: basic(1)}, 0x5000, "", "reading char: bad address 0x5000"},
+		{&dwarf.UcharType{BasicType: basic(1)}, 0x5000, "", "reading unsigned char: bad address 0x5000"},
+		{&dwarf.AddrType{BasicType: basic(8)}, 0x5000, "", "reading address: bad address 0x5000"},
+	}
+	p := newTestPrinter(mem)
+	for _, test := range tests {
+		s, err := p.sprintValue(test.typ, test.a)
+		if test.wantErr == "" {
+			if err != nil || s != test.want {
+				t.Errorf("%T at %#x: got %s, %v; want %s", test.typ, test.a, s, err, test.want)
+			}
+			continue
+		}
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%T at %#x: got error %v, want %s", test.typ, test.a, err, test.wantErr)
+		}
+		if want := "<" + test.wantErr + ">"; s != want {
+			t.Errorf("%T at %#x: got %s, want %s", test.typ, test.a, s, want)
+		}
+
+		// The first error is sticky: printing continues after it, but
+		// later errors do not replace it.
+		st := &dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: 16},
+			StructName: "s",
+			Kind:       "struct",
+			Field: []*dwarf.StructField{
+				{Name: "a", Type: test.typ, ByteOffset: 0},
+				{Name: "b", Type: intType(4), ByteOffset: 0x100},
+			},
+		}
+		s, err = p.sprintValue(st, test.a)
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("struct with %T at %#x: got error %v, want %s", test.typ, test.a, err, test.wantErr)
+		}
+		if !strings.Contains(s, "<reading integer:") {
+			t.Errorf("struct with %T at %#x: printing stopped at the first error: %s", test.typ, test.a, s)
+		}
+	}
+
+	// The quoted character is escaped in HTMLMode.
+	p.HTMLMode = true
+	for _, typ := range []dwarf.Type{&dwarf.CharType{BasicType: basic(1)}, &dwarf.UcharType{BasicType: basic(1)}} {
+		if s, err := p.sprintValue(typ, addr+2); err != nil || s != "60 &#39;&lt;&#39;" {
+			t.Errorf("%T in HTMLMode: got %s, %v; want 60 &#39;&lt;&#39;", typ, s, err)
+		}
+	}
+}
+
+func TestPrintEnum(t *testing.T) {
+	const addr = 0x1000
+	typ := &dwarf.EnumType{
+		CommonType: dwarf.CommonType{ByteSize: 4},
+		EnumName:   "color",
+		Val: []*dwarf.EnumValue{
+			{Name: "red", Val: 0},
+			{Name: "green", Val: 1},
+			{Name: "neg", Val: -5},
+			{Name: "big", Val: 0xfffffffe},
+		},
+	}
+	tests := []struct {
+		val  uint64
+		want string
+	}{
+		{0, "red"},
+		{1, "green"},
+		{0xfffffffb, "neg"},
+		{0xfffffffe, "big"},
+		{7, "7 /* unknown color value */"},
+		{0xffffffff, "-1 /* unknown color value */"},
+	}
+	for _, test := range tests {
+		mem := make(fakeMemory)
+		mem.writeUint(addr, 4, test.val)
+		s, err := newTestPrinter(mem).sprintValue(typ, addr)
+		if err != nil || s != test.want {
+			t.Errorf("enum value %#x: got %s, %v; want %s", test.val, s, err, test.want)
+		}
+	}
+
+	if _, err := newTestPrinter(make(fakeMemory)).sprintValue(typ, addr); err == nil {
+		t.Error("reading unmapped enum: got no error")
+	}
+}
+
+func TestPrintQualType(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 7)
+	mem.writeUint(addr+4, 4, 0xfffffff8)
+	want, err := newTestPrinter(mem).sprintValue(pointStruct(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, qual := range []string{"const", "volatile", "restrict"} {
+		typ := &dwarf.QualType{Qual: qual, Type: pointStruct()}
+		s, err := newTestPrinter(mem).sprintValue(typ, addr)
+		if err != nil || s != want {
+			t.Errorf("%s: got %s, %v; want %s", qual, s, err, want)
+		}
+	}
+}
+
+func TestValAttrs(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 3)
+	mem.writeUint(addr+4, 4, 4)
+	mem.writeUint(addr+8, 8, data)
+	mem.writeUint(addr+16, 8, 5)
+	mem.writeUint(addr+24, 2, 7)
+	mem.writeUint(addr+26, 2, 8)
+	mem.write(data, []byte("hello"))
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 40},
+		StructName: "T",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "pt", Type: pointStruct(), ByteOffset: 0},
+			{Name: "s", Type: stringHeader(), ByteOffset: 8},
+			{Name: "a", Type: &dwarf.ArrayType{Type: uintType(2), StrideBitSize: 16, Count: 2}, ByteOffset: 24},
+			{Name: "bad", Type: intType(4), ByteOffset: 0x4000},
+		},
+	}
+	attrs := newTestPrinter(mem).ValAttrs(typ, addr)
+
+	var buf bytes.Buffer
+	h := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && (a.Key == slog.TimeKey || a.Key == slog.LevelKey) {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	slog.New(h).LogAttrs(context.Background(), slog.LevelInfo, "v", attrs...)
+	want := `msg=v pt.x=3 pt.y=4 s=hello a.0=7 a.1=8 bad="bad address 0x5000"` + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestPrintUnionAndClass(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 0x3fc00000)
+	mem.writeUint(addr+4, 4, 2)
+
+	class := pointStruct()
+	class.Kind = "class"
+	class.StructName = "Point"
+	fl := &dwarf.FloatType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 4}}}
+	union := func(fields ...*dwarf.StructField) *dwarf.StructType {
+		return &dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: 4},
+			StructName: "u",
+			Kind:       "union",
+			Field:      fields,
+		}
+	}
+	incomplete := union()
+	incomplete.Incomplete = true
+	tests := []struct {
+		typ  dwarf.Type
+		want string
+	}{
+		{class, "class Point {1069547520, 2}"},
+		{union(&dwarf.StructField{Name: "f", Type: fl}), "union u {f: 1.5}"},
+		{union(&dwarf.StructField{Name: "f", Type: fl}, &dwarf.StructField{Name: "i", Type: intType(4)}), "union u {f: 1.5 /* or 1 other field */}"},
+		{union(&dwarf.StructField{Name: "i", Type: intType(4)}, &dwarf.StructField{Name: "f", Type: fl}, &dwarf.StructField{Name: "b", Type: uintType(1)}), "union u {i: 1069547520 /* or 2 other fields */}"},
+		{union(), "union u {}"},
+		{incomplete, "union u {<incomplete>}"},
+	}
+	for _, test := range tests {
+		s, err := newTestPrinter(mem).sprintValue(test.typ, addr)
+		if err != nil || s != test.want {
+			t.Errorf("got %s, %v; want %s", s, err, test.want)
+		}
+	}
+}
+
+// efaceType returns the type of interface{}, which has the layout of
+// runtime.eface, with a runtime._type that holds just the type's name.
+func efaceType() *dwarf.InterfaceType {
+	runtimeType := &dwarf.TypedefType{
+		CommonType: dwarf.CommonType{Name: "runtime._type"},
+		Type: &dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: 16},
+			StructName: "runtime._type",
+			Kind:       "struct",
+			Field: []*dwarf.StructField{
+				{Name: "size", Type: uintType(8), ByteOffset: 0},
+				{Name: "_string", Type: ptrTo(&dwarf.StringType{StructType: *stringHeader()}), ByteOffset: 8},
+			},
+		},
+	}
+	eface := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16},
+		StructName: "runtime.eface",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "_type", Type: ptrTo(runtimeType), ByteOffset: 0},
+			{Name: "data", Type: ptrTo(&dwarf.VoidType{}), ByteOffset: 8},
+		},
+	}
+	return &dwarf.InterfaceType{TypedefType: dwarf.TypedefType{
+		CommonType: dwarf.CommonType{Name: "interface {}"},
+		Type:       &dwarf.TypedefType{CommonType: dwarf.CommonType{Name: "runtime.eface"}, Type: eface},
+	}}
+}
+
+func TestPrintEmptyInterface(t *testing.T) {
+	const addr, typeAddr, data = 0x1000, 0x2000, 0x3000
+	mem := make(fakeMemory)
+	// An interface{} holding the int 42.
+	mem.writeUint(addr, 8, typeAddr)
+	mem.writeUint(addr+8, 8, data)
+	mem.writeUint(typeAddr+8, 8, typeAddr+16)
+	mem.writeUint(typeAddr+16, 8, typeAddr+32)
+	mem.writeUint(typeAddr+24, 8, 3)
+	mem.write(typeAddr+32, []byte("int"))
+	mem.writeUint(data, 8, 42)
+	// A nil interface{}.
+	mem.writeUint(addr+16, 8, 0)
+	mem.writeUint(addr+24, 8, 0)
+
+	p := newTestPrinter(mem)
+	typ := efaceType()
+	for _, test := range []struct {
+		addr uint64
+		want string
+	}{
+		{addr, `("int", 0x3000)`},
+		{addr + 16, "(nil, nil)"},
+	} {
+		s, err := p.sprintValue(typ, test.addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.want {
+			t.Errorf("got %s, want %s", s, test.want)
+		}
+	}
+}
+
+func TestPrintUnspecifiedType(t *testing.T) {
+	const addr, target = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, target)
+	mem.write(addr+8, []byte{0xde, 0xad, 0xbe, 0xef})
+	void := &dwarf.UnspecifiedType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{Name: "void"}}}
+	unknown := &dwarf.UnspecifiedType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{Name: "__unknown_type", ByteSize: 4}}}
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 12},
+		StructName: "s",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "p", Type: ptrTo(void), ByteOffset: 0},
+			{Name: "u", Type: unknown, ByteOffset: 8},
+			{Name: "v", Type: void, ByteOffset: 12},
+		},
+	}
+	p := newTestPrinter(mem)
+	p.FollowPointers = true
+	s, err := p.sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct s {0x2000, 0xdeadbeef, <unspecified>}"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+
+	// Large values are truncated like strings.
+	p.MaxStringBytes = 2
+	s, err = p.sprintValue(unknown, addr+8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "0xdead..."; s != want || !p.Truncated() {
+		t.Errorf("with MaxStringBytes 2: got %s (Truncated %t), want %s", s, p.Truncated(), want)
+	}
+}
+
+func TestPrintTypedefWithoutType(t *testing.T) {
+	// No memory is read.
+	p := newTestPrinter(make(fakeMemory))
+	for _, test := range []struct {
+		typ  *dwarf.TypedefType
+		want string
+	}{
+		{&dwarf.TypedefType{CommonType: dwarf.CommonType{Name: "handle_t"}}, "<forward-declared typedef handle_t>"},
+		{&dwarf.TypedefType{CommonType: dwarf.CommonType{Name: "nothing_t"}, Type: &dwarf.VoidType{}}, "<void>"},
+	} {
+		s, err := p.sprintValue(test.typ, 0x1000)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.want {
+			t.Errorf("%s: got %s, want %s", test.typ.Name, s, test.want)
+		}
+	}
+}
+
+func TestPrinterOptions(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	for i := uint64(0); i < 10; i++ {
+		mem.writeUint(addr+4*i, 4, i)
+	}
+	array := &dwarf.ArrayType{Type: intType(4), StrideBitSize: 32, Count: 10}
+	str := stringHeader()
+	mem.writeUint(data, 8, data+16)
+	mem.writeUint(data+8, 8, 11)
+	mem.write(data+16, []byte("hello world"))
+
+	p := NewPrinter(&arch.AMD64, nil, &Server{arch: arch.AMD64, peekHook: mem.peek},
+		PrinterOptions{MaxArrayElements: 3, MaxStringBytes: 5})
+	s, err := p.sprintValue(array, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := array.String() + "{0, 1, 2, ...}"; s != want {
+		t.Errorf("array: got %s, want %s", s, want)
+	}
+	if !p.Truncated() {
+		t.Error("array: Truncated() = false, want true")
+	}
+	s, err = p.sprintValue(str, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"hello..."`; s != want {
+		t.Errorf("string: got %s, want %s", s, want)
+	}
+
+	// The zero options give the default limits.
+	p = newTestPrinter(mem)
+	s, err = p.sprintValue(array, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := array.String() + "{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}"; s != want {
+		t.Errorf("default array: got %s, want %s", s, want)
+	}
+	if err := ValidateGoLiteralOutput(s); err != nil {
+		t.Errorf("default array: %v", err)
+	}
+	s, err = p.sprintValue(str, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `"hello world"`; s != want {
+		t.Errorf("default string: got %s, want %s", s, want)
+	}
+	if err := ValidateGoLiteralOutput(s); err != nil {
+		t.Errorf("default string: %v", err)
+	}
+}
+
+func TestPrintReverseFields(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 3)
+	mem.writeUint(addr+4, 4, 4)
+	p := newTestPrinter(mem)
+	p.PrinterOptions = PrinterOptions{ReverseFields: true}
+	s, err := p.sprintValue(pointStruct(), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct point {4, 3}"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestPrintArtificialFields(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, 0x2000)
+	mem.writeUint(addr+8, 4, 7)
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 12},
+		StructName: "Shape",
+		Kind:       "class",
+		Field: []*dwarf.StructField{
+			{Name: "_vptr.Shape", Type: ptrTo(intType(8)), IsArtificial: true},
+			{Name: "id", Type: intType(4), ByteOffset: 8},
+		},
+	}
+	p := newTestPrinter(mem)
+	for _, test := range []struct {
+		show bool
+		want string
+	}{
+		{false, "class Shape {7}"},
+		{true, "class Shape {0x2000, 7}"},
+	} {
+		p.ShowArtificialFields = test.show
+		s, err := p.sprintValue(typ, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.want {
+			t.Errorf("ShowArtificialFields=%t: got %s, want %s", test.show, s, test.want)
+		}
+	}
+}
+
+func TestPrintFieldFilter(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 3)
+	mem.writeUint(addr+4, 4, 4)
+	p := newTestPrinter(mem)
+	for _, test := range []struct {
+		filter func(*dwarf.StructField) bool
+		want   string
+	}{
+		{nil, "struct point {3, 4}"},
+		{NewFieldFilter("y"), "struct point {4}"},
+		{NewFieldFilter("x", "y"), "struct point {3, 4}"},
+		{NewFieldFilter(), "struct point {}"},
+		{func(f *dwarf.StructField) bool { return f.ByteOffset == 0 }, "struct point {3}"},
+	} {
+		p.FieldFilter = test.filter
+		s, err := p.sprintValue(pointStruct(), addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.want {
+			t.Errorf("got %s, want %s", s, test.want)
+		}
+	}
+}
+
+// sliceType returns a Go slice type with elements of type elem.
+func sliceType(elem dwarf.Type) *dwarf.SliceType {
+	return &dwarf.SliceType{
+		StructType: dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: 24, Name: "[]" + elem.String()},
+			StructName: "[]" + elem.String(),
+			Kind:       "struct",
+			Field: []*dwarf.StructField{
+				{Name: "array", Type: ptrTo(elem), ByteOffset: 0},
+				{Name: "len", Type: intType(8), ByteOffset: 8},
+				{Name: "cap", Type: intType(8), ByteOffset: 16},
+			},
+		},
+		ElemType: elem,
+	}
+}
+
+// writeSlice writes a slice of n int32s, with values 0 to n-1, at addr,
+// with its elements at data.
+func writeSlice(mem fakeMemory, addr, data uint64, n int) *dwarf.SliceType {
+	mem.writeUint(addr, 8, data)
+	mem.writeUint(addr+8, 8, uint64(n))
+	mem.writeUint(addr+16, 8, uint64(n))
+	for i := 0; i < n; i++ {
+		mem.writeUint(data+4*uint64(i), 4, uint64(i))
+	}
+	return sliceType(intType(4))
+}
+
+func TestValidateGoLiteralOutput(t *testing.T) {
+	for _, s := range []string{`42`, `"a\tb"`, `[]int32{0, 1}`, `[2]uint8{3, 4}`, `main.point{x: 1, y: 2}`, `map[int32]int32{5: 50}`} {
+		if err := ValidateGoLiteralOutput(s); err != nil {
+			t.Errorf("ValidateGoLiteralOutput(%s): %v", s, err)
+		}
+	}
+	for _, s := range []string{`struct point {1, 2}`, `map[5:50 6:60]`, `[]int32{0, 1, ...}`} {
+		if err := ValidateGoLiteralOutput(s); err == nil {
+			t.Errorf("ValidateGoLiteralOutput(%s): got no error", s)
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	typ := writeSlice(mem, addr, data, 2000)
+	p := newTestPrinter(mem)
+	want, err := p.sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(want) <= flushSize {
+		t.Fatalf("output is only %d bytes; want more than %d to test flushing", len(want), flushSize)
+	}
+	if err := ValidateGoLiteralOutput(want); err != nil {
+		t.Errorf("slice output is not a Go literal: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := p.fprint(&buf, func() { p.printValueAt(typ, addr) }); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Errorf("Fprint and Sprint output differ:\n%s\n%s", buf.String(), want)
+	}
+
+	// Errors writing the output are reported.
+	w := &failWriter{}
+	if err := p.fprint(w, func() { p.printValueAt(typ, addr) }); err != errWrite {
+		t.Errorf("writing to a failing writer: got error %v, want %v", err, errWrite)
+	}
+	if w.n != 1 {
+		t.Errorf("failing writer written %d times, want 1", w.n)
+	}
+}
+
+var errWrite = errors.New("write failed")
+
+// failWriter is an io.Writer that always fails.
+type failWriter struct {
+	n int // Number of calls to Write.
+}
+
+func (w *failWriter) Write(b []byte) (int, error) {
+	w.n++
+	return 0, errWrite
+}
+
+func BenchmarkSprintSlice(b *testing.B) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	typ := writeSlice(mem, addr, data, 10000)
+	p := newTestPrinter(mem)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s, _ := p.sprintValue(typ, addr)
+		ioutil.Discard.Write([]byte(s))
+	}
+}
+
+func BenchmarkFprintSlice(b *testing.B) {
+	const addr, data = 0x1000, 0x2000
+	mem := make(fakeMemory)
+	typ := writeSlice(mem, addr, data, 10000)
+	p := newTestPrinter(mem)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.fprint(ioutil.Discard, func() { p.printValueAt(typ, addr) })
+	}
+}
+
+// writeStringMap writes a Go map from the strings "k0" to "k<n-1>" to the
+// ints 0 to n-1, as for writeMap, with the bytes of the strings at data.
+func writeStringMap(mem fakeMemory, addr, heap, data uint64, n int) *dwarf.MapType {
+	str := &dwarf.StringType{StructType: *stringHeader()}
+	return writeMapEntries(mem, addr, heap, str, intType(8), n, func(i int, keyAddr, valAddr uint64) {
+		key := fmt.Sprintf("k%d", i)
+		mem.write(data, []byte(key))
+		mem.writeUint(keyAddr, 8, data)
+		mem.writeUint(keyAddr+8, 8, uint64(len(key)))
+		mem.writeUint(valAddr, 8, uint64(i))
+		data += uint64(len(key))
+	})
+}
+
+// maxWriter is an io.Writer that records the size of the largest Write.
+type maxWriter struct {
+	bytes.Buffer
+	max int
+}
+
+func (w *maxWriter) Write(b []byte) (int, error) {
+	if len(b) > w.max {
+		w.max = len(b)
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestFprintMapStreams(t *testing.T) {
+	const addr, heap, data = 0x1000, 0x100000, 0x1000000
+	const n = 2000
+	mem := make(fakeMemory)
+	typ := writeStringMap(mem, addr, heap, data, n)
+	p := newTestPrinter(mem)
+	p.MaxMapEntries = n
+	for _, sorted := range []bool{false, true} {
+		p.SortMapKeys = sorted
+		want, err := p.sprintValue(typ, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(want) <= 2*flushSize {
+			t.Fatalf("output is only %d bytes; want more than %d to test flushing", len(want), 2*flushSize)
+		}
+		// The entries of a large map are written as they are printed,
+		// not held until the whole map is done.
+		var w maxWriter
+		if err := p.fprint(&w, func() { p.printValueAt(typ, addr) }); err != nil {
+			t.Fatal(err)
+		}
+		if w.String() != want {
+			t.Errorf("SortMapKeys=%t: Fprint and Sprint output differ", sorted)
+		}
+		if w.max >= 2*flushSize {
+			t.Errorf("SortMapKeys=%t: wrote %d bytes at once, want less than %d", sorted, w.max, 2*flushSize)
+		}
+	}
+}
+
+// chunkWriter is an io.Writer that records each call to Write.
+type chunkWriter struct {
+	chunks []string
+}
+
+func (w *chunkWriter) Write(b []byte) (int, error) {
+	w.chunks = append(w.chunks, string(b))
+	return len(b), nil
+}
+
+func TestPrintMapChunkSize(t *testing.T) {
+	const addr, heap, data = 0x1000, 0x10000, 0x100000
+	mem := make(fakeMemory)
+	typ := writeStringMap(mem, addr, heap, data, 10)
+	p := newTestPrinter(mem)
+	p.MaxMapEntries = 10
+	p.MapChunkSize = 3
+	// Three chunks of three entries, then the rest.
+	want := []string{
+		`map["k0":0 "k1":1 "k2":2`,
+		` "k3":3 "k4":4 "k5":5`,
+		` "k6":6 "k7":7 "k8":8`,
+		` "k9":9]`,
+	}
+	for _, sorted := range []bool{false, true} {
+		p.SortMapKeys = sorted
+		var w chunkWriter
+		if err := p.fprint(&w, func() { p.printValueAt(typ, addr) }); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(w.chunks, want) {
+			t.Errorf("SortMapKeys=%t: got chunks %q, want %q", sorted, w.chunks, want)
+		}
+	}
+	// Sprint has nothing to flush to, and prints the map whole.
+	if s, err := p.sprintValue(typ, addr); err != nil || s != strings.Join(want, "") {
+		t.Errorf("Sprint: got %s, %v, want %s", s, err, strings.Join(want, ""))
+	}
+}
+
+func benchmarkFprintMap(b *testing.B, chunkSize int) {
+	const addr, heap, data = 0x1000, 0x100000, 0x1000000
+	const n = 10000
+	mem := make(fakeMemory)
+	typ := writeStringMap(mem, addr, heap, data, n)
+	p := newTestPrinter(mem)
+	p.MaxMapEntries = n
+	p.MapChunkSize = chunkSize
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.fprint(ioutil.Discard, func() { p.printValueAt(typ, addr) })
+	}
+}
+
+func BenchmarkFprintMap(b *testing.B)        { benchmarkFprintMap(b, 0) }
+func BenchmarkFprintMapChunked(b *testing.B) { benchmarkFprintMap(b, 100) }
+
+func TestCycleSet(t *testing.T) {
+	var s cycleSet
+	typ, other := intType(4), intType(4)
+	// Sets small enough for the slice, and large enough to need a map.
+	for _, n := range []uint64{maxSmallCycleSet, 3 * maxSmallCycleSet} {
+		s.clear()
+		for a := uint64(0); a < n; a++ {
+			if s.contains(typeAndAddress{typ, a}) {
+				t.Fatalf("set contains %#x before it is added", a)
+			}
+			s.add(typeAndAddress{typ, a})
+		}
+		for a := uint64(0); a < n; a++ {
+			if !s.contains(typeAndAddress{typ, a}) {
+				t.Errorf("set of %d elements does not contain %#x", n, a)
+			}
+		}
+		// Types are compared by identity.
+		if s.contains(typeAndAddress{typ, n}) || s.contains(typeAndAddress{other, 0}) {
+			t.Errorf("set of %d elements contains an element that was not added", n)
+		}
+	}
+	s.clear()
+	if len(s.small) != 0 || s.large != nil {
+		t.Errorf("cleared set has %d elements and map %v", len(s.small), s.large)
+	}
+}
+
+// fiveFieldStruct returns the type of a struct of five int32s.
+func fiveFieldStruct() *dwarf.StructType {
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 20},
+		StructName: "five",
+		Kind:       "struct",
+	}
+	for i := 0; i < 5; i++ {
+		typ.Field = append(typ.Field, &dwarf.StructField{Name: fmt.Sprintf("f%d", i), Type: intType(4), ByteOffset: int64(4 * i)})
+	}
+	return typ
+}
+
+func BenchmarkSprintStruct(b *testing.B) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.write(addr, make([]byte, 20))
+	typ := fiveFieldStruct()
+	p := newTestPrinter(mem)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		p.sprintValue(typ, addr)
+	}
+}
+
+// BenchmarkCycleSet and BenchmarkCycleMap compare a cycleSet with the map
+// it replaced, for the six values visited when printing a struct of five
+// fields.
+func BenchmarkCycleSet(b *testing.B) {
+	typ := fiveFieldStruct()
+	var s cycleSet
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.clear()
+		for a := uint64(0); a < 6; a++ {
+			ta := typeAndAddress{typ, 0x1000 + a}
+			if !s.contains(ta) {
+				s.add(ta)
+			}
+		}
+	}
+}
+
+func BenchmarkCycleMap(b *testing.B) {
+	typ := fiveFieldStruct()
+	m := make(map[typeAndAddress]bool)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for k := range m {
+			delete(m, k)
+		}
+		for a := uint64(0); a < 6; a++ {
+			ta := typeAndAddress{typ, 0x1000 + a}
+			if !m[ta] {
+				m[ta] = true
+			}
+		}
+	}
+}
+
+func TestPrintMaxDepth(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 1)
+	// Nest an int in 5 levels of structs.
+	var typ dwarf.Type = intType(4)
+	for i := 0; i < 5; i++ {
+		typ = &dwarf.StructType{
+			CommonType: dwarf.CommonType{ByteSize: 4},
+			StructName: "s",
+			Kind:       "struct",
+			Field:      []*dwarf.StructField{{Name: "f", Type: typ}},
+		}
+	}
+	tests := []struct {
+		maxDepth int
+		want     string
+	}{
+		{0, "struct s {struct s {struct s {struct s {struct s {1}}}}}"},
+		{6, "struct s {struct s {struct s {struct s {struct s {1}}}}}"},
+		{5, "struct s {struct s {struct s {struct s {struct s {<max depth>}}}}}"},
+		{2, "struct s {struct s {<max depth>}}"},
+	}
+	for _, test := range tests {
+		p := newTestPrinter(mem)
+		p.MaxDepth = test.maxDepth
+		s, err := p.sprintValue(typ, addr)
+		if err != nil || s != test.want {
+			t.Errorf("MaxDepth %d: got %s, %v; want %s", test.maxDepth, s, err, test.want)
+		}
+	}
+}
+
+func TestPrintNilString(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, 0)
+	mem.writeUint(addr+8, 8, 0x2000)
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16},
+		StructName: "s",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "p", Type: ptrTo(intType(4)), ByteOffset: 0},
+			{Name: "q", Type: ptrTo(intType(4)), ByteOffset: 8},
+		},
+	}
+	for nilString, want := range map[string]string{
+		"":     "struct s {nil, 0x2000}",
+		"NULL": "struct s {NULL, 0x2000}",
+	} {
+		p := newTestPrinter(mem)
+		p.NilString = nilString
+		s, err := p.sprintValue(typ, addr)
+		if err != nil || s != want {
+			t.Errorf("NilString %q: got %s, %v; want %s", nilString, s, err, want)
+		}
+	}
+}
+
+func TestPrintFollowPointers(t *testing.T) {
+	const addr, target = 0x1000, 0xc000014050
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, target)
+	mem.writeUint(target, 4, 42)
+	p := newTestPrinter(mem)
+	p.FollowPointers = true
+	s, err := p.sprintValue(ptrTo(intType(4)), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(*0xc000014050) 42"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+
+	// A list node that points to itself.
+	node := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16},
+		StructName: "node",
+		Kind:       "struct",
+	}
+	node.Field = []*dwarf.StructField{
+		{Name: "next", Type: ptrTo(node), ByteOffset: 0},
+		{Name: "val", Type: intType(4), ByteOffset: 8},
+	}
+	mem.writeUint(addr, 8, addr)
+	mem.writeUint(addr+8, 4, 5)
+	s, err = p.sprintValue(node, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct node {(*0x1000) (struct node 0x1000), 5}"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestPrintIndent(t *testing.T) {
+	const addr, data, heap = 0x1000, 0x2000, 0x10000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 1)
+	mem.writeUint(addr+4, 4, 2)
+	mem.writeUint(addr+8, 4, 3)
+	mem.writeUint(addr+12, 4, 4)
+	slice := writeSlice(mem, addr+16, data, 2)
+	m := writeMap(mem, addr+40, heap, []int64{5, 6}, []int64{50, 60})
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 48},
+		StructName: "outer",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "pt", Type: pointStruct(), ByteOffset: 0},
+			{Name: "a", Type: &dwarf.ArrayType{Type: intType(4), StrideBitSize: 32, Count: 2}, ByteOffset: 8},
+			{Name: "s", Type: slice, ByteOffset: 16},
+			{Name: "m", Type: m, ByteOffset: 40},
+			{Name: "e", Type: &dwarf.StructType{StructName: "empty", Kind: "struct"}, ByteOffset: 48},
+		},
+	}
+	p := newTestPrinter(mem)
+	p.Indent = "\t"
+	got, err := p.sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile("testdata/indent.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	p.Indent = "    "
+	got, err = p.sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(string(want), "\t", "    ", -1); got != want {
+		t.Errorf("with four-space Indent, got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A struct whose fields are all filtered out is printed on one line.
+	p.FieldFilter = NewFieldFilter()
+	got, err = p.sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct outer {}"; got != want {
+		t.Errorf("with no fields shown, got %q, want %q", got, want)
+	}
+}
+
+func TestPrintGoLiteral(t *testing.T) {
+	const addr, data, heap = 0x1000, 0x2000, 0x10000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 1)
+	mem.writeUint(addr+4, 4, 2)
+	slice := writeSlice(mem, addr+8, data, 2)
+	m := writeMap(mem, addr+32, heap, []int64{5, 6}, []int64{50, 60})
+	mem.writeUint(addr+40, 8, 0)
+	mem.writeUint(addr+48, 1, 'a')
+	char := &dwarf.CharType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 1, Name: "char"}}}
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 56},
+		StructName: "main.fixture",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "Pt", Type: pointStruct(), ByteOffset: 0},
+			{Name: "S", Type: slice, ByteOffset: 8},
+			{Name: "M", Type: m, ByteOffset: 32},
+			{Name: "P", Type: ptrTo(intType(4)), ByteOffset: 40},
+			{Name: "C", Type: char, ByteOffset: 48},
+		},
+	}
+	golden, err := ioutil.ReadFile("testdata/literal.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The first line is printed without GoLiteral and the second with it.
+	want := strings.Split(strings.TrimSuffix(string(golden), "\n"), "\n")
+	if len(want) != 2 {
+		t.Fatalf("testdata/literal.golden has %d lines, want 2", len(want))
+	}
+	p := newTestPrinter(mem)
+	p.SortMapKeys = true
+	for i, goLiteral := range []bool{false, true} {
+		p.GoLiteral = goLiteral
+		got, err := p.sprintValue(typ, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want[i] {
+			t.Errorf("GoLiteral=%t: got\n%s\nwant\n%s", goLiteral, got, want[i])
+		}
+	}
+	if err := ValidateGoLiteralOutput(want[1]); err != nil {
+		t.Errorf("GoLiteral output is not a Go expression: %v", err)
+	}
+}
+
+func TestPrintEmbeddedField(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 1)
+	mem.writeUint(addr+4, 4, 2)
+	mem.writeUint(addr+8, 4, 3)
+	pt := pointStruct()
+	pt.StructName = "main.Point[main.T]"
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 12},
+		StructName: "main.Labeled",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "Point[main.T]", Type: pt, ByteOffset: 0, Embedded: true},
+			{Name: "Label", Type: intType(4), ByteOffset: 8},
+		},
+	}
+	p := newTestPrinter(mem)
+	for _, test := range []struct {
+		goLiteral bool
+		want      string
+	}{
+		{false, "struct main.Labeled {Point: struct main.Point[main.T] {1, 2}, 3}"},
+		{true, "main.Labeled{Point: main.Point[main.T]{x: 1, y: 2}, Label: 3}"},
+	} {
+		p.GoLiteral = test.goLiteral
+		got, err := p.sprintValue(typ, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.want {
+			t.Errorf("GoLiteral=%t: got %q, want %q", test.goLiteral, got, test.want)
+		}
+	}
+}
+
+func TestPrintValidateAddr(t *testing.T) {
+	const addr, target = 0x1000, 0xdead0000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, target)
+	p := newTestPrinter(mem)
+	p.FollowPointers = true
+	p.ValidateAddrFn = func(a uint64) bool { return a < 0x10000 }
+	s, err := p.sprintValue(ptrTo(intType(4)), addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "(*0xdead0000) <unmapped @0xdead0000>"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+	s, err = p.sprintValue(ptrTo(intType(4)), target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<unmapped @0xdead0000>"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestPrintValidateAddrIndirect(t *testing.T) {
+	const addr, data, heap = 0x1000, 0x2000, 0x10000
+	for _, test := range []struct {
+		name  string
+		write func(mem fakeMemory) dwarf.Type
+		bad   uint64
+		want  string
+	}{
+		{
+			"slice",
+			func(mem fakeMemory) dwarf.Type { return writeSlice(mem, addr, data, 2) },
+			data,
+			"<unmapped @0x2000>",
+		},
+		{
+			"map header",
+			func(mem fakeMemory) dwarf.Type { return writeMap(mem, addr, heap, []int64{1}, []int64{10}) },
+			heap,
+			"map[<unmapped @0x10000>]",
+		},
+		{
+			"map bucket",
+			func(mem fakeMemory) dwarf.Type { return writeMap(mem, addr, heap, []int64{1}, []int64{10}) },
+			heap + 32,
+			"map[<unmapped @0x10020>]",
+		},
+		{
+			"interface type",
+			func(mem fakeMemory) dwarf.Type {
+				mem.writeUint(addr, 8, 0x3000)
+				mem.writeUint(addr+8, 8, 0x4000)
+				return efaceType()
+			},
+			0x3000,
+			"(<unmapped @0x3000>, 0x4000)",
+		},
+	} {
+		mem := make(fakeMemory)
+		typ := test.write(mem)
+		p := newTestPrinter(mem)
+		p.ValidateAddrFn = func(a uint64) bool { return a != test.bad }
+		s, err := p.sprintValue(typ, addr)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+		if s != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, s, test.want)
+		}
+	}
+}
+
+func TestPrintIncompleteArray(t *testing.T) {
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.write(addr, []byte("abc"))
+	char := &dwarf.CharType{BasicType: dwarf.BasicType{CommonType: dwarf.CommonType{ByteSize: 1, Name: "char"}}}
+	typ := &dwarf.ArrayType{Type: char, StrideBitSize: 8, Count: -1}
+	p := newTestPrinter(mem)
+	s, err := p.sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[...]char"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+	if !p.Truncated() {
+		t.Error("Truncated() = false, want true")
+	}
+}
+
+func TestPrintBitFields(t *testing.T) {
+	const addr = 0x1000
+	// struct { unsigned x:3; unsigned y:5; int z:4; }, with x=5, y=17, z=-3,
+	// allocated from the least significant bit, as on amd64.
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 4, 5|17<<3|0xd<<8)
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 4},
+		StructName: "bits",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "x", Type: uintType(4), ByteSize: 4, BitOffset: 29, BitSize: 3},
+			{Name: "y", Type: uintType(4), ByteSize: 4, BitOffset: 24, BitSize: 5},
+			{Name: "z", Type: intType(4), ByteSize: 4, BitOffset: 20, BitSize: 4},
+		},
+	}
+	s, err := newTestPrinter(mem).sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "struct bits {5, 17, -3}"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+// dwarfData returns the DWARF data of the named ELF file.
+func dwarfData(t *testing.T, name string) *dwarf.Data {
+	f, err := elf.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestPrintResolveFuncNames(t *testing.T) {
+	d := dwarfData(t, "../dwarf/testdata/inline.elf")
+	pc, err := d.LookupFunction("caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const addr = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, pc)
+	mem.writeUint(addr+8, 8, 0x123)
+	fn := ptrTo(&dwarf.FuncType{ReturnType: &dwarf.VoidType{}})
+	typ := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16},
+		StructName: "ops",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "f", Type: fn, ByteOffset: 0},
+			{Name: "g", Type: fn, ByteOffset: 8},
+		},
+	}
+	p := newTestPrinter(mem)
+	p.dwarf = d
+	p.ResolveFuncNames = true
+	s, err := p.sprintValue(typ, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("struct ops {%#x /* caller */, 0x123}", pc); s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+
+	// A function value is printed with its name in place of its type.
+	ft := &dwarf.FuncType{ReturnType: &dwarf.VoidType{}}
+	s, err = p.sprintValue(ft, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("caller @%#x ", pc); s != want {
+		t.Errorf("got %q, want %q", s, want)
+	}
+	p.ResolveFuncNames = false
+	s, err = p.sprintValue(ft, pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("func() void @%#x ", pc); s != want {
+		t.Errorf("without ResolveFuncNames: got %q, want %q", s, want)
+	}
+}
+
+func TestSprintGoroutine(t *testing.T) {
+	d := dwarfData(t, "../dwarf/testdata/inline.elf")
+	caller, err := d.LookupFunction("caller")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const g = 0x1000
+	mem := make(fakeMemory)
+	mem.writeUint(g, 8, 17)
+	mem.writeUint(g+8, 4, 4) // _Gwaiting
+	mem.writeUint(g+16, 8, caller)
+	mem.writeUint(g+24, 8, caller+3)
+	mem.writeUint(g+32, 8, 0)
+	sched := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 8},
+		StructName: "runtime.gobuf",
+		Kind:       "struct",
+		Field:      []*dwarf.StructField{{Name: "pc", Type: uintType(8)}},
+	}
+	gType := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 40},
+		StructName: "runtime.g",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "goid", Type: intType(8), ByteOffset: 0},
+			{Name: "atomicstatus", Type: uintType(4), ByteOffset: 8},
+			{Name: "startpc", Type: uintType(8), ByteOffset: 16},
+			{Name: "sched", Type: sched, ByteOffset: 24},
+			{Name: "waiting", Type: ptrTo(pointStruct()), ByteOffset: 32},
+		},
+	}
+	p := newTestPrinter(mem)
+	p.dwarf = d
+	got, err := p.sprintGoroutine(gType, g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("goroutine 17 [waiting]: caller() at inline.c:13 (+0x3)\n"+
+		"\tgoid: 17\n"+
+		"\tatomicstatus: 4\n"+
+		"\tstartpc: %d\n"+
+		"\twaiting: nil", caller)
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if p.FollowPointers {
+		t.Error("FollowPointers left set")
+	}
+}
+
+func TestSprintGoroutineLocals(t *testing.T) {
+	abbrev := []byte{
+		1, 0x11, 1, 0, 0, // compile unit, with children
+		2, 0x24, 0, 0x03, 0x08, 0x0b, 0x0b, 0x3e, 0x0b, 0, 0, // base type: name, byte size, encoding
+		3, 0x34, 0, 0x03, 0x08, 0x49, 0x13, 0x02, 0x0a, 0, 0, // variable: name, type, location
+		4, 0x05, 0, 0x03, 0x08, 0x49, 0x13, 0x02, 0x0a, 0, 0, // formal parameter: name, type, location
+		5, 0x2e, 1, 0x03, 0x08, 0x11, 0x01, 0x12, 0x01, 0x40, 0x0a, 0, 0, // subprogram: name, low pc, high pc, frame base
+		0,
+	}
+	const header = 11
+	const lowpc, pc = 0x400000, 0x400010
+	body := []byte{1}
+	intOff := header + len(body)
+	body = append(body, 2, 'i', 'n', 't', 0, 8, 5) // int, 8 bytes, signed
+	body = append(body, 5, 'f', 0)
+	body = append(body, 0, 0, 0x40, 0, 0, 0, 0, 0)                       // low pc
+	body = append(body, 0, 1, 0x40, 0, 0, 0, 0, 0)                       // high pc
+	body = append(body, 1, 0x9c)                                         // DW_OP_call_frame_cfa
+	body = append(body, 3, 'x', 0, byte(intOff), 0, 0, 0, 2, 0x91, 0x70) // DW_OP_fbreg -16
+	body = append(body, 4, 'y', 0, byte(intOff), 0, 0, 0, 2, 0x91, 0x00) // DW_OP_fbreg 0
+	body = append(body, 0, 0)
+	info := append([]byte{byte(header - 4 + len(body)), 0, 0, 0, 2, 0, 0, 0, 0, 0, 8}, body...)
+	frame := []byte{
+		// CIE: version 3, code alignment 1, data alignment -4,
+		// return address register 16, DW_CFA_def_cfa r7+8.
+		12, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 3, 0, 1, 0x7c, 16, 0x0c, 7, 8,
+		// FDE for [lowpc, lowpc+0x100): DW_CFA_def_cfa_offset_sf -8,
+		// which is 32 after the data alignment.
+		22, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0x40, 0, 0, 0, 0, 0,
+		0, 1, 0, 0, 0, 0, 0, 0,
+		0x13, 0x78,
+	}
+	d, err := dwarf.New(abbrev, nil, frame, info, nil, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stack := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16},
+		StructName: "runtime.stack",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "lo", Type: uintType(8), ByteOffset: 0},
+			{Name: "hi", Type: uintType(8), ByteOffset: 8},
+		},
+	}
+	sched := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 16},
+		StructName: "runtime.gobuf",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "sp", Type: uintType(8), ByteOffset: 0},
+			{Name: "pc", Type: uintType(8), ByteOffset: 8},
+		},
+	}
+	gType := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 48},
+		StructName: "runtime.g",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "stack", Type: stack, ByteOffset: 0},
+			{Name: "sched", Type: sched, ByteOffset: 16},
+			{Name: "goid", Type: intType(8), ByteOffset: 32},
+			{Name: "atomicstatus", Type: uintType(4), ByteOffset: 40},
+		},
+	}
+	const g, sp = 0x1000, 0x5000
+	mem := make(fakeMemory)
+	mem.writeUint(g+16, 8, sp)
+	mem.writeUint(g+24, 8, pc)
+	mem.writeUint(g+32, 8, 5)
+	mem.writeUint(g+40, 4, 4) // _Gwaiting
+	mem.writeUint(sp+32-16, 8, 7)
+	mem.writeUint(sp+32, 8, 0xfffffffffffffffe)
+	p := newTestPrinter(mem)
+	p.dwarf = d
+
+	tests := []struct {
+		lo, hi uint64
+		want   string
+	}{
+		{0x4000, 0x6000, "goroutine 5 [waiting]: f() (+0x10)\n" +
+			"\tgoid: 5\n" +
+			"\tatomicstatus: 4\n" +
+			"\tlocals:\n" +
+			"\t\tx: 7\n" +
+			"\t\ty: -2"},
+		// The saved SP isn't in the goroutine's stack, so it can't be
+		// used to find the locals.
+		{0x6000, 0x8000, "goroutine 5 [waiting]: f() (+0x10)\n" +
+			"\tgoid: 5\n" +
+			"\tatomicstatus: 4"},
+	}
+	for _, test := range tests {
+		mem.writeUint(g, 8, test.lo)
+		mem.writeUint(g+8, 8, test.hi)
+		got, err := p.sprintGoroutine(gType, g)
+		if err != nil {
+			t.Errorf("stack [%#x, %#x): %v", test.lo, test.hi, err)
+		} else if got != test.want {
+			t.Errorf("stack [%#x, %#x): got:\n%s\nwant:\n%s", test.lo, test.hi, got, test.want)
+		}
+	}
+}
+
+func TestPrintBaseAddress(t *testing.T) {
+	const base = 0x7f0000000000
+	d := dwarfData(t, "../dwarf/testdata/enum.elf")
+	addr, err := d.LookupVariable("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.SetBaseAddress(base)
+	mem := make(fakeMemory)
+	mem.writeUint(addr+base, 4, 2)
+	p := newTestPrinter(mem)
+	p.dwarf = d
+	s, err := p.Sprint("c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "BLUE"; s != want {
+		t.Errorf("got %s, want %s", s, want)
+	}
+}
+
+func TestPrintChannelBuffer(t *testing.T) {
+	const addr, hchan, buf = 0x1000, 0x2000, 0x3000
+	hchanType := &dwarf.StructType{
+		CommonType: dwarf.CommonType{ByteSize: 32},
+		StructName: "runtime.hchan",
+		Kind:       "struct",
+		Field: []*dwarf.StructField{
+			{Name: "qcount", Type: uintType(8), ByteOffset: 0},
+			{Name: "dataqsiz", Type: uintType(8), ByteOffset: 8},
+			{Name: "buf", Type: ptrTo(intType(4)), ByteOffset: 16},
+			{Name: "recvx", Type: uintType(8), ByteOffset: 24},
+		},
+	}
+	typ := &dwarf.ChanType{
+		TypedefType: dwarf.TypedefType{
+			CommonType: dwarf.CommonType{ByteSize: 8},
+			Type:       ptrTo(hchanType),
+		},
+		ElemType: intType(4),
+	}
+	for _, test := range []struct {
+		ptr                     uint64
+		qcount, dataqsiz, recvx uint64
+		max                     int64 // MaxArrayElements
+		want                    string
+	}{
+		{0, 0, 0, 0, 0, "(chan int32 nil)"},
+		{hchan, 0, 0, 0, 0, "(chan int32 0x2000)"},
+		{hchan, 0, 4, 0, 0, "(chan int32 0x2000 [0/4])"},
+		{hchan, 2, 4, 0, 0, "(chan int32 0x2000 [2/4] {10, 11})"},
+		{hchan, 3, 4, 2, 0, "(chan int32 0x2000 [3/4] {12, 13, 10})"},
+		{hchan, 9, 4, 1, 0, "(chan int32 0x2000 [9/4] {11, 12, 13, 10})"},
+		{hchan, 4, 4, 1, 2, "(chan int32 0x2000 [4/4] {11, 12, ...})"},
+	} {
+		mem := make(fakeMemory)
+		mem.writeUint(addr, 8, test.ptr)
+		mem.writeUint(hchan, 8, test.qcount)
+		mem.writeUint(hchan+8, 8, test.dataqsiz)
+		mem.writeUint(hchan+16, 8, buf)
+		mem.writeUint(hchan+24, 8, test.recvx)
+		for i := uint64(0); i < 4; i++ {
+			mem.writeUint(buf+4*i, 4, 10+i)
+		}
+		p := newTestPrinter(mem)
+		p.MaxArrayElements = test.max
+		s, err := p.sprintValue(typ, addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != test.want {
+			t.Errorf("got %s, want %s", s, test.want)
+		}
+		if p.Truncated() != (test.max != 0) {
+			t.Errorf("%s: Truncated() = %t", s, p.Truncated())
+		}
+	}
+
+	// The channel and its buffer are checked with ValidateAddrFn.
+	mem := make(fakeMemory)
+	mem.writeUint(addr, 8, hchan)
+	mem.writeUint(hchan, 8, 2)
+	mem.writeUint(hchan+8, 8, 4)
+	mem.writeUint(hchan+16, 8, buf)
+	mem.writeUint(hchan+24, 8, 0)
+	for bad, want := range map[uint64]string{
+		hchan: "(chan int32 0x2000 <unmapped @0x2000>)",
+		buf:   "(chan int32 0x2000 [2/4] <unmapped @0x3000>)",
+	} {
+		p := newTestPrinter(mem)
+		p.ValidateAddrFn = func(a uint64) bool { return a != bad }
+		if s, err := p.sprintValue(typ, addr); err != nil || s != want {
+			t.Errorf("with %#x unmapped: got %s, %v; want %s", bad, s, err, want)
+		}
+	}
+}
@@ -57,6 +57,10 @@ type Server struct {
 	files           []*file // Index == file descriptor.
 	printer         *Printer
 
+	// peekHook, if non-nil, is used to read memory instead of ptrace.
+	// It is set by tests.
+	peekHook func(addr uint64, buf []byte) error
+
 	// goroutineStack reads the stack of a (non-running) goroutine.
 	goroutineStack     func(uint64) ([]debug.Frame, error)
 	goroutineStackOnce sync.Once
@@ -1009,11 +1013,7 @@ func (s *Server) handleGoroutines(req *protocol.GoroutinesRequest, resp *protoco
 		}
 		gr := debug.Goroutine{}
 
-		// Read status from the field named "atomicstatus" or "status".
-		status, err := s.peekUintStructField(gType, g, "atomicstatus")
-		if err != nil {
-			status, err = s.peekUintOrIntStructField(gType, g, "status")
-		}
+		status, err := s.goroutineStatus(gType, g, &gr)
 		if err != nil {
 			return err
 		}
@@ -1021,32 +1021,9 @@ func (s *Server) handleGoroutines(req *protocol.GoroutinesRequest, resp *protoco
 			// _Gdead.
 			continue
 		}
-		gr.Status = invalidStatus
-		if status < uint64(len(gStatus)) {
-			gr.Status = gStatus[status]
-			gr.StatusString = gStatusString[status]
-		} else if status^0x1000 < uint64(len(gScanStatus)) {
-			gr.Status = gScanStatus[status^0x1000]
-			gr.StatusString = gScanStatusString[status^0x1000]
-		}
 		if gr.Status == invalidStatus {
 			return fmt.Errorf("unexpected goroutine status 0x%x", status)
 		}
-		if status == 4 || status == 0x1004 {
-			// _Gwaiting or _Gscanwaiting.
-			// Try reading waitreason to get a better value for StatusString.
-			// Depending on the runtime, waitreason may be a Go string or a C string.
-			if waitreason, err := s.peekStringStructField(gType, g, "waitreason", 80); err == nil {
-				if waitreason != "" {
-					gr.StatusString = waitreason
-				}
-			} else if ptr, err := s.peekPtrStructField(gType, g, "waitreason"); err == nil {
-				waitreason := s.peekCString(ptr, 80)
-				if waitreason != "" {
-					gr.StatusString = waitreason
-				}
-			}
-		}
 
 		gr.ID, err = s.peekIntStructField(gType, g, "goid")
 		if err != nil {
@@ -1080,6 +1057,45 @@ func (s *Server) handleGoroutines(req *protocol.GoroutinesRequest, resp *protoco
 	return nil
 }
 
+// goroutineStatus reads the status of the goroutine whose g struct is at
+// address g, sets gr.Status and gr.StatusString from it, and returns the
+// runtime's value for the status.  gr.Status is invalidStatus for a dead
+// goroutine or an unknown status.
+func (s *Server) goroutineStatus(gType *dwarf.StructType, g uint64, gr *debug.Goroutine) (uint64, error) {
+	// Read status from the field named "atomicstatus" or "status".
+	status, err := s.peekUintStructField(gType, g, "atomicstatus")
+	if err != nil {
+		status, err = s.peekUintOrIntStructField(gType, g, "status")
+	}
+	if err != nil {
+		return 0, err
+	}
+	gr.Status = invalidStatus
+	if status < uint64(len(gStatus)) {
+		gr.Status = gStatus[status]
+		gr.StatusString = gStatusString[status]
+	} else if status^0x1000 < uint64(len(gScanStatus)) {
+		gr.Status = gScanStatus[status^0x1000]
+		gr.StatusString = gScanStatusString[status^0x1000]
+	}
+	if status == 4 || status == 0x1004 {
+		// _Gwaiting or _Gscanwaiting.
+		// Try reading waitreason to get a better value for StatusString.
+		// Depending on the runtime, waitreason may be a Go string or a C string.
+		if waitreason, err := s.peekStringStructField(gType, g, "waitreason", 80); err == nil {
+			if waitreason != "" {
+				gr.StatusString = waitreason
+			}
+		} else if ptr, err := s.peekPtrStructField(gType, g, "waitreason"); err == nil {
+			waitreason := s.peekCString(ptr, 80)
+			if waitreason != "" {
+				gr.StatusString = waitreason
+			}
+		}
+	}
+	return status, nil
+}
+
 // TODO: let users specify how many frames they want.  10 will be enough to
 // determine the reason a goroutine is blocked.
 const goroutineStackFrameCount = 10
@@ -1094,7 +1110,7 @@ func (s *Server) goroutineStackInit(gType *dwarf.StructType) {
 	}
 
 	// Get g field "sched", which contains fields pc and sp.
-	schedField, err := getField(gType, "sched")
+	schedField, err := gType.FieldByName("sched")
 	if err != nil {
 		return
 	}
@@ -1120,7 +1136,7 @@ func (s *Server) goroutineStackInit(gType *dwarf.StructType) {
 		{"sp", &schedSPOffset, &schedSPByteSize},
 	} {
 		var f *dwarf.StructField
-		f, err = getField(schedType, x.field)
+		f, err = schedType.FieldByName(x.field)
 		if err != nil {
 			return
 		}
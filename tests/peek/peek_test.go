@@ -70,7 +70,7 @@ var expectedVars = map[string]string{
 	`main.Z_map_empty`:           `map[]`,
 	`main.Z_map_nil`:             `map[]`,
 	`main.Z_pointer`:             `0xX`,
-	`main.Z_pointer_nil`:         `0x0`,
+	`main.Z_pointer_nil`:         `nil`,
 	`main.Z_slice`:               `[]uint8{115, 108, 105, 99, 101}`,
 	`main.Z_slice_2`:             `[]int8{-121, 121}`,
 	`main.Z_slice_nil`:           `[]uint8{}`,
@@ -83,7 +83,7 @@ var expectedVars = map[string]string{
 	`main.Z_uint8`:               `231`,
 	`main.Z_uintptr`:             `21`,
 	`main.Z_unsafe_pointer`:      `0xX`,
-	`main.Z_unsafe_pointer_nil`:  `0x0`,
+	`main.Z_unsafe_pointer_nil`:  `nil`,
 }
 
 // expectedEvaluate contains expected results of the debug.Evaluate function.